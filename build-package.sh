@@ -8,12 +8,13 @@ if [[ ! -d vendor ]]; then
   glide install --strip-vendor 1>&2
 fi
 
-go build 1>&2
+version="$(git describe 2>/dev/null | sed 's/^v\|-g.*//g' || true)"
+version="${version:-0.0.0}"
+
+go build -ldflags "-X github.com/Mirantis/criproxy/pkg/proxy.Version=${version}" 1>&2
 
 # https://www.debian.org/doc/manuals/maint-guide/update.en.html#idm3360
 date="$(LANG=C date -R)"
-version="$(git describe 2>/dev/null | sed 's/^v\|-g.*//g' || true)"
-version="${version:-0.0.0}"
 author="Ivan Shvedunov <ishvedunov@mirantis.com>"
 
 cat >debian/changelog <<EOF
@@ -4,9 +4,13 @@ set -o nounset
 set -o pipefail
 set -o errtrace
 
-TARGET_PKGS=(v1_9 v1_12)
-K8S_TAGS=(v1.9.11 v1.12.3)
-SUBDIRS=(pkg/kubelet/apis/cri/v1alpha1/runtime pkg/kubelet/apis/cri/runtime/v1alpha2)
+# register.go files in the target packages are not downloaded.
+# They register the services without the streaming methods
+# (see RegisterDummyRuntimeServiceServer), so they survive the update.
+TARGET_PKGS=(v1_9 v1_12 v1)
+REPOS=(kubernetes kubernetes cri-api)
+TAGS=(v1.9.11 v1.12.3 v0.31.0)
+SUBDIRS=(pkg/kubelet/apis/cri/v1alpha1/runtime pkg/kubelet/apis/cri/runtime/v1alpha2 pkg/apis/runtime/v1)
 FILES=(api.pb.go api.proto constants.go)
 
 if [ $(uname) = Darwin ]; then
@@ -18,11 +22,12 @@ top_dir="$(cd "$(dirname "$(readlinkf "${BASH_SOURCE}")")"/..; pwd)"
 
 for ((i = 0; i < ${#TARGET_PKGS[@]}; i++)); do
   dir="pkg/runtimeapis/${TARGET_PKGS[${i}]}"
-  tag="${K8S_TAGS[${i}]}"
+  repo="${REPOS[${i}]}"
+  tag="${TAGS[${i}]}"
   subdir="${SUBDIRS[${i}]}"
   mkdir -p "${top_dir}/${dir}"
   for file in "${FILES[@]}"; do
-    url="https://raw.githubusercontent.com/kubernetes/kubernetes/${tag}/${subdir}/${file}"
+    url="https://raw.githubusercontent.com/kubernetes/${repo}/${tag}/${subdir}/${file}"
     subpath="${dir}/${file}"
     echo >&2 "Downloading ${url} -> ${subpath}"
     curl -sSL "${url}" >"${top_dir}/${subpath}"
@@ -17,68 +17,322 @@ limitations under the License.
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
-	"net/url"
+	"net"
+	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/Mirantis/criproxy/pkg/proxy"
 	"github.com/Mirantis/criproxy/pkg/utils"
 )
 
 const (
-	// XXX: don't hardcode
-	connectionTimeout = 30 * time.Second
+	configCheckInterval = 10 * time.Second
 )
 
 var (
 	listen = flag.String("listen", "/run/criproxy.sock",
-		"The unix socket to listen on, e.g. /run/virtlet.sock")
+		"The address to listen on: a unix socket path or a unix://, tcp:// or tls:// URL, e.g. /run/criproxy.sock or tls://0.0.0.0:10500")
 	connect = flag.String("connect", "/var/run/dockershim.sock",
-		"CRI runtime ids and unix socket(s) to connect to, e.g. /var/run/dockershim.sock,alt:/var/run/another.sock")
+		"CRI runtime ids and addresses to connect to, e.g. /var/run/dockershim.sock,alt:/var/run/another.sock,vm:tcp://10.0.0.2:10500")
+	tlsCertFile = flag.String("tls-cert-file", "",
+		"The certificate file to use for tls:// -listen address")
+	tlsKeyFile = flag.String("tls-private-key-file", "",
+		"The private key file for -tls-cert-file")
+	clientCaFile = flag.String("client-ca-file", "",
+		"The CA file to verify the client certificates for tls:// -listen address (client certificates are not required if this value is empty)")
+	socketMode = flag.String("socket-mode", "",
+		"The octal file mode of the unix socket specified by -listen, e.g. 0660 (the default umask is used if this value is empty)")
+	socketGroup = flag.String("socket-group", "",
+		"The name or the id of the group that owns the unix socket specified by -listen")
+	configPath = flag.String("config", "",
+		"YAML or JSON file describing the runtimes to connect to (-connect is ignored if this value is set)")
 	streamPort    = flag.Int("streamPort", 11250, "streaming port of the default runtime")
 	streamUrl     = flag.String("streamUrl", "", "streaming url of the default runtime (-streamPort is ignored if this value is set)")
 	apiServerHost = flag.String("apiserver", "", "apiserver URL")
-	criVersions   = []proxy.CRIVersion{&proxy.CRI19{}, &proxy.CRI112{}}
+	metricsListen = flag.String("metrics-listen", "",
+		"The address to serve Prometheus metrics on, e.g. 127.0.0.1:9090 (metrics are disabled if this value is empty)")
+	healthListen = flag.String("health-listen", "",
+		"The address to serve /healthz and /readyz on, e.g. 127.0.0.1:9091 (may be the same as -metrics-listen; health checks are disabled if this value is empty)")
+	streamProxyListen = flag.String("stream-proxy-listen", "",
+		"The address to serve proxied exec/attach/port-forward streams on, e.g. :11251 (streams go directly to the runtimes if this value is empty)")
+	streamProxyUrl = flag.String("stream-proxy-url", "",
+		"The URL of the streaming proxy as seen by the apiserver (the node address and the port of -stream-proxy-listen is used if this value is empty)")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 30*time.Second,
+		"The time to wait for in-flight requests to finish upon SIGTERM or SIGINT")
+	criVersions = []proxy.CRIVersion{&proxy.CRI19{}, &proxy.CRI112{}, &proxy.CRIv1{}}
 )
 
-// runCriProxy starts CRI proxy
-func runCriProxy(connect, listen string) error {
-	addrs := strings.Split(connect, ",")
+// loadConfig loads CRI proxy config from the file specified by
+// -config flag or makes it from -connect flag value
+func loadConfig() (*proxy.Config, error) {
+	var config *proxy.Config
 	var err error
-	var realStreamUrl *url.URL
-	if *streamUrl == "" {
-		if realStreamUrl, err = utils.GetStreamUrl(*streamPort); err != nil {
-			return fmt.Errorf("can't get stream url: %v", err)
-		}
+	if *configPath != "" {
+		config, err = proxy.LoadConfig(*configPath)
 	} else {
-		if realStreamUrl, err = url.Parse(*streamUrl); err != nil {
-			return fmt.Errorf("invalid stream url %q: %v", *streamUrl, err)
+		config, err = proxy.ConfigFromConnectSpec(*connect)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	primary := &config.Runtimes[0]
+	switch {
+	case primary.StreamUrl != "":
+		// the stream url is set in the config file
+	case *streamUrl != "":
+		primary.StreamUrl = *streamUrl
+	default:
+		realStreamUrl, err := utils.GetStreamUrl(*streamPort)
+		if err != nil {
+			return nil, fmt.Errorf("can't get stream url: %v", err)
+		}
+		primary.StreamUrl = realStreamUrl.String()
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("bad config: %v", err)
+	}
+	return config, nil
+}
+
+func configModTime() time.Time {
+	if *configPath == "" {
+		return time.Time{}
+	}
+	fi, err := os.Stat(*configPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// watchConfig reloads the config upon SIGHUP or when the config
+// file changes
+func watchConfig(proxies []*proxy.RuntimeProxy, auditLog *proxy.AuditLog, modTime time.Time) {
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hupCh:
+			glog.V(1).Infof("Got SIGHUP, reloading the config")
+		case <-ticker.C:
+			newModTime := configModTime()
+			if newModTime.IsZero() || newModTime.Equal(modTime) {
+				continue
+			}
+			glog.V(1).Infof("Config file %q changed, reloading it", *configPath)
+		}
+		modTime = configModTime()
+		config, err := loadConfig()
+		if err != nil {
+			glog.Errorf("Failed to reload the config, keeping the old one: %v", err)
+			continue
+		}
+		for _, p := range proxies {
+			if err := p.Reload(config); err != nil {
+				glog.Errorf("Failed to reload the config: %v", err)
+			}
+		}
+		auditLog.Reload(config.Audit)
+	}
+}
+
+// newStreamServer makes a streaming proxy server if
+// -stream-proxy-listen flag is set
+func newStreamServer() (*proxy.StreamServer, error) {
+	if *streamProxyListen == "" {
+		return nil, nil
+	}
+	baseUrl := *streamProxyUrl
+	if baseUrl == "" {
+		_, portStr, err := net.SplitHostPort(*streamProxyListen)
+		if err != nil {
+			return nil, fmt.Errorf("bad -stream-proxy-listen value %q: %v", *streamProxyListen, err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("bad -stream-proxy-listen port %q: %v", portStr, err)
+		}
+		u, err := utils.GetStreamUrl(port)
+		if err != nil {
+			return nil, fmt.Errorf("can't get stream proxy url: %v", err)
+		}
+		baseUrl = u.String()
+	}
+	return proxy.NewStreamServer(baseUrl, proxy.DefaultStreamTokenTTL)
+}
+
+// serveHttp serves metrics, health checks and
+// the streaming proxy over HTTP
+func serveHttp(proxies []*proxy.RuntimeProxy, streamServer *proxy.StreamServer) {
+	muxes := make(map[string]*http.ServeMux)
+	handle := func(addr, path string, handler http.Handler) {
+		if addr == "" {
+			return
+		}
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		muxes[addr].Handle(path, handler)
+	}
+	handle(*metricsListen, "/metrics", promhttp.Handler())
+	healthHandler := proxy.NewHealthHandler(proxies)
+	handle(*healthListen, "/healthz", healthHandler)
+	handle(*healthListen, "/readyz", healthHandler)
+	if streamServer != nil {
+		handle(*streamProxyListen, "/stream/", streamServer)
+	}
+	for addr, mux := range muxes {
+		go func(addr string, mux *http.ServeMux) {
+			glog.V(1).Infof("Serving HTTP on %s", addr)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				glog.Errorf("Failed to serve HTTP on %s: %v", addr, err)
+			}
+		}(addr, mux)
+	}
+}
+
+// serverTlsConfig makes the TLS config for tls:// -listen address
+func serverTlsConfig() (*tls.Config, error) {
+	if *tlsCertFile == "" {
+		return nil, nil
+	}
+	tlsConfig := proxy.TlsConfig{
+		CaFile:   *clientCaFile,
+		CertFile: *tlsCertFile,
+		KeyFile:  *tlsKeyFile,
+	}
+	return tlsConfig.ServerConfig()
+}
+
+// socketPermissions returns the permissions for the unix socket
+// specified by -listen
+func socketPermissions() (utils.SocketPermissions, error) {
+	perms := utils.SocketPermissions{Group: *socketGroup}
+	if *socketMode != "" {
+		mode, err := strconv.ParseUint(*socketMode, 8, 32)
+		if err != nil || mode == 0 || mode > 0777 {
+			return perms, fmt.Errorf("bad -socket-mode value %q", *socketMode)
+		}
+		perms.Mode = os.FileMode(mode)
+	}
+	return perms, nil
+}
+
+// serve makes the server listen on the address specified by -listen
+// or on the socket passed by systemd via socket activation
+func serve(server *proxy.Server, listen string) error {
+	tlsConfig, err := serverTlsConfig()
+	if err != nil {
+		return err
+	}
+	ln, err := utils.ActivationListener()
+	if err != nil {
+		return err
+	}
+	if ln == nil {
+		perms, err := socketPermissions()
+		if err != nil {
+			return err
+		}
+		glog.V(1).Infof("Starting CRI proxy on %s", listen)
+		server.SetTlsConfig(tlsConfig)
+		server.SetSocketPermissions(perms)
+		return server.Serve(listen, nil)
+	}
+
+	glog.V(1).Infof("Starting CRI proxy on the socket passed by systemd")
+	ep, err := utils.ParseEndpoint(listen)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	if ep.Tls {
+		if tlsConfig == nil {
+			ln.Close()
+			return fmt.Errorf("no TLS config for %q", listen)
 		}
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	return server.ServeListener(ln, nil)
+}
+
+// runCriProxy starts CRI proxy
+func runCriProxy(listen string) error {
+	modTime := configModTime()
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	streamServer, err := newStreamServer()
+	if err != nil {
+		return err
 	}
+	auditLog := proxy.NewAuditLog(config.Audit)
+	defer auditLog.Close()
+	var proxies []*proxy.RuntimeProxy
 	var interceptors []proxy.Interceptor
 	for _, criVersion := range criVersions {
-		proxy, err := proxy.NewRuntimeProxy(criVersion, addrs, connectionTimeout, realStreamUrl)
+		proxy, err := proxy.NewRuntimeProxy(criVersion, config)
 		if err != nil {
 			return fmt.Errorf("error initializing CRI proxy: %v", err)
 		}
+		if streamServer != nil {
+			proxy.SetStreamServer(streamServer)
+		}
+		proxy.SetAuditLog(auditLog)
+		proxies = append(proxies, proxy)
 		interceptors = append(interceptors, proxy)
 	}
-	glog.V(1).Infof("Starting CRI proxy on socket %s", listen)
+	go watchConfig(proxies, auditLog, modTime)
+	serveHttp(proxies, streamServer)
 	server := proxy.NewServer(interceptors, nil)
-	if err := server.Serve(listen, nil); err != nil {
+	shutdownCh := handleShutdown(server)
+	if err := serve(server, listen); err != nil {
 		return fmt.Errorf("serving failed: %v", err)
 	}
+	// Serve returns as soon as the server stops listening,
+	// so wait for the in-flight requests to be finished
+	<-shutdownCh
 	return nil
 }
 
+// handleShutdown makes the server shut down gracefully upon SIGTERM
+// or SIGINT. The returned channel is closed after the shutdown is
+// complete.
+func handleShutdown(server *proxy.Server) chan struct{} {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	doneCh := make(chan struct{})
+	go func() {
+		sig := <-sigCh
+		// a second signal makes the proxy exit immediately
+		signal.Reset(syscall.SIGTERM, syscall.SIGINT)
+		glog.V(1).Infof("Got %v, shutting down", sig)
+		if server.Shutdown(*shutdownTimeout) {
+			glog.V(1).Infof("Shutdown complete")
+		}
+		close(doneCh)
+	}()
+	return doneCh
+}
+
 func main() {
 	flag.Parse()
-	if err := runCriProxy(*connect, *listen); err != nil {
+	if err := runCriProxy(*listen); err != nil {
 		glog.Error(err)
 		os.Exit(1)
 	}
@@ -20,17 +20,20 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
-	runtimeapis "github.com/Mirantis/criproxy/pkg/runtimeapis"
-	digest  "github.com/opencontainers/go-digest"
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
 
+	runtimeapis "github.com/Mirantis/criproxy/pkg/runtimeapis"
 	"github.com/Mirantis/criproxy/pkg/utils"
 )
 
@@ -42,44 +45,118 @@ const (
 	clientStateConnecting
 	clientStateConnected
 	versionRequestMethod = "RuntimeService/Version"
+	// disconnectReasonTransport denotes a connection that was
+	// found broken by watching its connectivity state
+	disconnectReasonTransport = "transport"
+	// disconnectReasonUnavailable denotes a connection that was
+	// found broken because a request failed with Unavailable code
+	disconnectReasonUnavailable = "unavailable"
+	clientDrainTimeout          = 2 * time.Minute
+	drainCheckInterval          = 100 * time.Millisecond
 )
 
 var errNotConnected = errors.New("not connected")
 var errOldConnection = errors.New("the request was made on an old closed connection")
+var errClientRemoved = errors.New("the runtime was removed from the config")
+var errRuntimeNotAvailable = errors.New("CRI proxy: target runtime is not available")
 
 type client interface {
 	getID() string
 	isPrimary() bool
+	getStreamUrl() url.URL
 	currentState() clientState
+	currentBreakerState() breakerState
+	getRuntimeVersion() *RuntimeVersion
+	getImageFilesystems() []string
+	setImageFilesystems(fsIds []string)
+	lastConnectError() error
 	connect() chan error
 	stop()
+	drain(timeout time.Duration)
 	handleError(err error, tolerateDisconnect bool) error
-	imageName(unprefixedName string) string
-	augmentId(id string) string
-	annotationsMatch(annotations map[string]string) bool
-	idPrefixMatches(id string) (bool, string)
-	imageMatches(imageName string) (bool, string)
-	addPrefix(criObject CRIObject) CRIObject
 	invoke(ctx context.Context, method string, req, resp CRIObject) (CRIObject, error)
 	invokeWithErrorHandling(ctx context.Context, method string, req, resp CRIObject) (CRIObject, error)
 }
 
-type clientProbeFunc func(conn *grpc.ClientConn, connectionTimeout time.Duration) error
+type clientProbeFunc func(conn *grpc.ClientConn, probeTimeout time.Duration) error
 
 type clientConnection struct {
 	sync.Mutex
-	addr              string
-	conn              *grpc.ClientConn
-	probe             clientProbeFunc
-	state             clientState
-	connectionTimeout time.Duration
-	connectErrChs     []chan error
+	addr          string
+	tlsConfig     *TlsConfig
+	conn          *grpc.ClientConn
+	probe         clientProbeFunc
+	state         clientState
+	breaker       *circuitBreaker
+	dialTimeout   time.Duration
+	probeTimeout  time.Duration
+	backoff       utils.Backoff
+	connectErrChs []chan error
+	// connectedAt is the time when the last connection
+	// was established
+	connectedAt time.Time
+	// flaps is the number of the connections in a row that were
+	// lost shortly after they were established
+	flaps         int
+	inFlight      int
+	removed       bool
+	removedCh     chan struct{}
+	onStateChange func(state clientState)
+	onDisconnect  func(reason string)
+	// lastError is the error of the last failed connection attempt.
+	// It's reset when the connection is established.
+	lastError error
+	// version is the version of the runtime reported by it
+	// when the connection was last established
+	version *RuntimeVersion
+	// imageFilesystems are the ids of the image filesystems
+	// reported by the runtime
+	imageFilesystems []string
+}
+
+func newClientConnection(runtimeConfig RuntimeConfig) *clientConnection {
+	return &clientConnection{
+		addr:         runtimeConfig.Socket,
+		tlsConfig:    runtimeConfig.Tls,
+		state:        clientStateOffline,
+		breaker:      newCircuitBreaker(runtimeConfig),
+		dialTimeout:  runtimeConfig.DialTimeout.Duration,
+		probeTimeout: runtimeConfig.ProbeTimeout.Duration,
+		backoff: utils.Backoff{
+			Initial: runtimeConfig.InitialBackoff.Duration,
+			Max:     runtimeConfig.MaxBackoff.Duration,
+			Jitter:  runtimeConfig.BackoffJitter,
+		},
+		removedCh: make(chan struct{}),
+	}
 }
 
-func newClientConnection(addr string, connectionTimeout time.Duration) *clientConnection {
-	return &clientConnection{
-		addr:              addr,
-		connectionTimeout: connectionTimeout,
+// reconnectDelayNonLocked returns the delay before reconnecting to
+// the runtime. It's non-zero if the previous connection was lost
+// shortly after it was established, which is the case for
+// crash-looping runtimes.
+func (c *clientConnection) reconnectDelayNonLocked() time.Duration {
+	if c.connectedAt.IsZero() {
+		return 0
+	}
+	threshold := c.backoff.Max
+	if threshold <= 0 {
+		threshold = DefaultMaxBackoff
+	}
+	if time.Since(c.connectedAt) >= threshold {
+		c.flaps = 0
+		return 0
+	}
+	c.flaps++
+	return c.backoff.Delay(c.flaps)
+}
+
+func (c *clientConnection) setStateNonLocked(state clientState) {
+	c.state = state
+	// the state of a removed client doesn't matter anymore and
+	// may be confused with the state of the new client with the same id
+	if c.onStateChange != nil && !c.removed {
+		c.onStateChange(state)
 	}
 }
 
@@ -89,7 +166,46 @@ func (c *clientConnection) currentState() clientState {
 	return c.state
 }
 
+func (c *clientConnection) currentBreakerState() breakerState {
+	return c.breaker.state()
+}
+
+func (c *clientConnection) lastConnectError() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.lastError
+}
+
+func (c *clientConnection) getRuntimeVersion() *RuntimeVersion {
+	c.Lock()
+	defer c.Unlock()
+	return c.version
+}
+
+func (c *clientConnection) getImageFilesystems() []string {
+	c.Lock()
+	defer c.Unlock()
+	return c.imageFilesystems
+}
+
+func (c *clientConnection) setImageFilesystems(fsIds []string) {
+	c.Lock()
+	defer c.Unlock()
+	c.imageFilesystems = fsIds
+}
+
+func (c *clientConnection) setLastError(err error) {
+	c.Lock()
+	defer c.Unlock()
+	c.lastError = err
+}
+
 func (c *clientConnection) connectNonLocked() chan error {
+	if c.removed {
+		errCh := make(chan error, 1)
+		errCh <- errClientRemoved
+		return errCh
+	}
 	if c.state == clientStateConnected {
 		errCh := make(chan error, 1)
 		errCh <- nil
@@ -102,34 +218,62 @@ func (c *clientConnection) connectNonLocked() chan error {
 		return errCh
 	}
 
-	c.state = clientStateConnecting
+	c.setStateNonLocked(clientStateConnecting)
+	delay := c.reconnectDelayNonLocked()
 	go func() {
+		if delay > 0 {
+			glog.V(1).Infof("Runtime service %s was lost shortly after connecting, waiting %v before reconnecting", c.addr, delay)
+			select {
+			case <-c.removedCh:
+			case <-time.After(delay):
+			}
+		}
 		glog.V(1).Infof("Connecting to runtime service %s", c.addr)
 		var conn *grpc.ClientConn
-		if err := utils.WaitForSocket(c.addr, -1, func() error {
-			var err error
-			conn, err = grpc.Dial(c.addr, grpc.WithInsecure(), grpc.WithTimeout(c.connectionTimeout), grpc.WithDialer(utils.Dial))
+		if err := utils.WaitForSocket(c.addr, c.dialTimeout, c.backoff, -1, c.removedCh, func() error {
+			dialer, err := c.dialer()
+			if err != nil {
+				return err
+			}
+			conn, err = grpc.Dial(c.addr, grpc.WithInsecure(), grpc.WithTimeout(c.dialTimeout), grpc.WithDialer(dialer))
 			if err == nil && c.probe != nil {
-				err = c.probe(conn, c.connectionTimeout)
+				err = c.probe(conn, c.probeTimeout)
 				if err != nil {
 					conn.Close()
 				}
 			}
 			return err
-		}); err != nil {
+		}, c.setLastError); err != nil {
 			glog.Errorf("Failed to connect to the socket: %v", err)
 			err = fmt.Errorf("failed to connect to the socket: %v", err)
+			c.Lock()
+			defer c.Unlock()
+			c.lastError = err
+			c.setStateNonLocked(clientStateOffline)
 			for _, ch := range c.connectErrChs {
 				ch <- err
 			}
+			c.connectErrChs = nil
 			return
 		}
 
 		c.Lock()
 		defer c.Unlock()
+		if c.removed {
+			conn.Close()
+			c.setStateNonLocked(clientStateOffline)
+			for _, ch := range c.connectErrChs {
+				ch <- errClientRemoved
+			}
+			c.connectErrChs = nil
+			return
+		}
 		glog.V(1).Infof("Connected to runtime service %s", c.addr)
-		c.state = clientStateConnected
+		c.setStateNonLocked(clientStateConnected)
 		c.conn = conn
+		c.connectedAt = time.Now()
+		go c.watchConn(conn)
+		c.lastError = nil
 
 		for _, ch := range c.connectErrChs {
 			ch <- nil
@@ -139,6 +283,63 @@ func (c *clientConnection) connectNonLocked() chan error {
 	return errCh
 }
 
+// dialer returns the dialer for the runtime address. The TLS
+// certificates are reloaded on each connection attempt so that
+// they can be rotated without restarting the proxy.
+func (c *clientConnection) dialer() (func(string, time.Duration) (net.Conn, error), error) {
+	ep, err := utils.ParseEndpoint(c.addr)
+	if err != nil || !ep.Tls {
+		return utils.Dial, err
+	}
+	tlsConfig := c.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &TlsConfig{}
+	}
+	config, err := tlsConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return utils.NewDialer(config), nil
+}
+
+// watchConn watches the connectivity state of the gRPC connection
+// and starts reconnecting as soon as the connection is lost, without
+// waiting for a request to fail. It returns when the connection is
+// closed.
+func (c *clientConnection) watchConn(conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for conn.WaitForStateChange(context.Background(), state) {
+		wasReady := state == connectivity.Ready
+		state = conn.GetState()
+		switch {
+		case state == connectivity.Shutdown:
+			return
+		case wasReady && state != connectivity.Ready:
+			// depending on gRPC version, a lost connection
+			// goes either to TransientFailure or Idle state
+			c.Lock()
+			if c.conn == conn {
+				glog.Warningf("Lost the connection to runtime service %s (%s)", c.addr, state)
+				c.reconnectNonLocked(disconnectReasonTransport)
+			}
+			c.Unlock()
+			return
+		}
+	}
+}
+
+// reconnectNonLocked closes the current connection and starts
+// reestablishing it.
+func (c *clientConnection) reconnectNonLocked(reason string) {
+	if c.conn != nil && c.onDisconnect != nil && !c.removed {
+		c.onDisconnect(reason)
+	}
+	c.stopNonLocked()
+	if !c.removed {
+		c.connectNonLocked()
+	}
+}
+
 func (c *clientConnection) connect() chan error {
 	c.Lock()
 	defer c.Unlock()
@@ -153,163 +354,120 @@ func (c *clientConnection) stopNonLocked() {
 		glog.Errorf("Failed to close gRPC connection: %v", err)
 	}
 	c.conn = nil
-	c.state = clientStateOffline
+	c.setStateNonLocked(clientStateOffline)
+}
+
+// markRemovedNonLocked makes the client give up any pending
+// connection attempts and never reconnect again
+func (c *clientConnection) markRemovedNonLocked() {
+	if !c.removed {
+		c.removed = true
+		close(c.removedCh)
+	}
 }
 
 func (c *clientConnection) stop() {
 	c.Lock()
 	defer c.Unlock()
+	c.markRemovedNonLocked()
 	c.stopNonLocked()
 }
 
+// drain marks the connection as removed so it's never reestablished
+// and then closes it after all of the in-flight requests are
+// finished or the timeout expires, whichever comes first.
+func (c *clientConnection) drain(timeout time.Duration) {
+	c.Lock()
+	c.markRemovedNonLocked()
+	c.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		c.Lock()
+		if c.inFlight == 0 || time.Now().After(deadline) {
+			if c.inFlight != 0 {
+				glog.Warningf("Closing the connection to %s with %d request(s) still in flight", c.addr, c.inFlight)
+			}
+			c.stopNonLocked()
+			c.Unlock()
+			return
+		}
+		c.Unlock()
+		time.Sleep(drainCheckInterval)
+	}
+}
+
+// acquireConn returns the current gRPC connection and increments
+// the in-flight request counter. releaseConn must be called after
+// the request is finished.
+func (c *clientConnection) acquireConn() (*grpc.ClientConn, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.state != clientStateConnected {
+		return nil, errNotConnected
+	}
+	c.inFlight++
+	return c.conn, nil
+}
+
+func (c *clientConnection) releaseConn() {
+	c.Lock()
+	defer c.Unlock()
+	c.inFlight--
+}
+
 // handleError checks whether an error returned by grpc call has
 // 'Unavailable' code in which case it disconnects from the client and
 // starts trying to reestablish the connection. In case if
 // tolerateDisconnect is true, it also returns nil in this case. In
 // other cases, including non-'Unavailable' errors, it returns the
-// original err value
+// original err value annotated with the runtime address. The errors
+// caused by the open circuit breaker are converted to 'Unavailable'
+// ones without reconnecting.
 func (c *clientConnection) handleError(err error, tolerateDisconnect bool) error {
+	if _, ok := err.(*breakerOpenError); ok {
+		if tolerateDisconnect {
+			return nil
+		}
+		return status.Error(codes.Unavailable, err.Error())
+	}
 	if grpc.Code(err) == codes.Unavailable {
 		c.Lock()
 		defer c.Unlock()
-		c.stopNonLocked()
-		c.connectNonLocked()
+		c.reconnectNonLocked(disconnectReasonUnavailable)
 
 		if tolerateDisconnect {
 			return nil
 		}
 	}
+	if st, ok := status.FromError(err); ok {
+		// keep the status code so that the callers, including
+		// kubelet, can check it
+		return status.Errorf(st.Code(), "%q: %s", c.addr, st.Message())
+	}
 	return fmt.Errorf("%q: %v", c.addr, err)
 }
 
 type clientBase struct {
-	id string
-}
-
-func (c *clientBase) getID() string { return c.id }
-
-func (c *clientBase) isPrimary() bool {
-	return c.id == ""
-}
-
-func (c *clientBase) imageName(unprefixedName string) string {
-	if c.isPrimary() {
-		return unprefixedName
-	}
-	return c.id + "/" + unprefixedName
-}
-
-func (c *clientBase) augmentId(id string) string {
-	if !c.isPrimary() {
-		return c.id + "__" + id
-	}
-	return id
-}
-
-func (c *clientBase) annotationsMatch(annotations map[string]string) bool {
-	targetRuntime, found := annotations[targetRuntimeAnnotationKey]
-	if c.isPrimary() {
-		return !found
-	}
-	return found && targetRuntime == c.id
-}
-
-func (c *clientBase) idPrefixMatches(id string) (bool, string) {
-	switch {
-	case c.isPrimary():
-		return true, id
-	case strings.HasPrefix(id, c.id+"__"):
-		return true, id[len(c.id)+2:]
-	default:
-		return false, ""
-	}
+	id        string
+	streamUrl url.URL
 }
 
-func (c *clientBase) imageMatches(imageName string) (bool, string) {
-	switch {
-	case c.isPrimary():
-		return true, imageName
-	case strings.HasPrefix(imageName, c.id+"/"):
-		return true, imageName[len(c.id)+1:]
-	default:
-		return false, ""
-	}
-}
-
-func (c *clientBase) prefixSandbox(unprefixedSandbox PodSandbox) PodSandbox {
-	if c.isPrimary() {
-		return unprefixedSandbox
-	}
-	sandbox := unprefixedSandbox.Copy()
-	sandbox.SetId(c.augmentId(unprefixedSandbox.Id()))
-	return sandbox
-}
-
-func (c *clientBase) prefixContainer(unprefixedContainer Container) Container {
-	if c.isPrimary() {
-		return unprefixedContainer
-	}
-	container := unprefixedContainer.Copy()
-	container.SetId(c.augmentId(unprefixedContainer.Id()))
-	container.SetPodSandboxId(c.augmentId(unprefixedContainer.PodSandboxId()))
-	// don't prefix digests
-	if _, err := digest.Parse(unprefixedContainer.Image()); err != nil {
-		container.SetImage(c.imageName(unprefixedContainer.Image()))
+func newClientBase(runtimeConfig RuntimeConfig) clientBase {
+	c := clientBase{id: runtimeConfig.Id}
+	// the url is checked by RuntimeConfig.Validate()
+	if u, err := url.Parse(runtimeConfig.StreamUrl); err == nil {
+		c.streamUrl = *u
 	}
-	return container
+	return c
 }
 
-func (c *clientBase) prefixContainerStats(unprefixedStats ContainerStats) ContainerStats {
-	if c.isPrimary() {
-		return unprefixedStats
-	}
-	stats := unprefixedStats.Copy()
-	stats.SetId(c.augmentId(unprefixedStats.Id()))
-	return stats
-}
+func (c *clientBase) getID() string { return c.id }
 
-func (c *clientBase) prefixImage(unprefixedImage Image) Image {
-	if c.isPrimary() {
-		return unprefixedImage
-	}
-	image := unprefixedImage.Copy()
-	// only prefix image id if it's not a digest
-	// so we don't get prefix/sha256:... which doesn't make sense
-	if _, err := digest.Parse(image.Id()); err != nil {
-		image.SetId(c.imageName(image.Id()))
-	}
-	newRepoTags := make([]string, len(image.RepoTags()))
-	for n, tag := range image.RepoTags() {
-		newRepoTags[n] = c.imageName(tag)
-	}
-	image.SetRepoTags(newRepoTags)
-	// repo digests may or may not include the image name
-	newRepoDigests := make([]string, len(image.RepoDigests()))
-	for n, digest := range image.RepoDigests() {
-		p := strings.Index(digest, "@")
-		if p > 0 {
-			newRepoDigests[n] = c.imageName(digest)
-		} else {
-			newRepoDigests[n] = digest
-		}
-	}
-	image.SetRepoDigests(newRepoDigests)
-	return image
-}
+func (c *clientBase) getStreamUrl() url.URL { return c.streamUrl }
 
-func (c *clientBase) addPrefix(criObject CRIObject) CRIObject {
-	switch o := criObject.(type) {
-	case PodSandbox:
-		return c.prefixSandbox(o)
-	case Container:
-		return c.prefixContainer(o)
-	case ContainerStats:
-		return c.prefixContainerStats(o)
-	case Image:
-		return c.prefixImage(o)
-	default:
-		return o
-	}
+func (c *clientBase) isPrimary() bool {
+	return c.id == ""
 }
 
 type apiClient struct {
@@ -320,30 +478,48 @@ type apiClient struct {
 
 var _ client = &apiClient{}
 
-func newApiClient(criVersion CRIVersion, clientConn *clientConnection, id string) *apiClient {
+func newApiClient(criVersion CRIVersion, clientConn *clientConnection, base clientBase) *apiClient {
 	return &apiClient{
-		clientBase:       clientBase{id},
+		clientBase:       base,
 		criVersion:       criVersion,
 		clientConnection: clientConn,
 	}
 }
 
-func (c *apiClient) getConn() (*grpc.ClientConn, error) {
-	c.Lock()
-	defer c.Unlock()
-	if c.state != clientStateConnected {
-		return nil, errNotConnected
-	}
-	return c.conn, nil
+// call invokes the method on the connection, applying the timeout
+// of the method for the runtime. It fails fast if the circuit
+// breaker of the runtime is open.
+func (c *apiClient) call(ctx context.Context, conn *grpc.ClientConn, method string, req, resp CRIObject) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	runtimeCtx, cancel, timeout := runtimeContext(ctx, c.id, method)
+	defer cancel()
+	start := time.Now()
+	err := grpc.Invoke(runtimeCtx, method, req.Unwrap(), resp.Unwrap(), conn)
+	observeRuntimeRequest(c.id, method, start, err)
+	auditRuntimeCall(ctx, c.id, req, resp, err)
+	if ctx.Err() != nil {
+		// the deadline of the incoming request has passed
+		// or it was cancelled before the runtime timeout,
+		// which is not the runtime's fault
+		c.breaker.abandon()
+		return deadlineError(c.id, method, 0, err)
+	}
+	err = deadlineError(c.id, method, timeout, err)
+	c.breaker.record(err)
+	return err
 }
 
 func (c *apiClient) invoke(ctx context.Context, method string, req, resp CRIObject) (CRIObject, error) {
-	conn, err := c.getConn()
+	conn, err := c.acquireConn()
 	if err != nil {
 		return nil, err
 	}
+	defer c.releaseConn()
 
-	if err = grpc.Invoke(ctx, method, req.Unwrap(), resp.Unwrap(), conn); grpc.Code(err) == codes.Unavailable {
+	err = c.call(ctx, conn, method, req, resp)
+	if grpc.Code(err) == codes.Unavailable {
 		c.Lock()
 		defer c.Unlock()
 		if conn != c.conn {
@@ -356,85 +532,118 @@ func (c *apiClient) invoke(ctx context.Context, method string, req, resp CRIObje
 }
 
 func (c *apiClient) invokeWithErrorHandling(ctx context.Context, method string, req, resp CRIObject) (CRIObject, error) {
-	err := grpc.Invoke(ctx, method, req.Unwrap(), resp.Unwrap(), c.conn)
+	conn, err := c.acquireConn()
+	if err != nil {
+		return nil, err
+	}
+	defer c.releaseConn()
+	err = c.call(ctx, conn, method, req, resp)
 	if err != nil {
 		err = c.handleError(err, false)
 	}
 	return resp, err
 }
 
-type upgradingClient struct {
+// convertingClient converts the requests and the responses between
+// the CRI version used by the proxy and the one used by the runtime
+type convertingClient struct {
 	client
-	legacyVersion CRIVersion
-	newVersion    CRIVersion
+	proxyVersion   CRIVersion
+	runtimeVersion CRIVersion
 }
 
-var _ client = &upgradingClient{}
+var _ client = &convertingClient{}
 
-func newUpgradingClient(next client, legacyVersion UpgradableCRIVersion) *upgradingClient {
-	return &upgradingClient{
-		client:        next,
-		legacyVersion: legacyVersion,
-		newVersion:    legacyVersion.UpgradesTo(),
+func newConvertingClient(next client, proxyVersion, runtimeVersion CRIVersion) *convertingClient {
+	return &convertingClient{
+		client:         next,
+		proxyVersion:   proxyVersion,
+		runtimeVersion: runtimeVersion,
 	}
 }
 
-func (c *upgradingClient) addPrefix(o CRIObject) CRIObject {
-	return c.downgradeCRIObject(c.client.addPrefix(c.upgradeCRIObject(o)))
+func (c *convertingClient) convertMethod(method string) string {
+	return "/" + c.runtimeVersion.ProtoPackage() + strings.TrimPrefix(method, "/"+c.proxyVersion.ProtoPackage())
 }
 
-func (c *upgradingClient) invoke(ctx context.Context, method string, req, resp CRIObject) (CRIObject, error) {
-	method = strings.Replace(method, "runtime.", "runtime.v1alpha2.", 1)
-	r, err := c.client.invoke(ctx, method, c.upgradeCRIObject(req), c.upgradeCRIObject(resp))
+func (c *convertingClient) invoke(ctx context.Context, method string, req, resp CRIObject) (CRIObject, error) {
+	r, err := c.client.invoke(ctx, c.convertMethod(method), c.convertRequest(req), c.convertRequest(resp))
 	if err != nil {
 		return nil, err
 	}
-	return c.downgradeCRIObjectTo(r, resp), err
+	return c.convertResponse(r, resp), err
 }
 
-func (c *upgradingClient) invokeWithErrorHandling(ctx context.Context, method string, req, resp CRIObject) (CRIObject, error) {
-	method = strings.Replace(method, "runtime.", "runtime.v1alpha2.", 1)
-	r, err := c.client.invokeWithErrorHandling(ctx, method, c.upgradeCRIObject(req), c.upgradeCRIObject(resp))
+func (c *convertingClient) invokeWithErrorHandling(ctx context.Context, method string, req, resp CRIObject) (CRIObject, error) {
+	r, err := c.client.invokeWithErrorHandling(ctx, c.convertMethod(method), c.convertRequest(req), c.convertRequest(resp))
 	if err != nil {
 		return nil, err
 	}
-	return c.downgradeCRIObjectTo(r, resp), nil
+	return c.convertResponse(r, resp), nil
 }
 
-func (c *upgradingClient) upgradeCRIObject(o CRIObject) CRIObject {
-	upgraded, err := runtimeapis.Upgrade(o.Unwrap())
+func (c *convertingClient) convertRequest(o CRIObject) CRIObject {
+	converted, err := runtimeapis.ConvertTo(o.Unwrap(), c.runtimeVersion.ProtoPackage())
 	if err != nil {
-		log.Panicf("Couldn't upgrade %T: %v", o.Unwrap(), err)
+		log.Panicf("Couldn't convert %T to %s: %v", o.Unwrap(), c.runtimeVersion.ProtoPackage(), err)
 	}
-	r, _, err := c.newVersion.WrapObject(upgraded)
+	r, _, err := c.runtimeVersion.WrapObject(converted)
 	if err != nil {
-		log.Panicf("Error wrapping upgraded object %T: %v", upgraded, err)
+		log.Panicf("Error wrapping converted object %T: %v", converted, err)
 	}
 	return r
 }
 
-func (c *upgradingClient) downgradeCRIObject(o CRIObject) CRIObject {
-	downgraded, err := runtimeapis.Downgrade(o.Unwrap())
-	if err != nil {
-		log.Panicf("Couldn't downgrade %T: %v", o.Unwrap(), err)
-	}
-	r, _, err := c.legacyVersion.WrapObject(downgraded)
-	if err != nil {
-		log.Panicf("Error wrapping downgraded object %T: %v", downgraded, err)
+// convertResponse converts the response of the runtime to the CRI
+// version used by the proxy. The conversion between CRI 1.9 and the
+// newer versions loses the ids of the image filesystems, so they're
+// restored using the mounts of the node.
+func (c *convertingClient) convertResponse(o CRIObject, resp CRIObject) CRIObject {
+	resp = c.convertResponseTo(o, resp)
+	toUuid := c.proxyVersion.ProtoPackage() == runtimeapis.ProtoPackage19
+	if toUuid == (c.runtimeVersion.ProtoPackage() == runtimeapis.ProtoPackage19) {
+		return resp
+	}
+	in, ok := o.(ObjectList)
+	if !ok {
+		return resp
+	}
+	out := resp.(ObjectList).Items()
+	for n, item := range in.Items() {
+		fs, ok := item.(FilesystemUsage)
+		if !ok {
+			// not an ImageFsInfo response
+			return resp
+		}
+		fsId := fs.FsId()
+		if n >= len(out) || fsId == "" {
+			continue
+		}
+		if toUuid {
+			fsId = imageFsIds.uuid(fsId)
+		} else {
+			fsId = imageFsIds.mountPoint(fsId)
+		}
+		out[n].(FilesystemUsage).SetFsId(fsId)
 	}
-	return r
+	return resp
 }
 
-func (c *upgradingClient) downgradeCRIObjectTo(o CRIObject, resp CRIObject) CRIObject {
-	downgraded, err := runtimeapis.Downgrade(o.Unwrap())
+func (c *convertingClient) convertResponseTo(o CRIObject, resp CRIObject) CRIObject {
+	converted, err := runtimeapis.ConvertTo(o.Unwrap(), c.proxyVersion.ProtoPackage())
 	if err != nil {
-		log.Panicf("Couldn't downgrade %T: %v", o.Unwrap(), err)
+		log.Panicf("Couldn't convert %T to %s: %v", o.Unwrap(), c.proxyVersion.ProtoPackage(), err)
 	}
-	resp.Wrap(downgraded)
+	resp.Wrap(converted)
 	return resp
 }
 
-// autoClient detects server version and chooses upgradingClient
+// runtimeCRIVersions lists CRI versions that are probed when
+// connecting to a runtime, newest first. The CRI version used by the
+// proxy is tried last if it's not in this list.
+var runtimeCRIVersions = []CRIVersion{&CRIv1{}, &CRI112{}}
+
+// autoClient detects server version and chooses convertingClient
 // or plain apiClient depending on it
 type autoClient struct {
 	clientBase
@@ -445,52 +654,83 @@ type autoClient struct {
 
 var _ client = &autoClient{}
 
-func newAutoClient(proxyCRIVersion CRIVersion, addr string, connectionTimeout time.Duration) *autoClient {
-	id := ""
-	parts := strings.SplitN(addr, ":", 2)
-	if len(parts) == 2 {
-		id, addr = parts[0], parts[1]
-	}
-	conn := newClientConnection(addr, connectionTimeout)
+func newAutoClient(proxyCRIVersion CRIVersion, runtimeConfig RuntimeConfig) *autoClient {
+	conn := newClientConnection(runtimeConfig)
 	c := &autoClient{
-		clientBase:       clientBase{id},
+		clientBase:       newClientBase(runtimeConfig),
 		clientConnection: conn,
 		proxyCRIVersion:  proxyCRIVersion,
 	}
 	conn.probe = c.checkConnection
+	conn.onStateChange = func(state clientState) {
+		setRuntimeStateMetric(c.id, proxyCRIVersion.ProtoPackage(), state)
+	}
+	conn.onDisconnect = func(reason string) {
+		observeRuntimeDisconnect(c.id, proxyCRIVersion.ProtoPackage(), reason)
+	}
+	setRuntimeStateMetric(c.id, proxyCRIVersion.ProtoPackage(), clientStateOffline)
 	return c
 }
 
-func (c *autoClient) checkVersion(criVersion CRIVersion, conn *grpc.ClientConn, connectionTimeout time.Duration) error {
-	ctx, _ := context.WithTimeout(context.Background(), connectionTimeout)
+func (c *autoClient) checkVersion(criVersion CRIVersion, conn *grpc.ClientConn, probeTimeout time.Duration) (interface{}, error) {
+	ctx, _ := context.WithTimeout(context.Background(), probeTimeout)
 	pReq, pResp := criVersion.ProbeRequest()
 	reqMethod := fmt.Sprintf("/%s.%s", criVersion.ProtoPackage(), versionRequestMethod)
-	return grpc.Invoke(ctx, reqMethod, pReq, pResp, conn)
+	if err := grpc.Invoke(ctx, reqMethod, pReq, pResp, conn); err != nil {
+		return nil, err
+	}
+	return pResp, nil
 }
 
-func (c *autoClient) checkConnection(conn *grpc.ClientConn, connectionTimeout time.Duration) error {
-	upgrade := []bool{false}
-	toTry := []CRIVersion{c.proxyCRIVersion}
-	upgradableVersion, upgradable := c.proxyCRIVersion.(UpgradableCRIVersion)
-	if upgradable {
-		upgrade = []bool{true, false}
-		toTry = []CRIVersion{upgradableVersion.UpgradesTo(), c.proxyCRIVersion}
+func (c *autoClient) checkConnection(conn *grpc.ClientConn, probeTimeout time.Duration) error {
+	toTry := append([]CRIVersion{}, runtimeCRIVersions...)
+	found := false
+	for _, v := range toTry {
+		if v.ProtoPackage() == c.proxyCRIVersion.ProtoPackage() {
+			found = true
+			break
+		}
+	}
+	if !found {
+		toTry = append(toTry, c.proxyCRIVersion)
 	}
 
 	var err error
-	for n, v := range toTry {
-		if err = c.checkVersion(v, conn, connectionTimeout); err == nil {
-			var next client = newApiClient(v, c.clientConnection, c.id)
-			if upgrade[n] {
-				next = newUpgradingClient(next, upgradableVersion)
+	for _, v := range toTry {
+		var pResp interface{}
+		if pResp, err = c.checkVersion(v, conn, probeTimeout); err == nil {
+			var next client = newApiClient(v, c.clientConnection, c.clientBase)
+			converted := v.ProtoPackage() != c.proxyCRIVersion.ProtoPackage()
+			if converted {
+				glog.V(1).Infof("Using %s for runtime %q, converting from %s", v.ProtoPackage(), runtimeName(c.id), c.proxyCRIVersion.ProtoPackage())
+				next = newConvertingClient(next, c.proxyCRIVersion, v)
 			}
 			c.next = next
+			c.setVersion(v, pResp, converted)
 			break
 		}
 	}
 	return err
 }
 
+func (c *autoClient) setVersion(criVersion CRIVersion, pResp interface{}, converted bool) {
+	wrapped, _, err := criVersion.WrapObject(pResp)
+	if err != nil {
+		glog.Errorf("Can't wrap Version response of runtime %q: %v", runtimeName(c.id), err)
+		return
+	}
+	resp := wrapped.(VersionResponse)
+	c.Lock()
+	defer c.Unlock()
+	c.version = &RuntimeVersion{
+		Name:       resp.RuntimeName(),
+		Version:    resp.RuntimeVersion(),
+		ApiVersion: resp.RuntimeApiVersion(),
+		Api:        criVersion.ProtoPackage(),
+		Converted:  converted,
+	}
+}
+
 func (c *autoClient) getNext() (client, error) {
 	c.Lock()
 	defer c.Unlock()
@@ -515,5 +755,3 @@ func (c *autoClient) invokeWithErrorHandling(ctx context.Context, method string,
 	}
 	return next.invokeWithErrorHandling(ctx, method, req, resp)
 }
-
-// TODO: handle grpc's ClientTransport.Error() to reconnect
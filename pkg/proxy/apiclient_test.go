@@ -0,0 +1,56 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectDelay(t *testing.T) {
+	c := newClientConnection(RuntimeConfig{
+		Socket:         "/run/foo.sock",
+		InitialBackoff: Duration{100 * time.Millisecond},
+		MaxBackoff:     Duration{time.Second},
+	})
+	if d := c.reconnectDelayNonLocked(); d != 0 {
+		t.Errorf("non-zero delay %v before the first connection", d)
+	}
+
+	// the connections that are lost right after they're
+	// established are reestablished with growing delays
+	for _, expected := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+	} {
+		c.connectedAt = time.Now()
+		if d := c.reconnectDelayNonLocked(); d != expected {
+			t.Errorf("reconnect delay %v instead of %v", d, expected)
+		}
+	}
+
+	// the delay is reset after a long-lived connection
+	c.connectedAt = time.Now().Add(-2 * time.Second)
+	if d := c.reconnectDelayNonLocked(); d != 0 {
+		t.Errorf("non-zero delay %v after a long-lived connection", d)
+	}
+	c.connectedAt = time.Now()
+	if d := c.reconnectDelayNonLocked(); d != 100*time.Millisecond {
+		t.Errorf("reconnect delay %v instead of %v", d, 100*time.Millisecond)
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// auditEntry is a line of the audit log
+type auditEntry struct {
+	sync.Mutex `json:"-"`
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	// Runtimes lists the runtimes the request was passed to
+	Runtimes []string `json:"runtimes,omitempty"`
+	// Ids lists the pod sandbox and container ids as seen
+	// by the runtimes, i.e. without the runtime prefixes
+	Ids []string `json:"ids,omitempty"`
+	// Duration is the duration of the request in seconds
+	Duration float64 `json:"duration"`
+	Code     string  `json:"code"`
+	Error    string  `json:"error,omitempty"`
+	// Request and Response are only set if the payload is logged.
+	// The request is marshalled before it's handled, as the
+	// handlers modify it
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	payload  bool
+	// redact removes the secrets from the payload
+	redact func(interface{}) interface{}
+}
+
+func (e *auditEntry) addRuntime(name string) {
+	for _, n := range e.Runtimes {
+		if n == name {
+			return
+		}
+	}
+	e.Runtimes = append(e.Runtimes, name)
+}
+
+func (e *auditEntry) addId(id string) {
+	if id == "" {
+		return
+	}
+	for _, i := range e.Ids {
+		if i == id {
+			return
+		}
+	}
+	e.Ids = append(e.Ids, id)
+}
+
+// AuditLog writes a JSON line for each CRI request handled by the
+// proxy to a file, which is rotated after it reaches the max size.
+// The zero value is a disabled audit log.
+type AuditLog struct {
+	sync.Mutex
+	config *AuditConfig
+	file   *os.File
+	size   int64
+}
+
+// NewAuditLog makes a new audit log. The file is opened upon the
+// first write. Nil config means that the audit log is disabled.
+func NewAuditLog(config *AuditConfig) *AuditLog {
+	return &AuditLog{config: config}
+}
+
+// Reload updates the config of the audit log. If the path is
+// changed, the current file is closed and the new one is opened
+// upon the next write.
+func (a *AuditLog) Reload(config *AuditConfig) {
+	a.Lock()
+	defer a.Unlock()
+	if config == nil || a.config == nil || config.Path != a.config.Path {
+		a.closeNonLocked()
+	}
+	a.config = config
+}
+
+// Close closes the audit log file. It's reopened
+// upon the next write, if any.
+func (a *AuditLog) Close() {
+	a.Lock()
+	defer a.Unlock()
+	a.closeNonLocked()
+}
+
+func (a *AuditLog) closeNonLocked() {
+	if a.file == nil {
+		return
+	}
+	if err := a.file.Close(); err != nil {
+		glog.Errorf("Error closing the audit log: %v", err)
+	}
+	a.file = nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// start returns a new audit entry for the request if the method
+// should be logged, and nil otherwise. If redact is not nil, it's
+// applied to the request and the response before they're marshalled.
+func (a *AuditLog) start(fullMethod string, req interface{}, redact func(interface{}) interface{}) *auditEntry {
+	if a == nil {
+		return nil
+	}
+	a.Lock()
+	config := a.config
+	a.Unlock()
+	if config == nil {
+		return nil
+	}
+	method := bareMethodName(fullMethod)
+	if len(config.Include) != 0 && !matchAny(config.Include, method) || matchAny(config.Exclude, method) {
+		return nil
+	}
+	e := &auditEntry{
+		Time:    time.Now(),
+		Method:  fullMethod,
+		payload: config.Payload,
+		redact:  redact,
+	}
+	if e.payload {
+		e.Request = e.marshalPayload(req)
+	}
+	return e
+}
+
+func (e *auditEntry) marshalPayload(o interface{}) json.RawMessage {
+	if e.redact != nil {
+		o = e.redact(o)
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		glog.Errorf("Can't marshal %T for the audit log: %v", o, err)
+		return nil
+	}
+	return data
+}
+
+// finish completes the audit entry and writes it to the file.
+// It does nothing if the entry is nil.
+func (a *AuditLog) finish(e *auditEntry, resp interface{}, err error) {
+	if e == nil {
+		return
+	}
+	e.Lock()
+	e.Duration = time.Since(e.Time).Seconds()
+	e.Code = grpc.Code(err).String()
+	if err != nil {
+		e.Error = err.Error()
+	} else if e.payload {
+		e.Response = e.marshalPayload(resp)
+	}
+	data, marshalErr := json.Marshal(e)
+	e.Unlock()
+	if marshalErr != nil {
+		glog.Errorf("Can't marshal the audit log entry for %s: %v", e.Method, marshalErr)
+		return
+	}
+	a.write(append(data, '\n'))
+}
+
+func (a *AuditLog) write(data []byte) {
+	a.Lock()
+	defer a.Unlock()
+	if a.config == nil {
+		return
+	}
+	if a.file != nil && a.config.MaxSize > 0 && a.size > 0 && a.size+int64(len(data)) > a.config.MaxSize {
+		a.rotateNonLocked()
+	}
+	if a.file == nil {
+		if err := a.openNonLocked(); err != nil {
+			glog.Errorf("Can't open the audit log: %v", err)
+			return
+		}
+	}
+	n, err := a.file.Write(data)
+	a.size += int64(n)
+	if err != nil {
+		glog.Errorf("Error writing the audit log: %v", err)
+	}
+}
+
+func (a *AuditLog) openNonLocked() error {
+	f, err := os.OpenFile(a.config.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.file = f
+	a.size = fi.Size()
+	return nil
+}
+
+// rotateNonLocked renames audit.log to audit.log.1, audit.log.1 to
+// audit.log.2 and so on, removing the oldest file.
+func (a *AuditLog) rotateNonLocked() {
+	a.closeNonLocked()
+	p := a.config.Path
+	for n := a.config.MaxFiles - 1; n > 0; n-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", p, n), fmt.Sprintf("%s.%d", p, n+1)); err != nil && !os.IsNotExist(err) {
+			glog.Errorf("Error rotating the audit log: %v", err)
+		}
+	}
+	var err error
+	if a.config.MaxFiles > 0 {
+		err = os.Rename(p, p+".1")
+	} else {
+		err = os.Remove(p)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		glog.Errorf("Error rotating the audit log: %v", err)
+	}
+}
+
+type auditEntryKey struct{}
+
+func withAuditEntry(ctx context.Context, e *auditEntry) context.Context {
+	if e == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, auditEntryKey{}, e)
+}
+
+// auditRuntimeCall records the runtime and the ids of the objects
+// of a request passed to it in the audit entry of the incoming
+// request, if any. The ids are taken from the request and, if the
+// call succeeded, from the response, so the ids of the newly created
+// pod sandboxes and containers are recorded too.
+func auditRuntimeCall(ctx context.Context, runtimeId string, req, resp CRIObject, err error) {
+	e, ok := ctx.Value(auditEntryKey{}).(*auditEntry)
+	if !ok {
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+	e.addRuntime(runtimeName(runtimeId))
+	objs := []CRIObject{req}
+	if err == nil {
+		objs = append(objs, resp)
+	}
+	for _, o := range objs {
+		if o, ok := o.(IdObject); ok {
+			e.addId(o.Id())
+		}
+		if o, ok := o.(PodSandboxIdObject); ok {
+			e.addId(o.PodSandboxId())
+		}
+		if o, ok := o.(ContainerIdObject); ok {
+			e.addId(o.ContainerId())
+		}
+		if o, ok := o.(IdFilterObject); ok {
+			e.addId(o.IdFilter())
+		}
+		if o, ok := o.(PodSandboxIdFilterObject); ok {
+			e.addId(o.PodSandboxIdFilter())
+		}
+	}
+}
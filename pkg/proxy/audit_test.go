@@ -0,0 +1,112 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	runtimeapi "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_9"
+)
+
+// readAuditLog returns the entries of the audit log file
+func readAuditLog(t *testing.T, path string) []*auditEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("can't open the audit log: %v", err)
+	}
+	defer f.Close()
+	var entries []*auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("bad audit log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, &e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("error reading the audit log: %v", err)
+	}
+	return entries
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "criproxy-audit")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	const maxSize = 1000
+	a := NewAuditLog(&AuditConfig{
+		Path:     path,
+		MaxSize:  maxSize,
+		MaxFiles: 2,
+		Exclude:  []string{"Status"},
+	})
+	defer a.Close()
+
+	for i := 0; i < 30; i++ {
+		e := a.start("/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{}, nil)
+		e.addId(fmt.Sprintf("pod-%d", i))
+		a.finish(e, &runtimeapi.StopPodSandboxResponse{}, nil)
+		if e := a.start("/runtime.RuntimeService/Status", &runtimeapi.StatusRequest{}, nil); e != nil {
+			t.Fatalf("excluded method was logged")
+		}
+	}
+
+	var ids []string
+	for _, name := range []string{"audit.log.2", "audit.log.1", "audit.log"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Stat(): %v", err)
+		}
+		if fi.Size() > maxSize {
+			t.Errorf("%s is too large: %d bytes", name, fi.Size())
+		}
+		for _, e := range readAuditLog(t, filepath.Join(dir, name)) {
+			if e.Code != "OK" || e.Request != nil || len(e.Ids) != 1 {
+				t.Errorf("bad audit log entry: %#v", e)
+			}
+			ids = append(ids, e.Ids...)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("the oldest audit log file wasn't removed")
+	}
+	// the rotated files keep the latest entries in order
+	if len(ids) == 0 || ids[len(ids)-1] != "pod-29" {
+		t.Fatalf("bad ids in the audit log: %v", ids)
+	}
+	for n, id := range ids {
+		if expected := fmt.Sprintf("pod-%d", 30-len(ids)+n); id != expected {
+			t.Errorf("bad id %q instead of %q", id, expected)
+		}
+	}
+
+	// nil config disables the audit log
+	a.Reload(nil)
+	if e := a.start("/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{}, nil); e != nil {
+		t.Errorf("disabled audit log returned an entry")
+	}
+}
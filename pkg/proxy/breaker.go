@@ -0,0 +1,188 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type breakerState int
+
+const (
+	// breakerDisabled means that the runtime has no circuit breaker
+	breakerDisabled = breakerState(iota)
+	// breakerClosed means that the requests are passed to the runtime
+	breakerClosed
+	// breakerOpen means that the runtime is skipped until the end
+	// of the cooldown period
+	breakerOpen
+	// breakerHalfOpen means that the cooldown period has ended
+	// and a single trial request is passed to the runtime. Its
+	// failure opens the breaker again, while success closes it
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerDisabled:
+		return ""
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "<unknown>"
+	}
+}
+
+// breakerOpenError is returned for the requests to a runtime which
+// circuit breaker is open. It's not related to the state of the
+// connection, so the connection is not reestablished because of it.
+type breakerOpenError struct {
+	runtime  string
+	failures int
+	retryIn  time.Duration
+}
+
+func (e *breakerOpenError) Error() string {
+	if e.retryIn <= 0 {
+		return fmt.Sprintf("runtime %q is skipped after %d consecutive failures while a trial request is in progress", e.runtime, e.failures)
+	}
+	return fmt.Sprintf("runtime %q is skipped after %d consecutive failures, retrying in %v", e.runtime, e.failures, e.retryIn)
+}
+
+// circuitBreaker makes the proxy skip a runtime that keeps
+// failing or timing out for a cooldown period.
+type circuitBreaker struct {
+	sync.Mutex
+	name      string
+	threshold int
+	cooldown  time.Duration
+	// failures is the number of the consecutive failed requests
+	failures  int
+	openUntil time.Time
+	// probing is true while the trial request
+	// of the half-open breaker is in progress
+	probing bool
+}
+
+func newCircuitBreaker(runtimeConfig RuntimeConfig) *circuitBreaker {
+	return &circuitBreaker{
+		name:      runtimeName(runtimeConfig.Id),
+		threshold: runtimeConfig.BreakerThreshold,
+		cooldown:  runtimeConfig.BreakerCooldown.Duration,
+	}
+}
+
+// isBreakerFailure returns true if the error returned by the runtime
+// indicates that it's misbehaving. The errors like NotFound, which
+// are the part of normal operation, don't count.
+func isBreakerFailure(err error) bool {
+	switch grpc.Code(err) {
+	case codes.DeadlineExceeded, codes.Unavailable, codes.Internal, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *circuitBreaker) stateNonLocked(now time.Time) breakerState {
+	switch {
+	case b.threshold <= 0:
+		return breakerDisabled
+	case b.failures < b.threshold:
+		return breakerClosed
+	case now.Before(b.openUntil):
+		return breakerOpen
+	default:
+		return breakerHalfOpen
+	}
+}
+
+func (b *circuitBreaker) state() breakerState {
+	b.Lock()
+	defer b.Unlock()
+	return b.stateNonLocked(time.Now())
+}
+
+// allow returns breakerOpenError if the breaker is open
+// and nil otherwise. The half-open breaker only allows a single
+// trial request until its result is recorded.
+func (b *circuitBreaker) allow() error {
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now()
+	switch b.stateNonLocked(now) {
+	case breakerHalfOpen:
+		if b.probing {
+			return &breakerOpenError{runtime: b.name, failures: b.failures}
+		}
+		b.probing = true
+		return nil
+	case breakerOpen:
+		return &breakerOpenError{
+			runtime:  b.name,
+			failures: b.failures,
+			retryIn:  b.openUntil.Sub(now).Round(time.Millisecond),
+		}
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker according to the result of
+// a request to the runtime.
+func (b *circuitBreaker) record(err error) {
+	b.Lock()
+	defer b.Unlock()
+	b.probing = false
+	now := time.Now()
+	state := b.stateNonLocked(now)
+	switch {
+	case state == breakerDisabled:
+		return
+	case !isBreakerFailure(err):
+		if state != breakerClosed {
+			glog.Infof("Circuit breaker for runtime %q is closed", b.name)
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold && state != breakerOpen {
+		b.openUntil = now.Add(b.cooldown)
+		glog.Warningf("Circuit breaker for runtime %q is open after %d consecutive failures (last error: %v), skipping the runtime for %v", b.name, b.failures, err, b.cooldown)
+	}
+}
+
+// abandon is called instead of record when the result of the
+// request allowed by the breaker tells nothing about the runtime,
+// e.g. when the incoming request is cancelled. It lets another
+// request through if the breaker is half-open.
+func (b *circuitBreaker) abandon() {
+	b.Lock()
+	defer b.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(RuntimeConfig{
+		Id:               "alt",
+		BreakerThreshold: 2,
+		BreakerCooldown:  Duration{100 * time.Millisecond},
+	})
+	verifyState := func(expected breakerState) {
+		if state := b.state(); state != expected {
+			t.Errorf("bad breaker state %v instead of %v", state, expected)
+		}
+		if expected == breakerHalfOpen {
+			// allow() would start a trial request
+			return
+		}
+		err := b.allow()
+		if expected == breakerOpen && err == nil {
+			t.Errorf("the requests are allowed by the open breaker")
+		} else if expected != breakerOpen && err != nil {
+			t.Errorf("the requests are not allowed by the %v breaker: %v", expected, err)
+		}
+	}
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	notFound := status.Error(codes.NotFound, "not found")
+
+	verifyState(breakerClosed)
+	b.record(unavailable)
+	verifyState(breakerClosed)
+	// the errors that are a part of normal operation
+	// reset the failure count
+	b.record(notFound)
+	b.record(unavailable)
+	verifyState(breakerClosed)
+	b.record(status.Error(codes.DeadlineExceeded, "timed out"))
+	verifyState(breakerOpen)
+	if err, ok := b.allow().(*breakerOpenError); !ok || err.runtime != "alt" || err.failures != 2 {
+		t.Errorf("bad error: %#v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	verifyState(breakerHalfOpen)
+	verifyTrialRequest(t, b)
+	// a single failure opens the breaker again
+	b.record(unavailable)
+	verifyState(breakerOpen)
+
+	time.Sleep(150 * time.Millisecond)
+	verifyState(breakerHalfOpen)
+	verifyTrialRequest(t, b)
+	// the abandoned trial request lets another one through
+	b.abandon()
+	verifyTrialRequest(t, b)
+	b.record(nil)
+	verifyState(breakerClosed)
+
+	disabled := newCircuitBreaker(RuntimeConfig{Id: "alt"})
+	for i := 0; i < 10; i++ {
+		disabled.record(unavailable)
+	}
+	if state := disabled.state(); state != breakerDisabled {
+		t.Errorf("bad state of the disabled breaker: %v", state)
+	}
+	if err := disabled.allow(); err != nil {
+		t.Errorf("the disabled breaker doesn't allow the requests: %v", err)
+	}
+}
+
+// verifyTrialRequest verifies that the half-open breaker lets
+// exactly one of the concurrent requests through
+func verifyTrialRequest(t *testing.T, b *circuitBreaker) {
+	const numRequests = 10
+	var wg sync.WaitGroup
+	errs := make([]error, numRequests)
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = b.allow()
+		}(i)
+	}
+	wg.Wait()
+	allowed := 0
+	for _, err := range errs {
+		switch err.(type) {
+		case nil:
+			allowed++
+		case *breakerOpenError:
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if allowed != 1 {
+		t.Errorf("the half-open breaker allowed %d requests instead of 1", allowed)
+	}
+	if state := b.state(); state != breakerHalfOpen {
+		t.Errorf("bad breaker state %v during the trial request", state)
+	}
+}
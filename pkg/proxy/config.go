@@ -0,0 +1,722 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/Mirantis/criproxy/pkg/utils"
+)
+
+const (
+	// DefaultConnectionTimeout is the connection timeout that's used
+	// for runtimes that don't specify it explicitly.
+	DefaultConnectionTimeout = 30 * time.Second
+	// DefaultMaxBackoff is the maximum delay between the connection
+	// attempts that's used for runtimes that don't specify it
+	// explicitly.
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultBreakerCooldown is the time during which a runtime
+	// is skipped after its circuit breaker opens, unless it's
+	// specified explicitly.
+	DefaultBreakerCooldown = 30 * time.Second
+	// IdMappingPrefix denotes the default id mapping mode in which
+	// the ids of the pod sandboxes and containers of the secondary
+	// runtimes are prefixed with "runtime-id__".
+	IdMappingPrefix = "prefix"
+	// IdMappingTable denotes the id mapping mode in which the
+	// ids are passed through unchanged and the runtimes that own
+	// them are recorded in a persistent table.
+	IdMappingTable = "table"
+	// StatusPolicyIgnore denotes the default status policy in
+	// which the runtime conditions of a secondary runtime are
+	// reported by Status but don't affect the readiness of the node.
+	StatusPolicyIgnore = "ignore"
+	// StatusPolicyRequired denotes the status policy in which
+	// the secondary runtime must be ready for the node to be ready.
+	StatusPolicyRequired = "required"
+	// ImageGCPolicyAllow denotes the default image GC policy in
+	// which the images of the runtime can be removed.
+	ImageGCPolicyAllow = "allow"
+	// ImageGCPolicyNever denotes the image GC policy in which
+	// RemoveImage requests for the images of the runtime are
+	// rejected.
+	ImageGCPolicyNever = "never"
+	// ImageGCPolicySharedFs denotes the image GC policy in which
+	// the images of a secondary runtime can only be removed if the
+	// runtime stores them on the image filesystem of the primary
+	// runtime, which kubelet checks to decide on image GC.
+	ImageGCPolicySharedFs = "shared-fs"
+	// DefaultAuditMaxSize is the size of the audit log file after
+	// which the file is rotated, unless it's specified explicitly.
+	DefaultAuditMaxSize = 100 << 20
+	// DefaultAuditMaxFiles is the number of the rotated audit log
+	// files that are kept, unless it's specified explicitly.
+	DefaultAuditMaxFiles = 5
+	// DefaultIdTablePath is the path to the id table that's used
+	// if the path isn't specified in the config.
+	DefaultIdTablePath = "/var/lib/criproxy/ids.json"
+)
+
+// Duration is a time.Duration that's represented as a string
+// such as "30s" or "1m30s" in the config file.
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON implements MarshalJSON method of json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements UnmarshalJSON method of json.Unmarshaler interface.
+// Besides strings, it accepts plain numbers which denote nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return fmt.Errorf("bad duration %s", string(data))
+		}
+		d.Duration = time.Duration(ns)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("bad duration %q: %v", s, err)
+	}
+	d.Duration = v
+	return nil
+}
+
+// TlsConfig specifies the certificates used for tls:// connections.
+type TlsConfig struct {
+	// CaFile is the path to the PEM file with the CA certificates
+	// used to verify the certificate of the peer. For the servers,
+	// setting it enables client certificate authentication. If
+	// it's not set for the clients, the system CAs are used.
+	CaFile string `json:"caFile,omitempty"`
+	// CertFile is the path to the PEM file with the certificate
+	// to present to the peer. For the clients, it's the client
+	// certificate.
+	CertFile string `json:"certFile,omitempty"`
+	// KeyFile is the path to the PEM file with the private key
+	// for CertFile.
+	KeyFile string `json:"keyFile,omitempty"`
+	// ServerName is the name used to verify the certificate of
+	// the server. Defaults to the host part of the address.
+	ServerName string `json:"serverName,omitempty"`
+}
+
+func (tc *TlsConfig) validate() error {
+	if (tc.CertFile == "") != (tc.KeyFile == "") {
+		return errors.New("TLS cert and key files must be specified together")
+	}
+	return nil
+}
+
+func (tc *TlsConfig) baseConfig() (*tls.Config, error) {
+	// gRPC uses HTTP/2
+	config := &tls.Config{NextProtos: []string{"h2"}}
+	if tc.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load TLS cert %q / key %q: %v", tc.CertFile, tc.KeyFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func (tc *TlsConfig) loadCa() (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(tc.CaFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %q", tc.CaFile)
+	}
+	return pool, nil
+}
+
+// ClientConfig makes a client-side tls.Config. The certificates
+// are loaded from the files each time it's called.
+func (tc *TlsConfig) ClientConfig() (*tls.Config, error) {
+	config, err := tc.baseConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.ServerName = tc.ServerName
+	if tc.CaFile != "" {
+		if config.RootCAs, err = tc.loadCa(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// ServerConfig makes a server-side tls.Config. If CaFile is set,
+// the clients are required to present the certificates signed by
+// one of the CAs listed in it.
+func (tc *TlsConfig) ServerConfig() (*tls.Config, error) {
+	if tc.CertFile == "" {
+		return nil, errors.New("TLS server needs cert and key files")
+	}
+	config, err := tc.baseConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tc.CaFile != "" {
+		if config.ClientCAs, err = tc.loadCa(); err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// RuntimeConfig describes a CRI runtime the proxy connects to.
+type RuntimeConfig struct {
+	// Id is the id of the runtime. It must be empty for the
+	// primary runtime and non-empty for every other one.
+	Id string `json:"id,omitempty"`
+	// Socket is the address of the runtime, which is either the
+	// path to its unix socket or a unix://, tcp:// or tls:// URL,
+	// e.g. tls://10.0.0.2:10500
+	Socket string `json:"socket"`
+	// Tls specifies the certificates for tls:// address.
+	Tls *TlsConfig `json:"tls,omitempty"`
+	// ConnectionTimeout is the timeout for connecting to the runtime.
+	// It's used as the default for ProbeTimeout.
+	ConnectionTimeout Duration `json:"connectionTimeout,omitempty"`
+	// DialTimeout is the timeout for establishing the connection
+	// to the runtime's socket, including the TLS handshake.
+	// Defaults to 5s.
+	DialTimeout Duration `json:"dialTimeout,omitempty"`
+	// ProbeTimeout is the timeout for the Version request that's
+	// used to check the connection to the runtime and to detect
+	// its CRI version. Defaults to ConnectionTimeout.
+	ProbeTimeout Duration `json:"probeTimeout,omitempty"`
+	// InitialBackoff is the delay after the first failed connection
+	// attempt. The delay is doubled after each subsequent failed
+	// attempt up to MaxBackoff. Defaults to 500ms.
+	InitialBackoff Duration `json:"initialBackoff,omitempty"`
+	// MaxBackoff is the maximum delay between the connection
+	// attempts. The connection that's lost earlier than MaxBackoff
+	// after it was established is also reestablished with a delay,
+	// so that a crash-looping runtime is not probed too often.
+	// Defaults to 30s.
+	MaxBackoff Duration `json:"maxBackoff,omitempty"`
+	// BackoffJitter is the fraction of the delay between the
+	// connection attempts by which it's randomly increased or
+	// decreased, from 0 (no jitter, the default) to 1.
+	BackoffJitter float64 `json:"backoffJitter,omitempty"`
+	// RequestTimeout is the timeout for the requests to the
+	// runtime, which is used for the methods that don't have
+	// a timeout in MethodTimeouts of either the runtime or the
+	// config. 0 means no timeout besides the deadline set by
+	// kubelet.
+	RequestTimeout Duration `json:"requestTimeout,omitempty"`
+	// MethodTimeouts maps the CRI method names without the service,
+	// e.g. "Status" or "PullImage", to the timeouts for the
+	// corresponding requests to the runtime. These take precedence
+	// over MethodTimeouts of the config.
+	MethodTimeouts map[string]Duration `json:"methodTimeouts,omitempty"`
+	// BreakerThreshold enables the circuit breaker for the runtime.
+	// After the specified number of consecutive requests to the
+	// runtime fail with Unavailable, DeadlineExceeded, Internal or
+	// ResourceExhausted errors, the runtime is skipped by List*
+	// and UpdateRuntimeConfig requests and the requests targeted
+	// at it fail immediately during BreakerCooldown.
+	BreakerThreshold int `json:"breakerThreshold,omitempty"`
+	// BreakerCooldown is the time during which the runtime is
+	// skipped after its circuit breaker opens. Defaults to 30s
+	// if BreakerThreshold is set.
+	BreakerCooldown Duration `json:"breakerCooldown,omitempty"`
+	// StatusPolicy specifies how the runtime conditions reported
+	// by a secondary runtime affect the result of Status requests,
+	// either "ignore" (the default) or "required". In "required"
+	// mode, if the runtime is not RuntimeReady or NetworkReady or
+	// doesn't respond, the corresponding condition of the primary
+	// runtime, which is used by kubelet, is reported as false.
+	StatusPolicy string `json:"statusPolicy,omitempty"`
+	// ImageGCPolicy specifies whether the images of the runtime
+	// can be removed, which is normally done by kubelet's image GC:
+	// "allow" (the default), "never" or "shared-fs". In "shared-fs"
+	// mode, which can't be used for the primary runtime, the images
+	// can only be removed if the runtime reported the image
+	// filesystem of the primary runtime in response to the last
+	// ImageFsInfo request.
+	ImageGCPolicy string `json:"imageGCPolicy,omitempty"`
+	// StreamUrl is the base URL of the streaming server of the
+	// runtime which is used to fix up relative URLs returned by
+	// Exec, Attach and PortForward. If it's not set for a
+	// secondary runtime, the StreamUrl of the primary runtime
+	// is used.
+	StreamUrl string `json:"streamUrl,omitempty"`
+	// ImagePrefixes lists the image name prefixes that denote
+	// the images handled by the runtime, e.g. "virtlet.cloud"
+	// for "virtlet.cloud/image-service/cirros". The first prefix
+	// is used for the image names returned by the runtime.
+	// Defaults to the runtime id.
+	ImagePrefixes []string `json:"imagePrefixes,omitempty"`
+	// AnnotationValues lists the values of
+	// kubernetes.io/target-runtime pod annotation that make
+	// RunPodSandbox requests go to this runtime. Defaults to the
+	// runtime id.
+	AnnotationValues []string `json:"annotationValues,omitempty"`
+	// RuntimeHandlers lists the values of RuntimeHandler field of
+	// RunPodSandbox requests (CRI 1.12+, set by kubelet according
+	// to the RuntimeClass of the pod) that make the pods run on
+	// this runtime. A matching runtime handler takes precedence
+	// over kubernetes.io/target-runtime annotation.
+	RuntimeHandlers []string `json:"runtimeHandlers,omitempty"`
+	// ForwardRuntimeHandler makes the proxy pass the matching
+	// RuntimeHandler value on to the runtime. By default, the
+	// value is cleared so that the runtime uses its default
+	// handler.
+	ForwardRuntimeHandler bool `json:"forwardRuntimeHandler,omitempty"`
+}
+
+func (rc *RuntimeConfig) isPrimary() bool {
+	return rc.Id == ""
+}
+
+func (rc *RuntimeConfig) applyDefaults() {
+	if rc.ConnectionTimeout.Duration == 0 {
+		rc.ConnectionTimeout.Duration = DefaultConnectionTimeout
+	}
+	if rc.DialTimeout.Duration == 0 {
+		rc.DialTimeout.Duration = utils.DefaultDialTimeout
+	}
+	if rc.ProbeTimeout.Duration == 0 {
+		rc.ProbeTimeout.Duration = rc.ConnectionTimeout.Duration
+	}
+	if rc.InitialBackoff.Duration == 0 {
+		rc.InitialBackoff.Duration = utils.DefaultInitialBackoff
+	}
+	if rc.MaxBackoff.Duration == 0 {
+		rc.MaxBackoff.Duration = DefaultMaxBackoff
+	}
+	if rc.BreakerThreshold > 0 && rc.BreakerCooldown.Duration == 0 {
+		rc.BreakerCooldown.Duration = DefaultBreakerCooldown
+	}
+	if rc.isPrimary() {
+		return
+	}
+	if len(rc.ImagePrefixes) == 0 {
+		rc.ImagePrefixes = []string{rc.Id}
+	}
+	if len(rc.AnnotationValues) == 0 {
+		rc.AnnotationValues = []string{rc.Id}
+	}
+}
+
+func (rc *RuntimeConfig) validate() error {
+	if rc.Socket == "" {
+		return errors.New("no socket specified")
+	}
+	ep, err := utils.ParseEndpoint(rc.Socket)
+	if err != nil {
+		return err
+	}
+	if rc.Tls != nil {
+		if !ep.Tls {
+			return fmt.Errorf("TLS config specified for non-TLS address %q", rc.Socket)
+		}
+		if err := rc.Tls.validate(); err != nil {
+			return err
+		}
+	}
+	for _, item := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"connection timeout", rc.ConnectionTimeout.Duration},
+		{"dial timeout", rc.DialTimeout.Duration},
+		{"probe timeout", rc.ProbeTimeout.Duration},
+		{"initial backoff", rc.InitialBackoff.Duration},
+		{"max backoff", rc.MaxBackoff.Duration},
+		{"request timeout", rc.RequestTimeout.Duration},
+		{"breaker cooldown", rc.BreakerCooldown.Duration},
+	} {
+		if item.value < 0 {
+			return fmt.Errorf("negative %s %v", item.name, item.value)
+		}
+	}
+	if err := validateMethodTimeouts(rc.MethodTimeouts); err != nil {
+		return err
+	}
+	if rc.BreakerThreshold < 0 {
+		return fmt.Errorf("negative breaker threshold %d", rc.BreakerThreshold)
+	}
+	if rc.MaxBackoff.Duration != 0 && rc.MaxBackoff.Duration < rc.InitialBackoff.Duration {
+		return fmt.Errorf("max backoff %v is less than initial backoff %v", rc.MaxBackoff.Duration, rc.InitialBackoff.Duration)
+	}
+	if rc.BackoffJitter < 0 || rc.BackoffJitter > 1 {
+		return fmt.Errorf("bad backoff jitter %v (must be between 0 and 1)", rc.BackoffJitter)
+	}
+	if rc.StreamUrl != "" {
+		if _, err := url.Parse(rc.StreamUrl); err != nil {
+			return fmt.Errorf("invalid stream url %q: %v", rc.StreamUrl, err)
+		}
+	}
+	switch rc.StatusPolicy {
+	case "", StatusPolicyIgnore, StatusPolicyRequired:
+	default:
+		return fmt.Errorf("bad status policy %q", rc.StatusPolicy)
+	}
+	switch rc.ImageGCPolicy {
+	case "", ImageGCPolicyAllow, ImageGCPolicyNever, ImageGCPolicySharedFs:
+	default:
+		return fmt.Errorf("bad image GC policy %q", rc.ImageGCPolicy)
+	}
+	if rc.isPrimary() {
+		if rc.StatusPolicy != "" {
+			return errors.New("the primary runtime can't have a status policy")
+		}
+		if rc.BreakerThreshold != 0 {
+			return errors.New("the primary runtime can't have a circuit breaker")
+		}
+		if rc.ImageGCPolicy == ImageGCPolicySharedFs {
+			return fmt.Errorf("the primary runtime can't have %q image GC policy", ImageGCPolicySharedFs)
+		}
+		if len(rc.ImagePrefixes) != 0 {
+			return errors.New("the primary runtime can't have image prefixes")
+		}
+		if len(rc.AnnotationValues) != 0 {
+			return errors.New("the primary runtime can't have annotation values")
+		}
+	}
+	for _, prefix := range rc.ImagePrefixes {
+		if prefix == "" || strings.HasSuffix(prefix, "/") {
+			return fmt.Errorf("bad image prefix %q", prefix)
+		}
+	}
+	for _, value := range rc.AnnotationValues {
+		if value == "" {
+			return errors.New("empty annotation value")
+		}
+	}
+	for _, handler := range rc.RuntimeHandlers {
+		if handler == "" {
+			return errors.New("empty runtime handler")
+		}
+	}
+	return nil
+}
+
+// withoutTimeouts returns a copy of the runtime config without the
+// request timeouts, which can be changed without reconnecting to
+// the runtime.
+func (rc RuntimeConfig) withoutTimeouts() RuntimeConfig {
+	rc.RequestTimeout = Duration{}
+	rc.MethodTimeouts = nil
+	return rc
+}
+
+func validateMethodTimeouts(timeouts map[string]Duration) error {
+	for method, d := range timeouts {
+		if !isKnownMethod(method) {
+			return fmt.Errorf("timeout specified for unknown method %q", method)
+		}
+		if d.Duration < 0 {
+			return fmt.Errorf("negative timeout %v for method %q", d.Duration, method)
+		}
+	}
+	return nil
+}
+
+// PodRoutingRule makes the pods that match all of the specified
+// conditions run on the specified runtime.
+type PodRoutingRule struct {
+	// Runtime is the id of the runtime (empty for the primary one).
+	Runtime string `json:"runtime,omitempty"`
+	// Namespaces lists the namespaces of the pods.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// LabelSelector is a label selector for the pods,
+	// e.g. "app=vm,tier in (db,cache)".
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// ImageRoutingRule makes the images with the names matching the
+// regular expression handled by the specified runtime. Such images
+// are not renamed.
+type ImageRoutingRule struct {
+	// Runtime is the id of the runtime (empty for the primary one).
+	Runtime string `json:"runtime,omitempty"`
+	// Regexp is the regular expression for the image names.
+	Regexp string `json:"regexp"`
+}
+
+// RoutingConfig specifies the routing rules that are used besides
+// the runtime handlers, target runtime annotation and image
+// prefixes. The rules are tried in order and the first matching
+// rule wins.
+type RoutingConfig struct {
+	// Pods lists the rules for choosing the runtimes for the pods
+	// that don't have a matching runtime handler or the target
+	// runtime annotation.
+	Pods []PodRoutingRule `json:"pods,omitempty"`
+	// Images lists the rules for choosing the runtimes for the
+	// images. These take precedence over the image prefixes.
+	Images []ImageRoutingRule `json:"images,omitempty"`
+}
+
+// Config denotes the configuration of CRI proxy.
+type Config struct {
+	// Runtimes lists the runtimes to connect to. The first one
+	// is the primary runtime.
+	Runtimes []RuntimeConfig `json:"runtimes"`
+	// Routing specifies additional routing rules.
+	Routing RoutingConfig `json:"routing,omitempty"`
+	// IdMapping specifies how the ids of the pod sandboxes and
+	// containers of the secondary runtimes are mapped, either
+	// "prefix" (the default) or "table".
+	IdMapping string `json:"idMapping,omitempty"`
+	// IdTablePath is the path to the JSON file that holds the id
+	// table in "table" id mapping mode.
+	IdTablePath string `json:"idTablePath,omitempty"`
+	// DiscoverOwners enables the owner discovery. If the primary
+	// runtime doesn't know about a pod sandbox or a container with
+	// an id that's not mapped to a secondary runtime, the proxy
+	// asks the secondary runtimes about it and retries the request
+	// on the runtime that has it.
+	DiscoverOwners bool `json:"discoverOwners,omitempty"`
+	// MethodTimeouts maps the CRI method names without the service,
+	// e.g. "ListContainers", to the timeouts for the corresponding
+	// requests to every runtime. They take precedence over
+	// RequestTimeout of the runtimes.
+	MethodTimeouts map[string]Duration `json:"methodTimeouts,omitempty"`
+	// Audit enables the audit log.
+	Audit *AuditConfig `json:"audit,omitempty"`
+	// RedactEnv lists the patterns for the names of the container
+	// environment variables which values are replaced with
+	// "<redacted>" in the logs and the audit log, in addition to
+	// DefaultRedactEnv. The patterns use path.Match syntax and
+	// are matched case-insensitively.
+	RedactEnv []string `json:"redactEnv,omitempty"`
+}
+
+// AuditConfig specifies the settings of the audit log, which
+// contains a JSON line for each CRI request handled by the proxy.
+type AuditConfig struct {
+	// Path is the path to the audit log file.
+	Path string `json:"path"`
+	// MaxSize is the size of the file in bytes after which it's
+	// rotated. Defaults to 100 MiB.
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// MaxFiles is the number of the rotated files that are kept,
+	// e.g. audit.log.1 ... audit.log.5. Defaults to 5.
+	MaxFiles int `json:"maxFiles,omitempty"`
+	// Payload makes the requests and the responses included
+	// in the audit log.
+	Payload bool `json:"payload,omitempty"`
+	// Include lists the patterns for the CRI method names
+	// without the service, such as "RunPodSandbox" or "Remove*",
+	// that denote the methods to be logged. All the methods are
+	// logged if it's empty. The patterns use path.Match syntax.
+	Include []string `json:"include,omitempty"`
+	// Exclude lists the patterns for the CRI method names that
+	// should not be logged. It takes precedence over Include.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func (ac *AuditConfig) applyDefaults() {
+	if ac.MaxSize == 0 {
+		ac.MaxSize = DefaultAuditMaxSize
+	}
+	if ac.MaxFiles == 0 {
+		ac.MaxFiles = DefaultAuditMaxFiles
+	}
+}
+
+func (ac *AuditConfig) validate() error {
+	if ac.Path == "" {
+		return errors.New("no audit log path specified")
+	}
+	if ac.MaxSize < 0 {
+		return fmt.Errorf("negative audit log max size %d", ac.MaxSize)
+	}
+	if ac.MaxFiles < 0 {
+		return fmt.Errorf("negative audit log max files %d", ac.MaxFiles)
+	}
+	for _, pattern := range append(append([]string(nil), ac.Include...), ac.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad audit method pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) applyDefaults() {
+	for n := range c.Runtimes {
+		c.Runtimes[n].applyDefaults()
+	}
+	if c.Audit != nil {
+		c.Audit.applyDefaults()
+	}
+	if c.IdMapping == IdMappingTable && c.IdTablePath == "" {
+		c.IdTablePath = DefaultIdTablePath
+	}
+}
+
+// Validate checks the configuration for errors.
+func (c *Config) Validate() error {
+	if len(c.Runtimes) == 0 {
+		return errors.New("no runtimes specified")
+	}
+	if !c.Runtimes[0].isPrimary() {
+		return errors.New("the first runtime should be primary (no id)")
+	}
+	ids := make(map[string]bool)
+	imagePrefixes := make(map[string]string)
+	annotationValues := make(map[string]string)
+	runtimeHandlers := make(map[string]string)
+	for n, rc := range c.Runtimes {
+		if n > 0 && rc.isPrimary() {
+			return errors.New("only the first runtime should be primary (no id)")
+		}
+		if ids[rc.Id] {
+			return fmt.Errorf("duplicate runtime id %q", rc.Id)
+		}
+		ids[rc.Id] = true
+		if err := rc.validate(); err != nil {
+			return fmt.Errorf("runtime %q: %v", rc.Id, err)
+		}
+		for _, prefix := range rc.ImagePrefixes {
+			if otherId, found := imagePrefixes[prefix]; found {
+				return fmt.Errorf("image prefix %q is used by both %q and %q runtimes", prefix, otherId, rc.Id)
+			}
+			imagePrefixes[prefix] = rc.Id
+		}
+		for _, value := range rc.AnnotationValues {
+			if otherId, found := annotationValues[value]; found {
+				return fmt.Errorf("annotation value %q is used by both %q and %q runtimes", value, otherId, rc.Id)
+			}
+			annotationValues[value] = rc.Id
+		}
+		for _, handler := range rc.RuntimeHandlers {
+			if otherId, found := runtimeHandlers[handler]; found {
+				return fmt.Errorf("runtime handler %q is used by both %q and %q runtimes", handler, runtimeName(otherId), runtimeName(rc.Id))
+			}
+			runtimeHandlers[handler] = rc.Id
+		}
+	}
+	if err := validateMethodTimeouts(c.MethodTimeouts); err != nil {
+		return err
+	}
+	if c.Audit != nil {
+		if err := c.Audit.validate(); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range c.RedactEnv {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad redactEnv pattern %q: %v", pattern, err)
+		}
+	}
+	for _, rule := range c.Routing.Pods {
+		if !ids[rule.Runtime] {
+			return fmt.Errorf("pod routing rule refers to unknown runtime %q", rule.Runtime)
+		}
+		if len(rule.Namespaces) == 0 && rule.LabelSelector == "" {
+			return fmt.Errorf("pod routing rule for runtime %q has no conditions", runtimeName(rule.Runtime))
+		}
+	}
+	for _, rule := range c.Routing.Images {
+		if !ids[rule.Runtime] {
+			return fmt.Errorf("image routing rule refers to unknown runtime %q", rule.Runtime)
+		}
+	}
+	switch c.IdMapping {
+	case "", IdMappingPrefix:
+	case IdMappingTable:
+		if c.IdTablePath == "" {
+			return errors.New("no id table path specified")
+		}
+	default:
+		return fmt.Errorf("bad id mapping mode %q", c.IdMapping)
+	}
+	if _, err := NewRouter(c); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseConfig parses YAML or JSON configuration, sets the default
+// values for the missing fields and validates the result.
+func ParseConfig(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("error parsing the config: %v", err)
+	}
+	c.applyDefaults()
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("bad config: %v", err)
+	}
+	return &c, nil
+}
+
+// LoadConfig loads the configuration from the specified YAML or JSON file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read config file %q: %v", path, err)
+	}
+	c, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// ConfigFromConnectSpec makes a configuration from a comma-separated
+// list of runtime addresses, e.g.
+// /var/run/dockershim.sock,alt:/var/run/another.sock,vm:tcp://10.0.0.2:10500
+// Each address except for the first one must be prefixed with
+// the runtime id followed by a colon.
+func ConfigFromConnectSpec(spec string) (*Config, error) {
+	var c Config
+	for _, addr := range strings.Split(spec, ",") {
+		var rc RuntimeConfig
+		parts := strings.SplitN(addr, ":", 2)
+		if len(parts) == 2 && !strings.HasPrefix(parts[1], "//") {
+			rc.Id, rc.Socket = parts[0], parts[1]
+		} else {
+			rc.Socket = addr
+		}
+		c.Runtimes = append(c.Runtimes, rc)
+	}
+	c.applyDefaults()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,568 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mirantis/criproxy/pkg/utils"
+)
+
+func TestParseConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		expected *Config
+		error    string
+	}{
+		{
+			name: "yaml",
+			data: `
+runtimes:
+- socket: /var/run/dockershim.sock
+  streamUrl: http://10.0.0.1:11250/
+- id: virtlet.cloud
+  socket: /run/virtlet.sock
+  connectionTimeout: 1m30s
+  imagePrefixes: [virtlet.cloud, virtlet]
+  annotationValues: [virtlet.cloud, virtlet]
+  runtimeHandlers: [virtlet]
+  forwardRuntimeHandler: true
+`,
+			expected: &Config{
+				Runtimes: []RuntimeConfig{
+					{
+						Socket:            "/var/run/dockershim.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+						StreamUrl:         "http://10.0.0.1:11250/",
+					},
+					{
+						Id:                    "virtlet.cloud",
+						Socket:                "/run/virtlet.sock",
+						ConnectionTimeout:     Duration{90 * time.Second},
+						DialTimeout:           Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:          Duration{90 * time.Second},
+						InitialBackoff:        Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:            Duration{DefaultMaxBackoff},
+						ImagePrefixes:         []string{"virtlet.cloud", "virtlet"},
+						AnnotationValues:      []string{"virtlet.cloud", "virtlet"},
+						RuntimeHandlers:       []string{"virtlet"},
+						ForwardRuntimeHandler: true,
+					},
+				},
+			},
+		},
+		{
+			name: "json",
+			data: `{"runtimes": [{"socket": "/var/run/dockershim.sock"}, {"id": "alt", "socket": "/run/alt.sock", "connectionTimeout": "5s"}]}`,
+			expected: &Config{
+				Runtimes: []RuntimeConfig{
+					{
+						Socket:            "/var/run/dockershim.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+					},
+					{
+						Id:                "alt",
+						Socket:            "/run/alt.sock",
+						ConnectionTimeout: Duration{5 * time.Second},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{5 * time.Second},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+						ImagePrefixes:     []string{"alt"},
+						AnnotationValues:  []string{"alt"},
+					},
+				},
+			},
+		},
+		{
+			name: "id table",
+			data: "runtimes: [{socket: /run/foo.sock}]\nidMapping: table",
+			expected: &Config{
+				Runtimes: []RuntimeConfig{
+					{
+						Socket:            "/run/foo.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+					},
+				},
+				IdMapping:   IdMappingTable,
+				IdTablePath: DefaultIdTablePath,
+			},
+		},
+		{
+			name: "tcp and tls",
+			data: `
+runtimes:
+- socket: unix:///var/run/dockershim.sock
+- id: vm
+  socket: tls://10.0.0.2:10500
+  tls:
+    caFile: /etc/criproxy/ca.pem
+    certFile: /etc/criproxy/client.pem
+    keyFile: /etc/criproxy/client-key.pem
+    serverName: vm.example.com
+- id: alt
+  socket: tcp://10.0.0.3:10500
+`,
+			expected: &Config{
+				Runtimes: []RuntimeConfig{
+					{
+						Socket:            "unix:///var/run/dockershim.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+					},
+					{
+						Id:     "vm",
+						Socket: "tls://10.0.0.2:10500",
+						Tls: &TlsConfig{
+							CaFile:     "/etc/criproxy/ca.pem",
+							CertFile:   "/etc/criproxy/client.pem",
+							KeyFile:    "/etc/criproxy/client-key.pem",
+							ServerName: "vm.example.com",
+						},
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+						ImagePrefixes:     []string{"vm"},
+						AnnotationValues:  []string{"vm"},
+					},
+					{
+						Id:                "alt",
+						Socket:            "tcp://10.0.0.3:10500",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+						ImagePrefixes:     []string{"alt"},
+						AnnotationValues:  []string{"alt"},
+					},
+				},
+			},
+		},
+		{
+			name: "connection settings",
+			data: `
+runtimes:
+- socket: /var/run/dockershim.sock
+  dialTimeout: 1s
+  probeTimeout: 10s
+  initialBackoff: 100ms
+  maxBackoff: 1m
+  backoffJitter: 0.2
+`,
+			expected: &Config{
+				Runtimes: []RuntimeConfig{
+					{
+						Socket:            "/var/run/dockershim.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{time.Second},
+						ProbeTimeout:      Duration{10 * time.Second},
+						InitialBackoff:    Duration{100 * time.Millisecond},
+						MaxBackoff:        Duration{time.Minute},
+						BackoffJitter:     0.2,
+					},
+				},
+			},
+		},
+		{
+			name:  "negative dial timeout",
+			data:  "runtimes: [{socket: /run/foo.sock, dialTimeout: -1s}]",
+			error: "negative dial timeout",
+		},
+		{
+			name:  "max backoff less than initial backoff",
+			data:  "runtimes: [{socket: /run/foo.sock, initialBackoff: 1m, maxBackoff: 10s}]",
+			error: "max backoff 10s is less than initial backoff 1m0s",
+		},
+		{
+			name:  "bad backoff jitter",
+			data:  "runtimes: [{socket: /run/foo.sock, backoffJitter: 1.5}]",
+			error: "bad backoff jitter 1.5",
+		},
+		{
+			name: "request timeouts",
+			data: `
+runtimes:
+- socket: /var/run/dockershim.sock
+  methodTimeouts:
+    PullImage: 10m
+- id: alt
+  socket: /var/run/another.sock
+  requestTimeout: 30s
+methodTimeouts:
+  Status: 2s
+  ListContainers: 5s
+`,
+			expected: &Config{
+				Runtimes: []RuntimeConfig{
+					{
+						Socket:            "/var/run/dockershim.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+						MethodTimeouts: map[string]Duration{
+							"PullImage": {10 * time.Minute},
+						},
+					},
+					{
+						Id:                "alt",
+						Socket:            "/var/run/another.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+						RequestTimeout:    Duration{30 * time.Second},
+						ImagePrefixes:     []string{"alt"},
+						AnnotationValues:  []string{"alt"},
+					},
+				},
+				MethodTimeouts: map[string]Duration{
+					"Status":         {2 * time.Second},
+					"ListContainers": {5 * time.Second},
+				},
+			},
+		},
+		{
+			name: "circuit breaker",
+			data: `
+runtimes:
+- socket: /var/run/dockershim.sock
+- id: alt
+  socket: /var/run/another.sock
+  breakerThreshold: 5
+`,
+			expected: &Config{
+				Runtimes: []RuntimeConfig{
+					{
+						Socket:            "/var/run/dockershim.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+					},
+					{
+						Id:                "alt",
+						Socket:            "/var/run/another.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+						BreakerThreshold:  5,
+						BreakerCooldown:   Duration{DefaultBreakerCooldown},
+						ImagePrefixes:     []string{"alt"},
+						AnnotationValues:  []string{"alt"},
+					},
+				},
+			},
+		},
+		{
+			name: "audit log",
+			data: `
+runtimes:
+- socket: /var/run/dockershim.sock
+audit:
+  path: /var/log/criproxy/audit.log
+  payload: true
+  include: [RunPodSandbox, "Remove*"]
+  exclude: [RemoveImage]
+`,
+			expected: &Config{
+				Runtimes: []RuntimeConfig{
+					{
+						Socket:            "/var/run/dockershim.sock",
+						ConnectionTimeout: Duration{DefaultConnectionTimeout},
+						DialTimeout:       Duration{utils.DefaultDialTimeout},
+						ProbeTimeout:      Duration{DefaultConnectionTimeout},
+						InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+						MaxBackoff:        Duration{DefaultMaxBackoff},
+					},
+				},
+				Audit: &AuditConfig{
+					Path:     "/var/log/criproxy/audit.log",
+					MaxSize:  DefaultAuditMaxSize,
+					MaxFiles: DefaultAuditMaxFiles,
+					Payload:  true,
+					Include:  []string{"RunPodSandbox", "Remove*"},
+					Exclude:  []string{"RemoveImage"},
+				},
+			},
+		},
+		{
+			name:  "audit log without path",
+			data:  "runtimes: [{socket: /run/foo.sock}]\naudit: {payload: true}",
+			error: "no audit log path specified",
+		},
+		{
+			name:  "bad audit method pattern",
+			data:  "runtimes: [{socket: /run/foo.sock}]\naudit: {path: /tmp/audit.log, exclude: [\"List[\"]}",
+			error: "bad audit method pattern \"List[\": syntax error in pattern",
+		},
+		{
+			name:  "bad redactEnv pattern",
+			data:  "runtimes: [{socket: /run/foo.sock}]\nredactEnv: [\"DB_[\"]",
+			error: "bad redactEnv pattern \"DB_[\": syntax error in pattern",
+		},
+		{
+			name:  "bad status policy",
+			data:  "runtimes: [{socket: /run/foo.sock}, {id: alt, socket: /run/alt.sock, statusPolicy: sometimes}]",
+			error: "bad status policy \"sometimes\"",
+		},
+		{
+			name:  "status policy for the primary runtime",
+			data:  "runtimes: [{socket: /run/foo.sock, statusPolicy: required}]",
+			error: "the primary runtime can't have a status policy",
+		},
+		{
+			name:  "bad image GC policy",
+			data:  "runtimes: [{socket: /run/foo.sock}, {id: alt, socket: /run/alt.sock, imageGCPolicy: sometimes}]",
+			error: "bad image GC policy \"sometimes\"",
+		},
+		{
+			name:  "shared-fs image GC policy for the primary runtime",
+			data:  "runtimes: [{socket: /run/foo.sock, imageGCPolicy: shared-fs}]",
+			error: "the primary runtime can't have \"shared-fs\" image GC policy",
+		},
+		{
+			name:  "negative breaker threshold",
+			data:  "runtimes: [{socket: /run/foo.sock, breakerThreshold: -1}]",
+			error: "negative breaker threshold -1",
+		},
+		{
+			name:  "circuit breaker for the primary runtime",
+			data:  "runtimes: [{socket: /run/foo.sock, breakerThreshold: 5}]",
+			error: "the primary runtime can't have a circuit breaker",
+		},
+		{
+			name:  "timeout for unknown method",
+			data:  "runtimes: [{socket: /run/foo.sock}]\nmethodTimeouts: {PullImages: 1m}",
+			error: "timeout specified for unknown method \"PullImages\"",
+		},
+		{
+			name:  "negative method timeout",
+			data:  "runtimes: [{socket: /run/foo.sock, methodTimeouts: {Status: -1s}}]",
+			error: "negative timeout -1s for method \"Status\"",
+		},
+		{
+			name:  "negative request timeout",
+			data:  "runtimes: [{socket: /run/foo.sock, requestTimeout: -1s}]",
+			error: "negative request timeout",
+		},
+		{
+			name:  "bad address scheme",
+			data:  "runtimes: [{socket: http://10.0.0.2:10500}]",
+			error: "unsupported scheme \"http\"",
+		},
+		{
+			name:  "tcp address without port",
+			data:  "runtimes: [{socket: tcp://10.0.0.2}]",
+			error: "bad endpoint address \"tcp://10.0.0.2\"",
+		},
+		{
+			name:  "tls config for unix socket",
+			data:  "runtimes: [{socket: /run/foo.sock, tls: {caFile: /ca.pem}}]",
+			error: "TLS config specified for non-TLS address",
+		},
+		{
+			name:  "tls cert without key",
+			data:  "runtimes: [{socket: \"tls://10.0.0.2:10500\", tls: {certFile: /cert.pem}}]",
+			error: "TLS cert and key files must be specified together",
+		},
+		{
+			name:  "bad id mapping mode",
+			data:  "runtimes: [{socket: /run/foo.sock}]\nidMapping: hash",
+			error: "bad id mapping mode \"hash\"",
+		},
+		{
+			name:  "no runtimes",
+			data:  "runtimes: []",
+			error: "no runtimes specified",
+		},
+		{
+			name:  "bad duration",
+			data:  "runtimes: [{socket: /foo.sock, connectionTimeout: forever}]",
+			error: "bad duration",
+		},
+		{
+			name:  "no primary runtime",
+			data:  "runtimes: [{id: alt, socket: /run/alt.sock}]",
+			error: "the first runtime should be primary",
+		},
+		{
+			name:  "two primary runtimes",
+			data:  "runtimes: [{socket: /run/foo.sock}, {socket: /run/bar.sock}]",
+			error: "only the first runtime should be primary",
+		},
+		{
+			name:  "duplicate ids",
+			data:  "runtimes: [{socket: /run/foo.sock}, {id: alt, socket: /run/bar.sock}, {id: alt, socket: /run/baz.sock}]",
+			error: "duplicate runtime id \"alt\"",
+		},
+		{
+			name:  "no socket",
+			data:  "runtimes: [{socket: /run/foo.sock}, {id: alt}]",
+			error: "runtime \"alt\": no socket specified",
+		},
+		{
+			name:  "image prefix for the primary runtime",
+			data:  "runtimes: [{socket: /run/foo.sock, imagePrefixes: [foo]}]",
+			error: "the primary runtime can't have image prefixes",
+		},
+		{
+			name:  "duplicate image prefix",
+			data:  "runtimes: [{socket: /run/foo.sock}, {id: alt1, socket: /run/bar.sock, imagePrefixes: [alt]}, {id: alt2, socket: /run/baz.sock, imagePrefixes: [alt]}]",
+			error: "image prefix \"alt\" is used by both \"alt1\" and \"alt2\" runtimes",
+		},
+		{
+			name:  "duplicate runtime handler",
+			data:  "runtimes: [{socket: /run/foo.sock, runtimeHandlers: [runc]}, {id: alt, socket: /run/bar.sock, runtimeHandlers: [runc]}]",
+			error: "runtime handler \"runc\" is used by both \"primary\" and \"alt\" runtimes",
+		},
+		{
+			name:  "routing rule for unknown runtime",
+			data:  "runtimes: [{socket: /run/foo.sock}]\nrouting: {pods: [{runtime: alt, namespaces: [vms]}]}",
+			error: "pod routing rule refers to unknown runtime \"alt\"",
+		},
+		{
+			name:  "pod routing rule without conditions",
+			data:  "runtimes: [{socket: /run/foo.sock}, {id: alt, socket: /run/bar.sock}]\nrouting: {pods: [{runtime: alt}]}",
+			error: "pod routing rule for runtime \"alt\" has no conditions",
+		},
+		{
+			name:  "bad label selector",
+			data:  "runtimes: [{socket: /run/foo.sock}, {id: alt, socket: /run/bar.sock}]\nrouting: {pods: [{runtime: alt, labelSelector: \"a in b\"}]}",
+			error: "bad label selector",
+		},
+		{
+			name:  "bad image regexp",
+			data:  "runtimes: [{socket: /run/foo.sock}, {id: alt, socket: /run/bar.sock}]\nrouting: {images: [{runtime: alt, regexp: \"(\"}]}",
+			error: "bad image regexp",
+		},
+		{
+			name:  "duplicate annotation value",
+			data:  "runtimes: [{socket: /run/foo.sock}, {id: alt1, socket: /run/bar.sock, annotationValues: [alt]}, {id: alt2, socket: /run/baz.sock, annotationValues: [alt]}]",
+			error: "annotation value \"alt\" is used by both \"alt1\" and \"alt2\" runtimes",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(tc.data))
+			switch {
+			case tc.error == "" && err != nil:
+				t.Fatalf("ParseConfig(): %v", err)
+			case tc.error != "" && err == nil:
+				t.Fatalf("didn't get the expected error %q", tc.error)
+			case tc.error != "" && !strings.Contains(err.Error(), tc.error):
+				t.Fatalf("bad error message: %q instead of %q", err.Error(), tc.error)
+			case tc.error == "" && !reflect.DeepEqual(config, tc.expected):
+				t.Errorf("bad config:\n%s\ninstead of\n%s", dump(config), dump(tc.expected))
+			}
+		})
+	}
+}
+
+func TestConfigFromConnectSpec(t *testing.T) {
+	config, err := ConfigFromConnectSpec("/var/run/dockershim.sock,virtlet.cloud:/run/virtlet.sock")
+	if err != nil {
+		t.Fatalf("ConfigFromConnectSpec(): %v", err)
+	}
+	expected := &Config{
+		Runtimes: []RuntimeConfig{
+			{
+				Socket:            "/var/run/dockershim.sock",
+				ConnectionTimeout: Duration{DefaultConnectionTimeout},
+				DialTimeout:       Duration{utils.DefaultDialTimeout},
+				ProbeTimeout:      Duration{DefaultConnectionTimeout},
+				InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+				MaxBackoff:        Duration{DefaultMaxBackoff},
+			},
+			{
+				Id:                "virtlet.cloud",
+				Socket:            "/run/virtlet.sock",
+				ConnectionTimeout: Duration{DefaultConnectionTimeout},
+				DialTimeout:       Duration{utils.DefaultDialTimeout},
+				ProbeTimeout:      Duration{DefaultConnectionTimeout},
+				InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+				MaxBackoff:        Duration{DefaultMaxBackoff},
+				ImagePrefixes:     []string{"virtlet.cloud"},
+				AnnotationValues:  []string{"virtlet.cloud"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("bad config:\n%s\ninstead of\n%s", dump(config), dump(expected))
+	}
+
+	config, err = ConfigFromConnectSpec("tcp://10.0.0.1:10500,vm:tls://10.0.0.2:10500")
+	if err != nil {
+		t.Fatalf("ConfigFromConnectSpec(): %v", err)
+	}
+	expected = &Config{
+		Runtimes: []RuntimeConfig{
+			{
+				Socket:            "tcp://10.0.0.1:10500",
+				ConnectionTimeout: Duration{DefaultConnectionTimeout},
+				DialTimeout:       Duration{utils.DefaultDialTimeout},
+				ProbeTimeout:      Duration{DefaultConnectionTimeout},
+				InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+				MaxBackoff:        Duration{DefaultMaxBackoff},
+			},
+			{
+				Id:                "vm",
+				Socket:            "tls://10.0.0.2:10500",
+				ConnectionTimeout: Duration{DefaultConnectionTimeout},
+				DialTimeout:       Duration{utils.DefaultDialTimeout},
+				ProbeTimeout:      Duration{DefaultConnectionTimeout},
+				InitialBackoff:    Duration{utils.DefaultInitialBackoff},
+				MaxBackoff:        Duration{DefaultMaxBackoff},
+				ImagePrefixes:     []string{"vm"},
+				AnnotationValues:  []string{"vm"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("bad config:\n%s\ninstead of\n%s", dump(config), dump(expected))
+	}
+
+	if _, err := ConfigFromConnectSpec("alt:/run/alt.sock"); err == nil {
+		t.Errorf("didn't get an error for a connect spec without primary runtime")
+	}
+}
@@ -169,6 +169,8 @@ type FilesystemUsage_112 struct {
 	inner *runtimeapi.FilesystemUsage
 }
 
+var _ FilesystemUsage = &FilesystemUsage_112{}
+
 func (o *FilesystemUsage_112) Wrap(v interface{}) {
 	if v == nil {
 		o.inner = &runtimeapi.FilesystemUsage{}
@@ -177,6 +179,30 @@ func (o *FilesystemUsage_112) Wrap(v interface{}) {
 	}
 }
 func (o *FilesystemUsage_112) Unwrap() interface{} { return o.inner }
+func (o *FilesystemUsage_112) FsId() string        { return o.inner.GetFsId().GetMountpoint() }
+func (o *FilesystemUsage_112) SetFsId(id string) {
+	if id == "" {
+		o.inner.FsId = nil
+	} else {
+		o.inner.FsId = &runtimeapi.FilesystemIdentifier{Mountpoint: id}
+	}
+}
+func (o *FilesystemUsage_112) AddUsage(other FilesystemUsage) {
+	in := other.Unwrap().(*runtimeapi.FilesystemUsage)
+	o.inner.UsedBytes = addUInt64Values_112(o.inner.UsedBytes, in.UsedBytes)
+	o.inner.InodesUsed = addUInt64Values_112(o.inner.InodesUsed, in.InodesUsed)
+}
+
+func addUInt64Values_112(a, b *runtimeapi.UInt64Value) *runtimeapi.UInt64Value {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	default:
+		return &runtimeapi.UInt64Value{Value: a.Value + b.Value}
+	}
+}
 
 // ---
 
@@ -210,7 +236,12 @@ func (o *VersionResponse_112) Wrap(v interface{}) {
 		o.inner = v.(*runtimeapi.VersionResponse)
 	}
 }
-func (o *VersionResponse_112) Unwrap() interface{} { return o.inner }
+func (o *VersionResponse_112) Unwrap() interface{}              { return o.inner }
+func (o *VersionResponse_112) RuntimeName() string              { return o.inner.RuntimeName }
+func (o *VersionResponse_112) SetRuntimeName(name string)       { o.inner.RuntimeName = name }
+func (o *VersionResponse_112) RuntimeVersion() string           { return o.inner.RuntimeVersion }
+func (o *VersionResponse_112) SetRuntimeVersion(version string) { o.inner.RuntimeVersion = version }
+func (o *VersionResponse_112) RuntimeApiVersion() string        { return o.inner.RuntimeApiVersion }
 
 // ---
 
@@ -245,6 +276,32 @@ func (o *StatusResponse_112) Wrap(v interface{}) {
 	}
 }
 func (o *StatusResponse_112) Unwrap() interface{} { return o.inner }
+func (o *StatusResponse_112) Conditions() []RuntimeCondition {
+	var r []RuntimeCondition
+	for _, c := range o.inner.GetStatus().GetConditions() {
+		r = append(r, RuntimeCondition{
+			Type:    c.Type,
+			Status:  c.Status,
+			Reason:  c.Reason,
+			Message: c.Message,
+		})
+	}
+	return r
+}
+func (o *StatusResponse_112) SetConditions(conditions []RuntimeCondition) {
+	if o.inner.Status == nil {
+		o.inner.Status = &runtimeapi.RuntimeStatus{}
+	}
+	o.inner.Status.Conditions = nil
+	for _, c := range conditions {
+		o.inner.Status.Conditions = append(o.inner.Status.Conditions, &runtimeapi.RuntimeCondition{
+			Type:    c.Type,
+			Status:  c.Status,
+			Reason:  c.Reason,
+			Message: c.Message,
+		})
+	}
+}
 
 // ---
 
@@ -296,9 +353,22 @@ func (o *RunPodSandboxRequest_112) Wrap(v interface{}) {
 	}
 }
 func (o *RunPodSandboxRequest_112) Unwrap() interface{} { return o.inner }
+func (o *RunPodSandboxRequest_112) GetName() string {
+	return o.inner.Config.GetMetadata().GetName()
+}
+func (o *RunPodSandboxRequest_112) GetNamespace() string {
+	return o.inner.Config.GetMetadata().GetNamespace()
+}
+func (o *RunPodSandboxRequest_112) GetLabels() map[string]string {
+	return o.inner.Config.GetLabels()
+}
 func (o *RunPodSandboxRequest_112) GetAnnotations() map[string]string {
 	return o.inner.Config.GetAnnotations()
 }
+func (o *RunPodSandboxRequest_112) RuntimeHandler() string { return o.inner.RuntimeHandler }
+func (o *RunPodSandboxRequest_112) SetRuntimeHandler(handler string) {
+	o.inner.RuntimeHandler = handler
+}
 
 // ---
 
@@ -347,6 +417,11 @@ func (o *ListPodSandboxRequest_112) SetIdFilter(id string) {
 	}
 }
 
+func (o *ListPodSandboxRequest_112) HasFilter() bool {
+	f := o.inner.Filter
+	return f != nil && (f.Id != "" || f.State != nil || len(f.LabelSelector) != 0)
+}
+
 // ---
 
 type ListPodSandboxResponse_112 struct {
@@ -498,6 +573,7 @@ type CreateContainerRequest_112 struct {
 }
 
 var _ CreateContainerRequest = &CreateContainerRequest_112{}
+var _ Redactable = &CreateContainerRequest_112{}
 
 func (o *CreateContainerRequest_112) Wrap(v interface{}) {
 	if v == nil {
@@ -522,6 +598,29 @@ func (o *CreateContainerRequest_112) SetImage(image string) {
 	}
 }
 
+func (o *CreateContainerRequest_112) Redacted(redactEnv func(string) bool) interface{} {
+	if o.inner.Config == nil {
+		return o.inner
+	}
+	var envs []*runtimeapi.KeyValue
+	redacted := false
+	for _, kv := range o.inner.Config.Envs {
+		if kv != nil && kv.Value != "" && redactEnv(kv.Key) {
+			kv = &runtimeapi.KeyValue{Key: kv.Key, Value: redactedValue}
+			redacted = true
+		}
+		envs = append(envs, kv)
+	}
+	if !redacted {
+		return o.inner
+	}
+	req := *o.inner
+	config := *req.Config
+	config.Envs = envs
+	req.Config = &config
+	return &req
+}
+
 // ---
 
 type CreateContainerResponse_112 struct {
@@ -581,6 +680,11 @@ func (o *ListContainersRequest_112) SetPodSandboxIdFilter(podSandboxId string) {
 	}
 }
 
+func (o *ListContainersRequest_112) HasFilter() bool {
+	f := o.inner.Filter
+	return f != nil && (f.Id != "" || f.State != nil || f.PodSandboxId != "" || len(f.LabelSelector) != 0)
+}
+
 // ---
 
 type ListContainersResponse_112 struct {
@@ -916,6 +1020,7 @@ type ExecSyncRequest_112 struct {
 }
 
 var _ ExecSyncRequest = &ExecSyncRequest_112{}
+var _ Redactable = &ExecSyncRequest_112{}
 
 func (o *ExecSyncRequest_112) Wrap(v interface{}) {
 	if v == nil {
@@ -927,6 +1032,14 @@ func (o *ExecSyncRequest_112) Wrap(v interface{}) {
 func (o *ExecSyncRequest_112) Unwrap() interface{}      { return o.inner }
 func (o *ExecSyncRequest_112) ContainerId() string      { return o.inner.ContainerId }
 func (o *ExecSyncRequest_112) SetContainerId(id string) { o.inner.ContainerId = id }
+func (o *ExecSyncRequest_112) Redacted(redactEnv func(string) bool) interface{} {
+	if len(o.inner.Cmd) <= 1 {
+		return o.inner
+	}
+	req := *o.inner
+	req.Cmd = redactCmd(req.Cmd)
+	return &req
+}
 
 // ---
 
@@ -952,6 +1065,7 @@ type ExecRequest_112 struct {
 }
 
 var _ ExecRequest = &ExecRequest_112{}
+var _ Redactable = &ExecRequest_112{}
 
 func (o *ExecRequest_112) Wrap(v interface{}) {
 	if v == nil {
@@ -963,6 +1077,14 @@ func (o *ExecRequest_112) Wrap(v interface{}) {
 func (o *ExecRequest_112) Unwrap() interface{}      { return o.inner }
 func (o *ExecRequest_112) ContainerId() string      { return o.inner.ContainerId }
 func (o *ExecRequest_112) SetContainerId(id string) { o.inner.ContainerId = id }
+func (o *ExecRequest_112) Redacted(redactEnv func(string) bool) interface{} {
+	if len(o.inner.Cmd) <= 1 {
+		return o.inner
+	}
+	req := *o.inner
+	req.Cmd = redactCmd(req.Cmd)
+	return &req
+}
 
 // ---
 
@@ -1172,6 +1294,7 @@ type PullImageRequest_112 struct {
 }
 
 var _ PullImageRequest = &PullImageRequest_112{}
+var _ Redactable = &PullImageRequest_112{}
 
 func (o *PullImageRequest_112) Wrap(v interface{}) {
 	if v == nil {
@@ -1186,6 +1309,20 @@ func (o *PullImageRequest_112) SetImage(image string) {
 	o.inner.Image = &runtimeapi.ImageSpec{Image: image}
 }
 
+func (o *PullImageRequest_112) Redacted(redactEnv func(string) bool) interface{} {
+	if o.inner.Auth == nil {
+		return o.inner
+	}
+	req := *o.inner
+	auth := *req.Auth
+	auth.Password = redactString(auth.Password)
+	auth.Auth = redactString(auth.Auth)
+	auth.IdentityToken = redactString(auth.IdentityToken)
+	auth.RegistryToken = redactString(auth.RegistryToken)
+	req.Auth = &auth
+	return &req
+}
+
 // ---
 
 type PullImageResponse_112 struct {
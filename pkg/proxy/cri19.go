@@ -166,6 +166,8 @@ type FilesystemUsage_19 struct {
 	inner *runtimeapi.FilesystemUsage
 }
 
+var _ FilesystemUsage = &FilesystemUsage_19{}
+
 func (o *FilesystemUsage_19) Wrap(v interface{}) {
 	if v == nil {
 		o.inner = &runtimeapi.FilesystemUsage{}
@@ -174,6 +176,30 @@ func (o *FilesystemUsage_19) Wrap(v interface{}) {
 	}
 }
 func (o *FilesystemUsage_19) Unwrap() interface{} { return o.inner }
+func (o *FilesystemUsage_19) FsId() string        { return o.inner.GetStorageId().GetUuid() }
+func (o *FilesystemUsage_19) SetFsId(id string) {
+	if id == "" {
+		o.inner.StorageId = nil
+	} else {
+		o.inner.StorageId = &runtimeapi.StorageIdentifier{Uuid: id}
+	}
+}
+func (o *FilesystemUsage_19) AddUsage(other FilesystemUsage) {
+	in := other.Unwrap().(*runtimeapi.FilesystemUsage)
+	o.inner.UsedBytes = addUInt64Values_19(o.inner.UsedBytes, in.UsedBytes)
+	o.inner.InodesUsed = addUInt64Values_19(o.inner.InodesUsed, in.InodesUsed)
+}
+
+func addUInt64Values_19(a, b *runtimeapi.UInt64Value) *runtimeapi.UInt64Value {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	default:
+		return &runtimeapi.UInt64Value{Value: a.Value + b.Value}
+	}
+}
 
 // ---
 
@@ -207,7 +233,12 @@ func (o *VersionResponse_19) Wrap(v interface{}) {
 		o.inner = v.(*runtimeapi.VersionResponse)
 	}
 }
-func (o *VersionResponse_19) Unwrap() interface{} { return o.inner }
+func (o *VersionResponse_19) Unwrap() interface{}              { return o.inner }
+func (o *VersionResponse_19) RuntimeName() string              { return o.inner.RuntimeName }
+func (o *VersionResponse_19) SetRuntimeName(name string)       { o.inner.RuntimeName = name }
+func (o *VersionResponse_19) RuntimeVersion() string           { return o.inner.RuntimeVersion }
+func (o *VersionResponse_19) SetRuntimeVersion(version string) { o.inner.RuntimeVersion = version }
+func (o *VersionResponse_19) RuntimeApiVersion() string        { return o.inner.RuntimeApiVersion }
 
 // ---
 
@@ -242,6 +273,32 @@ func (o *StatusResponse_19) Wrap(v interface{}) {
 	}
 }
 func (o *StatusResponse_19) Unwrap() interface{} { return o.inner }
+func (o *StatusResponse_19) Conditions() []RuntimeCondition {
+	var r []RuntimeCondition
+	for _, c := range o.inner.GetStatus().GetConditions() {
+		r = append(r, RuntimeCondition{
+			Type:    c.Type,
+			Status:  c.Status,
+			Reason:  c.Reason,
+			Message: c.Message,
+		})
+	}
+	return r
+}
+func (o *StatusResponse_19) SetConditions(conditions []RuntimeCondition) {
+	if o.inner.Status == nil {
+		o.inner.Status = &runtimeapi.RuntimeStatus{}
+	}
+	o.inner.Status.Conditions = nil
+	for _, c := range conditions {
+		o.inner.Status.Conditions = append(o.inner.Status.Conditions, &runtimeapi.RuntimeCondition{
+			Type:    c.Type,
+			Status:  c.Status,
+			Reason:  c.Reason,
+			Message: c.Message,
+		})
+	}
+}
 
 // ---
 
@@ -293,9 +350,20 @@ func (o *RunPodSandboxRequest_19) Wrap(v interface{}) {
 	}
 }
 func (o *RunPodSandboxRequest_19) Unwrap() interface{} { return o.inner }
+func (o *RunPodSandboxRequest_19) GetName() string {
+	return o.inner.Config.GetMetadata().GetName()
+}
+func (o *RunPodSandboxRequest_19) GetNamespace() string {
+	return o.inner.Config.GetMetadata().GetNamespace()
+}
+func (o *RunPodSandboxRequest_19) GetLabels() map[string]string {
+	return o.inner.Config.GetLabels()
+}
 func (o *RunPodSandboxRequest_19) GetAnnotations() map[string]string {
 	return o.inner.Config.GetAnnotations()
 }
+func (o *RunPodSandboxRequest_19) RuntimeHandler() string           { return "" }
+func (o *RunPodSandboxRequest_19) SetRuntimeHandler(handler string) {}
 
 // ---
 
@@ -344,6 +412,11 @@ func (o *ListPodSandboxRequest_19) SetIdFilter(id string) {
 	}
 }
 
+func (o *ListPodSandboxRequest_19) HasFilter() bool {
+	f := o.inner.Filter
+	return f != nil && (f.Id != "" || f.State != nil || len(f.LabelSelector) != 0)
+}
+
 // ---
 
 type ListPodSandboxResponse_19 struct {
@@ -495,6 +568,7 @@ type CreateContainerRequest_19 struct {
 }
 
 var _ CreateContainerRequest = &CreateContainerRequest_19{}
+var _ Redactable = &CreateContainerRequest_19{}
 
 func (o *CreateContainerRequest_19) Wrap(v interface{}) {
 	if v == nil {
@@ -519,6 +593,29 @@ func (o *CreateContainerRequest_19) SetImage(image string) {
 	}
 }
 
+func (o *CreateContainerRequest_19) Redacted(redactEnv func(string) bool) interface{} {
+	if o.inner.Config == nil {
+		return o.inner
+	}
+	var envs []*runtimeapi.KeyValue
+	redacted := false
+	for _, kv := range o.inner.Config.Envs {
+		if kv != nil && kv.Value != "" && redactEnv(kv.Key) {
+			kv = &runtimeapi.KeyValue{Key: kv.Key, Value: redactedValue}
+			redacted = true
+		}
+		envs = append(envs, kv)
+	}
+	if !redacted {
+		return o.inner
+	}
+	req := *o.inner
+	config := *req.Config
+	config.Envs = envs
+	req.Config = &config
+	return &req
+}
+
 // ---
 
 type CreateContainerResponse_19 struct {
@@ -578,6 +675,11 @@ func (o *ListContainersRequest_19) SetPodSandboxIdFilter(podSandboxId string) {
 	}
 }
 
+func (o *ListContainersRequest_19) HasFilter() bool {
+	f := o.inner.Filter
+	return f != nil && (f.Id != "" || f.State != nil || f.PodSandboxId != "" || len(f.LabelSelector) != 0)
+}
+
 // ---
 
 type ListContainersResponse_19 struct {
@@ -877,6 +979,7 @@ type ExecSyncRequest_19 struct {
 }
 
 var _ ExecSyncRequest = &ExecSyncRequest_19{}
+var _ Redactable = &ExecSyncRequest_19{}
 
 func (o *ExecSyncRequest_19) Wrap(v interface{}) {
 	if v == nil {
@@ -888,6 +991,14 @@ func (o *ExecSyncRequest_19) Wrap(v interface{}) {
 func (o *ExecSyncRequest_19) Unwrap() interface{}      { return o.inner }
 func (o *ExecSyncRequest_19) ContainerId() string      { return o.inner.ContainerId }
 func (o *ExecSyncRequest_19) SetContainerId(id string) { o.inner.ContainerId = id }
+func (o *ExecSyncRequest_19) Redacted(redactEnv func(string) bool) interface{} {
+	if len(o.inner.Cmd) <= 1 {
+		return o.inner
+	}
+	req := *o.inner
+	req.Cmd = redactCmd(req.Cmd)
+	return &req
+}
 
 // ---
 
@@ -913,6 +1024,7 @@ type ExecRequest_19 struct {
 }
 
 var _ ExecRequest = &ExecRequest_19{}
+var _ Redactable = &ExecRequest_19{}
 
 func (o *ExecRequest_19) Wrap(v interface{}) {
 	if v == nil {
@@ -929,6 +1041,14 @@ func (o *ExecRequest_19) Wrap(v interface{}) {
 func (o *ExecRequest_19) Unwrap() interface{}      { return o.inner }
 func (o *ExecRequest_19) ContainerId() string      { return o.inner.ContainerId }
 func (o *ExecRequest_19) SetContainerId(id string) { o.inner.ContainerId = id }
+func (o *ExecRequest_19) Redacted(redactEnv func(string) bool) interface{} {
+	if len(o.inner.Cmd) <= 1 {
+		return o.inner
+	}
+	req := *o.inner
+	req.Cmd = redactCmd(req.Cmd)
+	return &req
+}
 
 // ---
 
@@ -1143,6 +1263,7 @@ type PullImageRequest_19 struct {
 }
 
 var _ PullImageRequest = &PullImageRequest_19{}
+var _ Redactable = &PullImageRequest_19{}
 
 func (o *PullImageRequest_19) Wrap(v interface{}) {
 	if v == nil {
@@ -1157,6 +1278,20 @@ func (o *PullImageRequest_19) SetImage(image string) {
 	o.inner.Image = &runtimeapi.ImageSpec{Image: image}
 }
 
+func (o *PullImageRequest_19) Redacted(redactEnv func(string) bool) interface{} {
+	if o.inner.Auth == nil {
+		return o.inner
+	}
+	req := *o.inner
+	auth := *req.Auth
+	auth.Password = redactString(auth.Password)
+	auth.Auth = redactString(auth.Auth)
+	auth.IdentityToken = redactString(auth.IdentityToken)
+	auth.RegistryToken = redactString(auth.RegistryToken)
+	req.Auth = &auth
+	return &req
+}
+
 // ---
 
 type PullImageResponse_19 struct {
@@ -1384,7 +1519,3 @@ func (c *CRI19) WrapObject(o interface{}) (CRIObject, CRIObject, error) {
 }
 
 func (c *CRI19) ProtoPackage() string { return "runtime" }
-
-func (c *CRI19) UpgradesTo() CRIVersion {
-	return &CRI112{}
-}
@@ -101,6 +101,12 @@ type ImageFilterObject interface {
 	SetImageFilter(string)
 }
 
+// FilterObject is a wrapped CRI List* request that may contain a filter.
+type FilterObject interface {
+	// HasFilter returns true if the filter limits the listed objects.
+	HasFilter() bool
+}
+
 // UrlObject is a wrapped CRI object that contains an URL.
 type UrlObject interface {
 	// Url returns the url contained in the object.
@@ -109,6 +115,17 @@ type UrlObject interface {
 	SetUrl(string)
 }
 
+// Redactable is a wrapped CRI object that may contain secrets
+// like registry credentials which must not be logged.
+type Redactable interface {
+	// Redacted returns the unwrapped CRI object with the secrets
+	// replaced. The object itself is returned if it contains no
+	// secrets, otherwise, a copy of it is made. redactEnv tells
+	// whether the value of the environment variable with the
+	// specified name must be redacted.
+	Redacted(redactEnv func(name string) bool) interface{}
+}
+
 // ObjectList denotes a wrapped CRI object that denotes a list of other CRI objects.
 type ObjectList interface {
 	// Items returns a slice of CRI objects that are contained in the list.
@@ -169,6 +186,15 @@ type ContainerStatus interface {
 // FilesystemUsage wraps a CRI FilesystemUsage object
 type FilesystemUsage interface {
 	CRIObject
+	// FsId returns the identifier of the filesystem, which is
+	// the UUID of the device for CRI 1.9 and the mount point
+	// for the newer CRI versions.
+	FsId() string
+	// SetFsId sets the identifier of the filesystem.
+	SetFsId(string)
+	// AddUsage adds the used bytes and inodes of another
+	// FilesystemUsage of the same CRI version to this one.
+	AddUsage(FilesystemUsage)
 }
 
 // VersionRequest wraps a CRI VersionRequest object
@@ -179,6 +205,16 @@ type VersionRequest interface {
 // VersionResponse wraps a CRI VersionResponse object
 type VersionResponse interface {
 	CRIObject
+	// RuntimeName returns the name of the runtime.
+	RuntimeName() string
+	// SetRuntimeName sets the name of the runtime.
+	SetRuntimeName(string)
+	// RuntimeVersion returns the version of the runtime.
+	RuntimeVersion() string
+	// SetRuntimeVersion sets the version of the runtime.
+	SetRuntimeVersion(string)
+	// RuntimeApiVersion returns the API version of the runtime.
+	RuntimeApiVersion() string
 }
 
 // StatusRequest wraps a CRI StatusRequest object
@@ -186,9 +222,26 @@ type StatusRequest interface {
 	CRIObject
 }
 
+// RuntimeCondition is a CRI RuntimeCondition that doesn't depend
+// on the CRI version
+type RuntimeCondition struct {
+	// Type is the type of the condition, e.g. RuntimeReady.
+	Type string
+	// Status is true if the condition is met.
+	Status bool
+	// Reason is a brief CamelCase reason for the condition.
+	Reason string
+	// Message is a human-readable message for the condition.
+	Message string
+}
+
 // StatusResponse wraps a CRI StatusResponse object
 type StatusResponse interface {
 	CRIObject
+	// Conditions returns the runtime conditions contained in the response.
+	Conditions() []RuntimeCondition
+	// SetConditions sets the runtime conditions contained in the response.
+	SetConditions([]RuntimeCondition)
 }
 
 // UpdateRuntimeConfigRequest wraps a CRI UpdateRuntimeConfigRequest object
@@ -204,7 +257,16 @@ type UpdateRuntimeConfigResponse interface {
 // RunPodSandboxRequest wraps a CRI RunPodSandboxRequest object
 type RunPodSandboxRequest interface {
 	CRIObject
+	GetName() string
+	GetNamespace() string
+	GetLabels() map[string]string
 	GetAnnotations() map[string]string
+	// RuntimeHandler returns the runtime handler for the pod
+	// (always empty for CRI versions before 1.12)
+	RuntimeHandler() string
+	// SetRuntimeHandler sets the runtime handler for the pod
+	// (does nothing for CRI versions before 1.12)
+	SetRuntimeHandler(handler string)
 }
 
 // RunPodSandboxResponse wraps a CRI RunPodSandboxResponse object
@@ -217,6 +279,7 @@ type RunPodSandboxResponse interface {
 type ListPodSandboxRequest interface {
 	CRIObject
 	IdFilterObject
+	FilterObject
 }
 
 // ListPodSandboxResponse wraps a CRI ListPodSandboxResponse object
@@ -277,6 +340,7 @@ type ListContainersRequest interface {
 	CRIObject
 	IdFilterObject
 	PodSandboxIdFilterObject
+	FilterObject
 }
 
 // ListContainersResponse wraps a CRI ListContainersResponse object
@@ -499,14 +563,6 @@ type CRIVersion interface {
 	ProtoPackage() string
 }
 
-// UpgradableCRIVersion is a CRI version that supports upgrading of
-// the objects for a newer CRI version.
-type UpgradableCRIVersion interface {
-	CRIVersion
-	// UpgradesTo returns a CRI version this one upgrades to.
-	UpgradesTo() CRIVersion
-}
-
 func wrapUsingMatcher(tm *typeMatcher, o interface{}) (CRIObject, CRIObject, error) {
 	if o == nil {
 		return nil, nil, nil
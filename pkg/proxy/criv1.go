@@ -0,0 +1,1554 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"google.golang.org/grpc"
+
+	runtimeapi "github.com/Mirantis/criproxy/pkg/runtimeapis/v1"
+)
+
+// ---
+
+type PodSandbox_v1 struct {
+	inner *runtimeapi.PodSandbox
+}
+
+var _ PodSandbox = &PodSandbox_v1{}
+
+func (o *PodSandbox_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.PodSandbox{}
+	} else {
+		o.inner = v.(*runtimeapi.PodSandbox)
+	}
+}
+func (o *PodSandbox_v1) Unwrap() interface{} { return o.inner }
+func (o *PodSandbox_v1) Copy() PodSandbox    { r := *o.inner; return &PodSandbox_v1{&r} }
+func (o *PodSandbox_v1) Id() string          { return o.inner.Id }
+func (o *PodSandbox_v1) SetId(id string)     { o.inner.Id = id }
+
+type Container_v1 struct {
+	inner *runtimeapi.Container
+}
+
+// ---
+
+var _ Container = &Container_v1{}
+
+func (o *Container_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.Container{}
+	} else {
+		o.inner = v.(*runtimeapi.Container)
+	}
+}
+func (o *Container_v1) Unwrap() interface{}       { return o.inner }
+func (o *Container_v1) Copy() Container           { r := *o.inner; return &Container_v1{&r} }
+func (o *Container_v1) Id() string                { return o.inner.Id }
+func (o *Container_v1) SetId(id string)           { o.inner.Id = id }
+func (o *Container_v1) PodSandboxId() string      { return o.inner.PodSandboxId }
+func (o *Container_v1) SetPodSandboxId(id string) { o.inner.PodSandboxId = id }
+func (o *Container_v1) Image() string             { return o.inner.Image.GetImage() }
+func (o *Container_v1) SetImage(image string)     { o.inner.Image = &runtimeapi.ImageSpec{Image: image} }
+
+// ---
+
+type Image_v1 struct {
+	inner *runtimeapi.Image
+}
+
+var _ Image = &Image_v1{}
+
+func (o *Image_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.Image{}
+	} else {
+		o.inner = v.(*runtimeapi.Image)
+	}
+}
+func (o *Image_v1) Unwrap() interface{}                 { return o.inner }
+func (o *Image_v1) Copy() Image                         { r := *o.inner; return &Image_v1{&r} }
+func (o *Image_v1) Id() string                          { return o.inner.Id }
+func (o *Image_v1) SetId(id string)                     { o.inner.Id = id }
+func (o *Image_v1) RepoTags() []string                  { return o.inner.RepoTags }
+func (o *Image_v1) SetRepoTags(repoTags []string)       { o.inner.RepoTags = repoTags }
+func (o *Image_v1) RepoDigests() []string               { return o.inner.RepoDigests }
+func (o *Image_v1) SetRepoDigests(repoDigests []string) { o.inner.RepoDigests = repoDigests }
+
+// ---
+
+type PodSandboxStatus_v1 struct {
+	inner *runtimeapi.PodSandboxStatus
+}
+
+var _ PodSandboxStatus = &PodSandboxStatus_v1{}
+
+func (o *PodSandboxStatus_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.PodSandboxStatus{}
+	} else {
+		o.inner = v.(*runtimeapi.PodSandboxStatus)
+	}
+}
+func (o *PodSandboxStatus_v1) Unwrap() interface{} { return o.inner }
+func (o *PodSandboxStatus_v1) Copy() PodSandboxStatus {
+	r := *o.inner
+	return &PodSandboxStatus_v1{&r}
+}
+func (o *PodSandboxStatus_v1) Id() string      { return o.inner.Id }
+func (o *PodSandboxStatus_v1) SetId(id string) { o.inner.Id = id }
+
+// ---
+
+type ContainerStatus_v1 struct {
+	inner *runtimeapi.ContainerStatus
+}
+
+var _ ContainerStatus = &ContainerStatus_v1{}
+
+func (o *ContainerStatus_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ContainerStatus{}
+	} else {
+		o.inner = v.(*runtimeapi.ContainerStatus)
+	}
+}
+func (o *ContainerStatus_v1) Unwrap() interface{}   { return o.inner }
+func (o *ContainerStatus_v1) Copy() ContainerStatus { r := *o.inner; return &ContainerStatus_v1{&r} }
+func (o *ContainerStatus_v1) Id() string            { return o.inner.Id }
+func (o *ContainerStatus_v1) SetId(id string)       { o.inner.Id = id }
+func (o *ContainerStatus_v1) Image() string         { return o.inner.Image.GetImage() }
+func (o *ContainerStatus_v1) SetImage(image string) {
+	o.inner.Image = &runtimeapi.ImageSpec{Image: image}
+}
+
+// ---
+
+type ContainerStats_v1 struct {
+	inner *runtimeapi.ContainerStats
+}
+
+var _ ContainerStats = &ContainerStats_v1{}
+
+func (o *ContainerStats_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ContainerStats{}
+	} else {
+		o.inner = v.(*runtimeapi.ContainerStats)
+	}
+}
+func (o *ContainerStats_v1) Unwrap() interface{}  { return o.inner }
+func (o *ContainerStats_v1) Copy() ContainerStats { r := *o.inner; return &ContainerStats_v1{&r} }
+func (o *ContainerStats_v1) Id() string           { return o.inner.Attributes.GetId() }
+func (o *ContainerStats_v1) SetId(id string) {
+	if o.inner.Attributes == nil {
+		o.inner.Attributes = &runtimeapi.ContainerAttributes{Id: id}
+	} else {
+		o.inner.Attributes.Id = id
+	}
+}
+
+// ---
+
+type FilesystemUsage_v1 struct {
+	inner *runtimeapi.FilesystemUsage
+}
+
+var _ FilesystemUsage = &FilesystemUsage_v1{}
+
+func (o *FilesystemUsage_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.FilesystemUsage{}
+	} else {
+		o.inner = v.(*runtimeapi.FilesystemUsage)
+	}
+}
+func (o *FilesystemUsage_v1) Unwrap() interface{} { return o.inner }
+func (o *FilesystemUsage_v1) FsId() string        { return o.inner.GetFsId().GetMountpoint() }
+func (o *FilesystemUsage_v1) SetFsId(id string) {
+	if id == "" {
+		o.inner.FsId = nil
+	} else {
+		o.inner.FsId = &runtimeapi.FilesystemIdentifier{Mountpoint: id}
+	}
+}
+func (o *FilesystemUsage_v1) AddUsage(other FilesystemUsage) {
+	in := other.Unwrap().(*runtimeapi.FilesystemUsage)
+	o.inner.UsedBytes = addUInt64Values_v1(o.inner.UsedBytes, in.UsedBytes)
+	o.inner.InodesUsed = addUInt64Values_v1(o.inner.InodesUsed, in.InodesUsed)
+}
+
+func addUInt64Values_v1(a, b *runtimeapi.UInt64Value) *runtimeapi.UInt64Value {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	default:
+		return &runtimeapi.UInt64Value{Value: a.Value + b.Value}
+	}
+}
+
+// ---
+
+type VersionRequest_v1 struct {
+	inner *runtimeapi.VersionRequest
+}
+
+var _ VersionRequest = &VersionRequest_v1{}
+
+func (o *VersionRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.VersionRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.VersionRequest)
+	}
+}
+func (o *VersionRequest_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type VersionResponse_v1 struct {
+	inner *runtimeapi.VersionResponse
+}
+
+var _ VersionResponse = &VersionResponse_v1{}
+
+func (o *VersionResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.VersionResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.VersionResponse)
+	}
+}
+func (o *VersionResponse_v1) Unwrap() interface{}              { return o.inner }
+func (o *VersionResponse_v1) RuntimeName() string              { return o.inner.RuntimeName }
+func (o *VersionResponse_v1) SetRuntimeName(name string)       { o.inner.RuntimeName = name }
+func (o *VersionResponse_v1) RuntimeVersion() string           { return o.inner.RuntimeVersion }
+func (o *VersionResponse_v1) SetRuntimeVersion(version string) { o.inner.RuntimeVersion = version }
+func (o *VersionResponse_v1) RuntimeApiVersion() string        { return o.inner.RuntimeApiVersion }
+
+// ---
+
+type StatusRequest_v1 struct {
+	inner *runtimeapi.StatusRequest
+}
+
+var _ StatusRequest = &StatusRequest_v1{}
+
+func (o *StatusRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.StatusRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.StatusRequest)
+	}
+}
+func (o *StatusRequest_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type StatusResponse_v1 struct {
+	inner *runtimeapi.StatusResponse
+}
+
+var _ StatusResponse = &StatusResponse_v1{}
+
+func (o *StatusResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.StatusResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.StatusResponse)
+	}
+}
+func (o *StatusResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *StatusResponse_v1) Conditions() []RuntimeCondition {
+	var r []RuntimeCondition
+	for _, c := range o.inner.GetStatus().GetConditions() {
+		r = append(r, RuntimeCondition{
+			Type:    c.Type,
+			Status:  c.Status,
+			Reason:  c.Reason,
+			Message: c.Message,
+		})
+	}
+	return r
+}
+func (o *StatusResponse_v1) SetConditions(conditions []RuntimeCondition) {
+	if o.inner.Status == nil {
+		o.inner.Status = &runtimeapi.RuntimeStatus{}
+	}
+	o.inner.Status.Conditions = nil
+	for _, c := range conditions {
+		o.inner.Status.Conditions = append(o.inner.Status.Conditions, &runtimeapi.RuntimeCondition{
+			Type:    c.Type,
+			Status:  c.Status,
+			Reason:  c.Reason,
+			Message: c.Message,
+		})
+	}
+}
+
+// ---
+
+type UpdateRuntimeConfigRequest_v1 struct {
+	inner *runtimeapi.UpdateRuntimeConfigRequest
+}
+
+var _ UpdateRuntimeConfigRequest = &UpdateRuntimeConfigRequest_v1{}
+
+func (o *UpdateRuntimeConfigRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.UpdateRuntimeConfigRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.UpdateRuntimeConfigRequest)
+	}
+}
+func (o *UpdateRuntimeConfigRequest_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type UpdateRuntimeConfigResponse_v1 struct {
+	inner *runtimeapi.UpdateRuntimeConfigResponse
+}
+
+var _ UpdateRuntimeConfigResponse = &UpdateRuntimeConfigResponse_v1{}
+
+func (o *UpdateRuntimeConfigResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.UpdateRuntimeConfigResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.UpdateRuntimeConfigResponse)
+	}
+}
+func (o *UpdateRuntimeConfigResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type RunPodSandboxRequest_v1 struct {
+	inner *runtimeapi.RunPodSandboxRequest
+}
+
+var _ RunPodSandboxRequest = &RunPodSandboxRequest_v1{}
+
+func (o *RunPodSandboxRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.RunPodSandboxRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.RunPodSandboxRequest)
+	}
+}
+func (o *RunPodSandboxRequest_v1) Unwrap() interface{} { return o.inner }
+func (o *RunPodSandboxRequest_v1) GetName() string {
+	return o.inner.Config.GetMetadata().GetName()
+}
+func (o *RunPodSandboxRequest_v1) GetNamespace() string {
+	return o.inner.Config.GetMetadata().GetNamespace()
+}
+func (o *RunPodSandboxRequest_v1) GetLabels() map[string]string {
+	return o.inner.Config.GetLabels()
+}
+func (o *RunPodSandboxRequest_v1) GetAnnotations() map[string]string {
+	return o.inner.Config.GetAnnotations()
+}
+func (o *RunPodSandboxRequest_v1) RuntimeHandler() string { return o.inner.RuntimeHandler }
+func (o *RunPodSandboxRequest_v1) SetRuntimeHandler(handler string) {
+	o.inner.RuntimeHandler = handler
+}
+
+// ---
+
+type RunPodSandboxResponse_v1 struct {
+	inner *runtimeapi.RunPodSandboxResponse
+}
+
+var _ RunPodSandboxResponse = &RunPodSandboxResponse_v1{}
+
+func (o *RunPodSandboxResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.RunPodSandboxResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.RunPodSandboxResponse)
+	}
+}
+func (o *RunPodSandboxResponse_v1) Unwrap() interface{}       { return o.inner }
+func (o *RunPodSandboxResponse_v1) PodSandboxId() string      { return o.inner.PodSandboxId }
+func (o *RunPodSandboxResponse_v1) SetPodSandboxId(id string) { o.inner.PodSandboxId = id }
+
+// ---
+
+type ListPodSandboxRequest_v1 struct {
+	inner *runtimeapi.ListPodSandboxRequest
+}
+
+var _ ListPodSandboxRequest = &ListPodSandboxRequest_v1{}
+
+func (o *ListPodSandboxRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ListPodSandboxRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ListPodSandboxRequest)
+	}
+}
+func (o *ListPodSandboxRequest_v1) Unwrap() interface{} { return o.inner }
+func (o *ListPodSandboxRequest_v1) IdFilter() string {
+	return o.inner.Filter.GetId()
+}
+
+func (o *ListPodSandboxRequest_v1) SetIdFilter(id string) {
+	if o.inner.Filter == nil {
+		o.inner.Filter = &runtimeapi.PodSandboxFilter{Id: id}
+	} else {
+		o.inner.Filter.Id = id
+	}
+}
+
+func (o *ListPodSandboxRequest_v1) HasFilter() bool {
+	f := o.inner.Filter
+	return f != nil && (f.Id != "" || f.State != nil || len(f.LabelSelector) != 0)
+}
+
+// ---
+
+type ListPodSandboxResponse_v1 struct {
+	inner *runtimeapi.ListPodSandboxResponse
+}
+
+var _ ListPodSandboxResponse = &ListPodSandboxResponse_v1{}
+
+func (o *ListPodSandboxResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ListPodSandboxResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ListPodSandboxResponse)
+	}
+}
+func (o *ListPodSandboxResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *ListPodSandboxResponse_v1) Items() []CRIObject {
+	var r []CRIObject
+	for _, sandbox := range o.inner.Items {
+		r = append(r, &PodSandbox_v1{sandbox})
+	}
+	return r
+}
+func (o *ListPodSandboxResponse_v1) SetItems(items []CRIObject) {
+	o.inner.Items = nil
+	for _, wrapped := range items {
+		o.inner.Items = append(o.inner.Items, wrapped.Unwrap().(*runtimeapi.PodSandbox))
+	}
+}
+
+// ---
+
+type StopPodSandboxRequest_v1 struct {
+	inner *runtimeapi.StopPodSandboxRequest
+}
+
+var _ StopPodSandboxRequest = &StopPodSandboxRequest_v1{}
+
+func (o *StopPodSandboxRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.StopPodSandboxRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.StopPodSandboxRequest)
+	}
+}
+func (o *StopPodSandboxRequest_v1) Unwrap() interface{}       { return o.inner }
+func (o *StopPodSandboxRequest_v1) PodSandboxId() string      { return o.inner.PodSandboxId }
+func (o *StopPodSandboxRequest_v1) SetPodSandboxId(id string) { o.inner.PodSandboxId = id }
+
+// ---
+
+type StopPodSandboxResponse_v1 struct {
+	inner *runtimeapi.StopPodSandboxResponse
+}
+
+var _ StopPodSandboxResponse = &StopPodSandboxResponse_v1{}
+
+func (o *StopPodSandboxResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.StopPodSandboxResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.StopPodSandboxResponse)
+	}
+}
+func (o *StopPodSandboxResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type RemovePodSandboxRequest_v1 struct {
+	inner *runtimeapi.RemovePodSandboxRequest
+}
+
+var _ RemovePodSandboxRequest = &RemovePodSandboxRequest_v1{}
+
+func (o *RemovePodSandboxRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.RemovePodSandboxRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.RemovePodSandboxRequest)
+	}
+}
+func (o *RemovePodSandboxRequest_v1) Unwrap() interface{}       { return o.inner }
+func (o *RemovePodSandboxRequest_v1) PodSandboxId() string      { return o.inner.PodSandboxId }
+func (o *RemovePodSandboxRequest_v1) SetPodSandboxId(id string) { o.inner.PodSandboxId = id }
+
+// ---
+
+type RemovePodSandboxResponse_v1 struct {
+	inner *runtimeapi.RemovePodSandboxResponse
+}
+
+var _ RemovePodSandboxResponse = &RemovePodSandboxResponse_v1{}
+
+func (o *RemovePodSandboxResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.RemovePodSandboxResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.RemovePodSandboxResponse)
+	}
+}
+func (o *RemovePodSandboxResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type PodSandboxStatusRequest_v1 struct {
+	inner *runtimeapi.PodSandboxStatusRequest
+}
+
+var _ PodSandboxStatusRequest = &PodSandboxStatusRequest_v1{}
+
+func (o *PodSandboxStatusRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.PodSandboxStatusRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.PodSandboxStatusRequest)
+	}
+}
+func (o *PodSandboxStatusRequest_v1) Unwrap() interface{}       { return o.inner }
+func (o *PodSandboxStatusRequest_v1) PodSandboxId() string      { return o.inner.PodSandboxId }
+func (o *PodSandboxStatusRequest_v1) SetPodSandboxId(id string) { o.inner.PodSandboxId = id }
+
+// ---
+
+type PodSandboxStatusResponse_v1 struct {
+	inner *runtimeapi.PodSandboxStatusResponse
+}
+
+var _ PodSandboxStatusResponse = &PodSandboxStatusResponse_v1{}
+
+func (o *PodSandboxStatusResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.PodSandboxStatusResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.PodSandboxStatusResponse)
+	}
+}
+func (o *PodSandboxStatusResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *PodSandboxStatusResponse_v1) Status() PodSandboxStatus {
+	if o.inner.Status == nil {
+		return nil
+	}
+	return &PodSandboxStatus_v1{o.inner.Status}
+}
+
+// ---
+
+type CreateContainerRequest_v1 struct {
+	inner *runtimeapi.CreateContainerRequest
+}
+
+var _ CreateContainerRequest = &CreateContainerRequest_v1{}
+var _ Redactable = &CreateContainerRequest_v1{}
+
+func (o *CreateContainerRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.CreateContainerRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.CreateContainerRequest)
+	}
+}
+func (o *CreateContainerRequest_v1) Unwrap() interface{}       { return o.inner }
+func (o *CreateContainerRequest_v1) PodSandboxId() string      { return o.inner.PodSandboxId }
+func (o *CreateContainerRequest_v1) SetPodSandboxId(id string) { o.inner.PodSandboxId = id }
+func (o *CreateContainerRequest_v1) Image() string {
+	if o.inner.Config == nil {
+		return ""
+	}
+	return o.inner.Config.Image.GetImage()
+}
+
+func (o *CreateContainerRequest_v1) SetImage(image string) {
+	if o.inner.Config != nil {
+		o.inner.Config.Image = &runtimeapi.ImageSpec{Image: image}
+	}
+}
+
+func (o *CreateContainerRequest_v1) Redacted(redactEnv func(string) bool) interface{} {
+	if o.inner.Config == nil {
+		return o.inner
+	}
+	var envs []*runtimeapi.KeyValue
+	redacted := false
+	for _, kv := range o.inner.Config.Envs {
+		if kv != nil && kv.Value != "" && redactEnv(kv.Key) {
+			kv = &runtimeapi.KeyValue{Key: kv.Key, Value: redactedValue}
+			redacted = true
+		}
+		envs = append(envs, kv)
+	}
+	if !redacted {
+		return o.inner
+	}
+	req := *o.inner
+	config := *req.Config
+	config.Envs = envs
+	req.Config = &config
+	return &req
+}
+
+// ---
+
+type CreateContainerResponse_v1 struct {
+	inner *runtimeapi.CreateContainerResponse
+}
+
+var _ CreateContainerResponse = &CreateContainerResponse_v1{}
+
+func (o *CreateContainerResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.CreateContainerResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.CreateContainerResponse)
+	}
+}
+func (o *CreateContainerResponse_v1) Unwrap() interface{}      { return o.inner }
+func (o *CreateContainerResponse_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *CreateContainerResponse_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+
+// ---
+
+type ListContainersRequest_v1 struct {
+	inner *runtimeapi.ListContainersRequest
+}
+
+var _ ListContainersRequest = &ListContainersRequest_v1{}
+
+func (o *ListContainersRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ListContainersRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ListContainersRequest)
+	}
+}
+func (o *ListContainersRequest_v1) Unwrap() interface{} { return o.inner }
+func (o *ListContainersRequest_v1) IdFilter() string {
+	return o.inner.Filter.GetId()
+}
+
+func (o *ListContainersRequest_v1) SetIdFilter(id string) {
+	if o.inner.Filter == nil {
+		o.inner.Filter = &runtimeapi.ContainerFilter{Id: id}
+	} else {
+		o.inner.Filter.Id = id
+	}
+}
+
+func (o *ListContainersRequest_v1) PodSandboxIdFilter() string {
+	return o.inner.Filter.GetPodSandboxId()
+}
+
+func (o *ListContainersRequest_v1) SetPodSandboxIdFilter(podSandboxId string) {
+	if o.inner.Filter == nil {
+		o.inner.Filter = &runtimeapi.ContainerFilter{Id: podSandboxId}
+	} else {
+		o.inner.Filter.PodSandboxId = podSandboxId
+	}
+}
+
+func (o *ListContainersRequest_v1) HasFilter() bool {
+	f := o.inner.Filter
+	return f != nil && (f.Id != "" || f.State != nil || f.PodSandboxId != "" || len(f.LabelSelector) != 0)
+}
+
+// ---
+
+type ListContainersResponse_v1 struct {
+	inner *runtimeapi.ListContainersResponse
+}
+
+var _ ListContainersResponse = &ListContainersResponse_v1{}
+
+func (o *ListContainersResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ListContainersResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ListContainersResponse)
+	}
+}
+func (o *ListContainersResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *ListContainersResponse_v1) Items() []CRIObject {
+	var r []CRIObject
+	for _, container := range o.inner.Containers {
+		r = append(r, &Container_v1{container})
+	}
+	return r
+}
+func (o *ListContainersResponse_v1) SetItems(items []CRIObject) {
+	o.inner.Containers = nil
+	for _, wrapped := range items {
+		o.inner.Containers = append(o.inner.Containers, wrapped.Unwrap().(*runtimeapi.Container))
+	}
+}
+
+// ---
+
+type ListContainerStatsRequest_v1 struct {
+	inner *runtimeapi.ListContainerStatsRequest
+}
+
+var _ ListContainerStatsRequest = &ListContainerStatsRequest_v1{}
+
+func (o *ListContainerStatsRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ListContainerStatsRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ListContainerStatsRequest)
+	}
+}
+func (o *ListContainerStatsRequest_v1) Unwrap() interface{} { return o.inner }
+func (o *ListContainerStatsRequest_v1) IdFilter() string {
+	return o.inner.Filter.GetId()
+}
+
+func (o *ListContainerStatsRequest_v1) SetIdFilter(id string) {
+	if o.inner.Filter == nil {
+		o.inner.Filter = &runtimeapi.ContainerStatsFilter{Id: id}
+	} else {
+		o.inner.Filter.Id = id
+	}
+}
+
+func (o *ListContainerStatsRequest_v1) PodSandboxIdFilter() string {
+	return o.inner.Filter.GetPodSandboxId()
+}
+
+func (o *ListContainerStatsRequest_v1) SetPodSandboxIdFilter(podSandboxId string) {
+	if o.inner.Filter == nil {
+		o.inner.Filter = &runtimeapi.ContainerStatsFilter{Id: podSandboxId}
+	} else {
+		o.inner.Filter.PodSandboxId = podSandboxId
+	}
+}
+
+// ---
+
+type ListContainerStatsResponse_v1 struct {
+	inner *runtimeapi.ListContainerStatsResponse
+}
+
+var _ ListContainerStatsResponse = &ListContainerStatsResponse_v1{}
+
+func (o *ListContainerStatsResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ListContainerStatsResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ListContainerStatsResponse)
+	}
+}
+func (o *ListContainerStatsResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *ListContainerStatsResponse_v1) Items() []CRIObject {
+	var r []CRIObject
+	for _, stats := range o.inner.Stats {
+		r = append(r, &ContainerStats_v1{stats})
+	}
+	return r
+}
+func (o *ListContainerStatsResponse_v1) SetItems(items []CRIObject) {
+	o.inner.Stats = nil
+	for _, wrapped := range items {
+		o.inner.Stats = append(o.inner.Stats, wrapped.Unwrap().(*runtimeapi.ContainerStats))
+	}
+}
+
+// ---
+
+type StartContainerRequest_v1 struct {
+	inner *runtimeapi.StartContainerRequest
+}
+
+var _ StartContainerRequest = &StartContainerRequest_v1{}
+
+func (o *StartContainerRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.StartContainerRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.StartContainerRequest)
+	}
+}
+func (o *StartContainerRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *StartContainerRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *StartContainerRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+
+// ---
+
+type StartContainerResponse_v1 struct {
+	inner *runtimeapi.StartContainerResponse
+}
+
+var _ StartContainerResponse = &StartContainerResponse_v1{}
+
+func (o *StartContainerResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.StartContainerResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.StartContainerResponse)
+	}
+}
+func (o *StartContainerResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type StopContainerRequest_v1 struct {
+	inner *runtimeapi.StopContainerRequest
+}
+
+var _ StopContainerRequest = &StopContainerRequest_v1{}
+
+func (o *StopContainerRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.StopContainerRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.StopContainerRequest)
+	}
+}
+func (o *StopContainerRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *StopContainerRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *StopContainerRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+
+// ---
+
+type StopContainerResponse_v1 struct {
+	inner *runtimeapi.StopContainerResponse
+}
+
+var _ StopContainerResponse = &StopContainerResponse_v1{}
+
+func (o *StopContainerResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.StopContainerResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.StopContainerResponse)
+	}
+}
+func (o *StopContainerResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type RemoveContainerRequest_v1 struct {
+	inner *runtimeapi.RemoveContainerRequest
+}
+
+var _ RemoveContainerRequest = &RemoveContainerRequest_v1{}
+
+func (o *RemoveContainerRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.RemoveContainerRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.RemoveContainerRequest)
+	}
+}
+func (o *RemoveContainerRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *RemoveContainerRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *RemoveContainerRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+
+// ---
+
+type RemoveContainerResponse_v1 struct {
+	inner *runtimeapi.RemoveContainerResponse
+}
+
+var _ RemoveContainerResponse = &RemoveContainerResponse_v1{}
+
+func (o *RemoveContainerResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.RemoveContainerResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.RemoveContainerResponse)
+	}
+}
+func (o *RemoveContainerResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type ReopenContainerLogRequest_v1 struct {
+	inner *runtimeapi.ReopenContainerLogRequest
+}
+
+var _ ReopenContainerLogRequest = &ReopenContainerLogRequest_v1{}
+
+func (o *ReopenContainerLogRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ReopenContainerLogRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ReopenContainerLogRequest)
+	}
+}
+func (o *ReopenContainerLogRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *ReopenContainerLogRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *ReopenContainerLogRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+
+// ---
+
+type ReopenContainerLogResponse_v1 struct {
+	inner *runtimeapi.ReopenContainerLogResponse
+}
+
+var _ ReopenContainerLogResponse = &ReopenContainerLogResponse_v1{}
+
+func (o *ReopenContainerLogResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ReopenContainerLogResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ReopenContainerLogResponse)
+	}
+}
+func (o *ReopenContainerLogResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type ContainerStatusRequest_v1 struct {
+	inner *runtimeapi.ContainerStatusRequest
+}
+
+var _ ContainerStatusRequest = &ContainerStatusRequest_v1{}
+
+func (o *ContainerStatusRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ContainerStatusRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ContainerStatusRequest)
+	}
+}
+func (o *ContainerStatusRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *ContainerStatusRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *ContainerStatusRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+
+// ---
+
+type ContainerStatusResponse_v1 struct {
+	inner *runtimeapi.ContainerStatusResponse
+}
+
+var _ ContainerStatusResponse = &ContainerStatusResponse_v1{}
+
+func (o *ContainerStatusResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ContainerStatusResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ContainerStatusResponse)
+	}
+}
+func (o *ContainerStatusResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *ContainerStatusResponse_v1) Status() ContainerStatus {
+	if o.inner.Status == nil {
+		return nil
+	}
+	return &ContainerStatus_v1{o.inner.Status}
+}
+
+// ---
+
+type ContainerStatsRequest_v1 struct {
+	inner *runtimeapi.ContainerStatsRequest
+}
+
+var _ ContainerStatsRequest = &ContainerStatsRequest_v1{}
+
+func (o *ContainerStatsRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ContainerStatsRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ContainerStatsRequest)
+	}
+}
+func (o *ContainerStatsRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *ContainerStatsRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *ContainerStatsRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+
+// ---
+
+type ContainerStatsResponse_v1 struct {
+	inner *runtimeapi.ContainerStatsResponse
+}
+
+var _ ContainerStatsResponse = &ContainerStatsResponse_v1{}
+
+func (o *ContainerStatsResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ContainerStatsResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ContainerStatsResponse)
+	}
+}
+func (o *ContainerStatsResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *ContainerStatsResponse_v1) Stats() ContainerStats {
+	if o.inner.Stats == nil {
+		return nil
+	}
+	return &ContainerStats_v1{o.inner.Stats}
+}
+
+// ---
+
+type ExecSyncRequest_v1 struct {
+	inner *runtimeapi.ExecSyncRequest
+}
+
+var _ ExecSyncRequest = &ExecSyncRequest_v1{}
+var _ Redactable = &ExecSyncRequest_v1{}
+
+func (o *ExecSyncRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ExecSyncRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ExecSyncRequest)
+	}
+}
+func (o *ExecSyncRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *ExecSyncRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *ExecSyncRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+func (o *ExecSyncRequest_v1) Redacted(redactEnv func(string) bool) interface{} {
+	if len(o.inner.Cmd) <= 1 {
+		return o.inner
+	}
+	req := *o.inner
+	req.Cmd = redactCmd(req.Cmd)
+	return &req
+}
+
+// ---
+
+type ExecSyncResponse_v1 struct {
+	inner *runtimeapi.ExecSyncResponse
+}
+
+var _ ExecSyncResponse = &ExecSyncResponse_v1{}
+
+func (o *ExecSyncResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ExecSyncResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ExecSyncResponse)
+	}
+}
+func (o *ExecSyncResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type ExecRequest_v1 struct {
+	inner *runtimeapi.ExecRequest
+}
+
+var _ ExecRequest = &ExecRequest_v1{}
+var _ Redactable = &ExecRequest_v1{}
+
+func (o *ExecRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ExecRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ExecRequest)
+	}
+}
+func (o *ExecRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *ExecRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *ExecRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+func (o *ExecRequest_v1) Redacted(redactEnv func(string) bool) interface{} {
+	if len(o.inner.Cmd) <= 1 {
+		return o.inner
+	}
+	req := *o.inner
+	req.Cmd = redactCmd(req.Cmd)
+	return &req
+}
+
+// ---
+
+type ExecResponse_v1 struct {
+	inner *runtimeapi.ExecResponse
+}
+
+var _ ExecResponse = &ExecResponse_v1{}
+
+func (o *ExecResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ExecResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ExecResponse)
+	}
+}
+func (o *ExecResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *ExecResponse_v1) Url() string         { return o.inner.Url }
+func (o *ExecResponse_v1) SetUrl(url string)   { o.inner.Url = url }
+
+// ---
+
+type AttachRequest_v1 struct {
+	inner *runtimeapi.AttachRequest
+}
+
+var _ AttachRequest = &AttachRequest_v1{}
+
+func (o *AttachRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.AttachRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.AttachRequest)
+	}
+}
+func (o *AttachRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *AttachRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *AttachRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+
+// ---
+
+type AttachResponse_v1 struct {
+	inner *runtimeapi.AttachResponse
+}
+
+var _ AttachResponse = &AttachResponse_v1{}
+
+func (o *AttachResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.AttachResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.AttachResponse)
+	}
+}
+func (o *AttachResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *AttachResponse_v1) Url() string         { return o.inner.Url }
+func (o *AttachResponse_v1) SetUrl(url string)   { o.inner.Url = url }
+
+// ---
+
+type PortForwardRequest_v1 struct {
+	inner *runtimeapi.PortForwardRequest
+}
+
+var _ PortForwardRequest = &PortForwardRequest_v1{}
+
+func (o *PortForwardRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.PortForwardRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.PortForwardRequest)
+	}
+}
+func (o *PortForwardRequest_v1) Unwrap() interface{}  { return o.inner }
+func (o *PortForwardRequest_v1) PodSandboxId() string { return o.inner.PodSandboxId }
+func (o *PortForwardRequest_v1) SetPodSandboxId(podSandboxId string) {
+	o.inner.PodSandboxId = podSandboxId
+}
+
+// ---
+
+type PortForwardResponse_v1 struct {
+	inner *runtimeapi.PortForwardResponse
+}
+
+var _ PortForwardResponse = &PortForwardResponse_v1{}
+
+func (o *PortForwardResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.PortForwardResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.PortForwardResponse)
+	}
+}
+func (o *PortForwardResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *PortForwardResponse_v1) Url() string         { return o.inner.Url }
+func (o *PortForwardResponse_v1) SetUrl(url string)   { o.inner.Url = url }
+
+// ---
+
+type ListImagesRequest_v1 struct {
+	inner *runtimeapi.ListImagesRequest
+}
+
+var _ ListImagesRequest = &ListImagesRequest_v1{}
+
+func (o *ListImagesRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ListImagesRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ListImagesRequest)
+	}
+}
+func (o *ListImagesRequest_v1) Unwrap() interface{} { return o.inner }
+func (o *ListImagesRequest_v1) ImageFilter() string { return o.inner.Filter.GetImage().GetImage() }
+func (o *ListImagesRequest_v1) SetImageFilter(image string) {
+	if o.inner.Filter == nil {
+		o.inner.Filter = &runtimeapi.ImageFilter{
+			Image: &runtimeapi.ImageSpec{Image: image},
+		}
+	} else {
+		o.inner.Filter.Image = &runtimeapi.ImageSpec{Image: image}
+	}
+}
+
+// ---
+
+type ListImagesResponse_v1 struct {
+	inner *runtimeapi.ListImagesResponse
+}
+
+var _ ListImagesResponse = &ListImagesResponse_v1{}
+
+func (o *ListImagesResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ListImagesResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ListImagesResponse)
+	}
+}
+func (o *ListImagesResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *ListImagesResponse_v1) Items() []CRIObject {
+	var r []CRIObject
+	for _, image := range o.inner.Images {
+		r = append(r, &Image_v1{image})
+	}
+	return r
+}
+func (o *ListImagesResponse_v1) SetItems(items []CRIObject) {
+	o.inner.Images = nil
+	for _, wrapped := range items {
+		o.inner.Images = append(o.inner.Images, wrapped.Unwrap().(*runtimeapi.Image))
+	}
+}
+
+// ---
+
+type ImageStatusRequest_v1 struct {
+	inner *runtimeapi.ImageStatusRequest
+}
+
+var _ ImageStatusRequest = &ImageStatusRequest_v1{}
+
+func (o *ImageStatusRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ImageStatusRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ImageStatusRequest)
+	}
+}
+func (o *ImageStatusRequest_v1) Unwrap() interface{} { return o.inner }
+func (o *ImageStatusRequest_v1) Image() string       { return o.inner.Image.GetImage() }
+func (o *ImageStatusRequest_v1) SetImage(image string) {
+	o.inner.Image = &runtimeapi.ImageSpec{Image: image}
+}
+
+// ---
+
+type ImageStatusResponse_v1 struct {
+	inner *runtimeapi.ImageStatusResponse
+}
+
+var _ ImageStatusResponse = &ImageStatusResponse_v1{}
+
+func (o *ImageStatusResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ImageStatusResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ImageStatusResponse)
+	}
+}
+func (o *ImageStatusResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *ImageStatusResponse_v1) Image() Image {
+	if o.inner.Image == nil {
+		return nil
+	}
+	return &Image_v1{o.inner.Image}
+}
+func (o *ImageStatusResponse_v1) SetImage(image Image) {
+	o.inner.Image = image.Unwrap().(*runtimeapi.Image)
+}
+
+// ---
+
+type PullImageRequest_v1 struct {
+	inner *runtimeapi.PullImageRequest
+}
+
+var _ PullImageRequest = &PullImageRequest_v1{}
+var _ Redactable = &PullImageRequest_v1{}
+
+func (o *PullImageRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.PullImageRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.PullImageRequest)
+	}
+}
+func (o *PullImageRequest_v1) Unwrap() interface{} { return o.inner }
+func (o *PullImageRequest_v1) Image() string       { return o.inner.Image.GetImage() }
+func (o *PullImageRequest_v1) SetImage(image string) {
+	o.inner.Image = &runtimeapi.ImageSpec{Image: image}
+}
+
+func (o *PullImageRequest_v1) Redacted(redactEnv func(string) bool) interface{} {
+	if o.inner.Auth == nil {
+		return o.inner
+	}
+	req := *o.inner
+	auth := *req.Auth
+	auth.Password = redactString(auth.Password)
+	auth.Auth = redactString(auth.Auth)
+	auth.IdentityToken = redactString(auth.IdentityToken)
+	auth.RegistryToken = redactString(auth.RegistryToken)
+	req.Auth = &auth
+	return &req
+}
+
+// ---
+
+type PullImageResponse_v1 struct {
+	inner *runtimeapi.PullImageResponse
+}
+
+var _ PullImageResponse = &PullImageResponse_v1{}
+
+func (o *PullImageResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.PullImageResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.PullImageResponse)
+	}
+}
+func (o *PullImageResponse_v1) Unwrap() interface{}   { return o.inner }
+func (o *PullImageResponse_v1) Image() string         { return o.inner.ImageRef }
+func (o *PullImageResponse_v1) SetImage(image string) { o.inner.ImageRef = image }
+
+// ---
+
+type RemoveImageRequest_v1 struct {
+	inner *runtimeapi.RemoveImageRequest
+}
+
+var _ RemoveImageRequest = &RemoveImageRequest_v1{}
+
+func (o *RemoveImageRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.RemoveImageRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.RemoveImageRequest)
+	}
+}
+func (o *RemoveImageRequest_v1) Unwrap() interface{} { return o.inner }
+func (o *RemoveImageRequest_v1) Image() string       { return o.inner.Image.GetImage() }
+func (o *RemoveImageRequest_v1) SetImage(image string) {
+	o.inner.Image = &runtimeapi.ImageSpec{Image: image}
+}
+
+// ---
+
+type RemoveImageResponse_v1 struct {
+	inner *runtimeapi.RemoveImageResponse
+}
+
+var _ RemoveImageResponse = &RemoveImageResponse_v1{}
+
+func (o *RemoveImageResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.RemoveImageResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.RemoveImageResponse)
+	}
+}
+func (o *RemoveImageResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type ImageFsInfoRequest_v1 struct {
+	inner *runtimeapi.ImageFsInfoRequest
+}
+
+var _ ImageFsInfoRequest = &ImageFsInfoRequest_v1{}
+
+func (o *ImageFsInfoRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ImageFsInfoRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.ImageFsInfoRequest)
+	}
+}
+func (o *ImageFsInfoRequest_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+type ImageFsInfoResponse_v1 struct {
+	inner *runtimeapi.ImageFsInfoResponse
+}
+
+var _ ImageFsInfoResponse = &ImageFsInfoResponse_v1{}
+
+func (o *ImageFsInfoResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.ImageFsInfoResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.ImageFsInfoResponse)
+	}
+}
+func (o *ImageFsInfoResponse_v1) Unwrap() interface{} { return o.inner }
+func (o *ImageFsInfoResponse_v1) Items() []CRIObject {
+	var r []CRIObject
+	for _, fs := range o.inner.ImageFilesystems {
+		r = append(r, &FilesystemUsage_v1{fs})
+	}
+	return r
+}
+func (o *ImageFsInfoResponse_v1) SetItems(items []CRIObject) {
+	o.inner.ImageFilesystems = nil
+	for _, wrapped := range items {
+		o.inner.ImageFilesystems = append(o.inner.ImageFilesystems, wrapped.Unwrap().(*runtimeapi.FilesystemUsage))
+	}
+}
+
+// --- 1.8+ only ---
+
+type UpdateContainerResourcesRequest_v1 struct {
+	inner *runtimeapi.UpdateContainerResourcesRequest
+}
+
+var _ UpdateContainerResourcesRequest = &UpdateContainerResourcesRequest_v1{}
+
+func (o *UpdateContainerResourcesRequest_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.UpdateContainerResourcesRequest{}
+	} else {
+		o.inner = v.(*runtimeapi.UpdateContainerResourcesRequest)
+	}
+}
+func (o *UpdateContainerResourcesRequest_v1) Unwrap() interface{}      { return o.inner }
+func (o *UpdateContainerResourcesRequest_v1) ContainerId() string      { return o.inner.ContainerId }
+func (o *UpdateContainerResourcesRequest_v1) SetContainerId(id string) { o.inner.ContainerId = id }
+
+// --- 1.8+ only ---
+
+type UpdateContainerResourcesResponse_v1 struct {
+	inner *runtimeapi.UpdateContainerResourcesResponse
+}
+
+var _ UpdateContainerResourcesResponse = &UpdateContainerResourcesResponse_v1{}
+
+func (o *UpdateContainerResourcesResponse_v1) Wrap(v interface{}) {
+	if v == nil {
+		o.inner = &runtimeapi.UpdateContainerResourcesResponse{}
+	} else {
+		o.inner = v.(*runtimeapi.UpdateContainerResourcesResponse)
+	}
+}
+func (o *UpdateContainerResourcesResponse_v1) Unwrap() interface{} { return o.inner }
+
+// ---
+
+var criV1typeMatcher = newTypeMatcher()
+
+func init() {
+	criV1typeMatcher.registerTypes(
+		&PodSandbox_v1{},
+		&Container_v1{},
+		&Image_v1{},
+		&PodSandboxStatus_v1{},
+		&ContainerStatus_v1{},
+		&ContainerStats_v1{},
+		&FilesystemUsage_v1{},
+		&VersionRequest_v1{},
+		&VersionResponse_v1{},
+		&StatusRequest_v1{},
+		&StatusResponse_v1{},
+		&UpdateRuntimeConfigRequest_v1{},
+		&UpdateRuntimeConfigResponse_v1{},
+		&RunPodSandboxRequest_v1{},
+		&RunPodSandboxResponse_v1{},
+		&ListPodSandboxRequest_v1{},
+		&ListPodSandboxResponse_v1{},
+		&StopPodSandboxRequest_v1{},
+		&StopPodSandboxResponse_v1{},
+		&RemovePodSandboxRequest_v1{},
+		&RemovePodSandboxResponse_v1{},
+		&PodSandboxStatusRequest_v1{},
+		&PodSandboxStatusResponse_v1{},
+		&CreateContainerRequest_v1{},
+		&CreateContainerResponse_v1{},
+		&ListContainersRequest_v1{},
+		&ListContainersResponse_v1{},
+		&ListContainerStatsRequest_v1{},
+		&ListContainerStatsResponse_v1{},
+		&StartContainerRequest_v1{},
+		&StartContainerResponse_v1{},
+		&StopContainerRequest_v1{},
+		&StopContainerResponse_v1{},
+		&RemoveContainerRequest_v1{},
+		&RemoveContainerResponse_v1{},
+		&ReopenContainerLogRequest_v1{},
+		&ReopenContainerLogResponse_v1{},
+		&ContainerStatusRequest_v1{},
+		&ContainerStatusResponse_v1{},
+		&ContainerStatsRequest_v1{},
+		&ContainerStatsResponse_v1{},
+		&ExecSyncRequest_v1{},
+		&ExecSyncResponse_v1{},
+		&ExecRequest_v1{},
+		&ExecResponse_v1{},
+		&AttachRequest_v1{},
+		&AttachResponse_v1{},
+		&PortForwardRequest_v1{},
+		&PortForwardResponse_v1{},
+		&ListImagesRequest_v1{},
+		&ListImagesResponse_v1{},
+		&ImageStatusRequest_v1{},
+		&ImageStatusResponse_v1{},
+		&PullImageRequest_v1{},
+		&PullImageResponse_v1{},
+		&RemoveImageRequest_v1{},
+		&RemoveImageResponse_v1{},
+		&ImageFsInfoRequest_v1{},
+		&ImageFsInfoResponse_v1{},
+		&UpdateContainerResourcesRequest_v1{},
+		&UpdateContainerResourcesResponse_v1{},
+	)
+}
+
+// CRIv1 denotes the CRI version v1 (Kubernetes 1.20+)
+type CRIv1 struct{}
+
+var _ CRIVersion = &CRIv1{}
+
+func (c *CRIv1) Register(server *grpc.Server) {
+	runtimeapi.RegisterDummyRuntimeServiceServer(server)
+	runtimeapi.RegisterDummyImageServiceServer(server)
+}
+
+func (c *CRIv1) ProbeRequest() (interface{}, interface{}) {
+	return &runtimeapi.VersionRequest{}, &runtimeapi.VersionResponse{}
+}
+
+func (c *CRIv1) WrapObject(o interface{}) (CRIObject, CRIObject, error) {
+	return wrapUsingMatcher(criV1typeMatcher, o)
+}
+
+func (c *CRIv1) ProtoPackage() string { return "runtime.v1" }
@@ -17,13 +17,16 @@ limitations under the License.
 package proxy
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
-	"os"
-	"syscall"
+	"time"
 
+	"github.com/golang/glog"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+
+	"github.com/Mirantis/criproxy/pkg/utils"
 )
 
 // Interceptor specifies an interceptor to be used by gRPC server.
@@ -42,6 +45,8 @@ type Interceptor interface {
 type Server struct {
 	server       *grpc.Server
 	interceptors []Interceptor
+	tlsConfig    *tls.Config
+	socketPerms  utils.SocketPermissions
 }
 
 // NewServer makes a new gRPC server.
@@ -68,17 +73,36 @@ func (s *Server) intercept(ctx context.Context, req interface{}, info *grpc.Unar
 	return nil, fmt.Errorf("no interceptor for method %q", info.FullMethod)
 }
 
-// Serve makes the server listen on the specified addr. If readyCh is
-// not nil, it'll be closed when the server is ready to accept
-// connections.
+// SetTlsConfig sets the TLS config to be used for tls:// addresses.
+// It must be called before Serve.
+func (s *Server) SetTlsConfig(tlsConfig *tls.Config) {
+	s.tlsConfig = tlsConfig
+}
+
+// SetSocketPermissions sets the mode and the group of the unix
+// socket created by Serve. It must be called before Serve.
+func (s *Server) SetSocketPermissions(perms utils.SocketPermissions) {
+	s.socketPerms = perms
+}
+
+// Serve makes the server listen on the specified addr, which may
+// be a unix socket path or a unix://, tcp:// or tls:// URL. If
+// readyCh is not nil, it'll be closed when the server is ready to
+// accept connections.
 func (s *Server) Serve(addr string, readyCh chan struct{}) error {
-	if err := syscall.Unlink(addr); err != nil && !os.IsNotExist(err) {
-		return err
-	}
-	ln, err := net.Listen("unix", addr)
+	ln, err := utils.Listen(addr, s.tlsConfig, s.socketPerms)
 	if err != nil {
 		return err
 	}
+	return s.ServeListener(ln, readyCh)
+}
+
+// ServeListener makes the server accept the connections on the
+// specified listener, e.g. the one passed via systemd socket
+// activation. The listener is closed when the server stops. If
+// readyCh is not nil, it'll be closed when the server is ready to
+// accept connections.
+func (s *Server) ServeListener(ln net.Listener, readyCh chan struct{}) error {
 	defer ln.Close()
 	if readyCh != nil {
 		close(readyCh)
@@ -86,6 +110,33 @@ func (s *Server) Serve(addr string, readyCh chan struct{}) error {
 	return s.server.Serve(ln)
 }
 
+// Shutdown stops the server gracefully. It stops accepting new
+// connections and waits for the requests that are in flight to be
+// finished, but no longer than for the specified timeout, after
+// which the remaining requests are cancelled. After that, the
+// connections to the runtimes are closed. Shutdown returns true if
+// all of the requests were finished before the timeout.
+func (s *Server) Shutdown(timeout time.Duration) bool {
+	stoppedCh := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stoppedCh)
+	}()
+	finished := true
+	select {
+	case <-stoppedCh:
+	case <-time.After(timeout):
+		glog.Warningf("Some requests are still in flight after %v, cancelling them", timeout)
+		finished = false
+		s.server.Stop()
+		<-stoppedCh
+	}
+	for _, intc := range s.interceptors {
+		intc.Stop()
+	}
+	return finished
+}
+
 // Stop stops the server.
 func (s *Server) Stop() {
 	for _, intc := range s.interceptors {
@@ -0,0 +1,86 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+)
+
+// ReadinessReport is returned by /readyz endpoint.
+type ReadinessReport struct {
+	// Ready is true if the primary runtime is connected via at
+	// least one of the CRI versions served by the proxy.
+	Ready bool `json:"ready"`
+	// Runtimes lists the status of the connections to the
+	// runtimes for each CRI version.
+	Runtimes []RuntimeStatus `json:"runtimes"`
+}
+
+// HealthHandler serves /healthz and /readyz endpoints.
+type HealthHandler struct {
+	proxies []*RuntimeProxy
+}
+
+var _ http.Handler = &HealthHandler{}
+
+// NewHealthHandler creates a HealthHandler for the specified proxies.
+func NewHealthHandler(proxies []*RuntimeProxy) *HealthHandler {
+	return &HealthHandler{proxies: proxies}
+}
+
+// Readiness returns the readiness report for the proxies. As the
+// runtimes are connected lazily, it also makes the proxies start
+// connecting to the runtimes that are offline.
+func (h *HealthHandler) Readiness() ReadinessReport {
+	report := ReadinessReport{Runtimes: []RuntimeStatus{}}
+	for _, p := range h.proxies {
+		p.ConnectOffline()
+		statuses := p.RuntimeStatuses()
+		if len(statuses) > 0 && statuses[0].State == clientStateConnected.String() {
+			report.Ready = true
+		}
+		report.Runtimes = append(report.Runtimes, statuses...)
+	}
+	return report
+}
+
+// ServeHTTP implements ServeHTTP method of http.Handler interface.
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/healthz":
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok\n"))
+	case "/readyz":
+		report := h.Readiness()
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			glog.Errorf("Failed to marshal the readiness report: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(append(data, '\n'))
+	default:
+		http.NotFound(w, r)
+	}
+}
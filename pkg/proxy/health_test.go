@@ -0,0 +1,95 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	proxytest "github.com/Mirantis/criproxy/pkg/proxy/testing"
+)
+
+func getReadiness(t *testing.T, url string) (int, ReadinessReport) {
+	resp, err := http.Get(url + "/readyz")
+	if err != nil {
+		t.Fatalf("GET /readyz: %v", err)
+	}
+	defer resp.Body.Close()
+	var report ReadinessReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("error decoding the readiness report: %v", err)
+	}
+	return resp.StatusCode, report
+}
+
+func TestHealthHandler(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer110,
+		proxytest.NewFakeCriServer110,
+	})
+	defer tester.stop()
+	server := httptest.NewServer(NewHealthHandler(tester.proxies))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("bad /healthz status code %d", resp.StatusCode)
+	}
+
+	// the runtimes aren't started yet
+	if code, report := getReadiness(t, server.URL); code != http.StatusServiceUnavailable || report.Ready {
+		t.Errorf("the proxy is reported as ready while no runtimes are running (status code %d)", code)
+	}
+
+	// start the primary runtime only
+	tester.startServers(t, 0)
+	var report ReadinessReport
+	for i := 0; ; i++ {
+		if i == 100 {
+			t.Fatalf("the proxy didn't become ready: %#v", report)
+		}
+		var code int
+		code, report = getReadiness(t, server.URL)
+		if code == http.StatusOK && report.Ready && len(report.Runtimes) == 6 &&
+			report.Runtimes[0].State == "connected" && report.Runtimes[2].State == "connected" &&
+			report.Runtimes[4].State == "connected" && report.Runtimes[1].LastError != "" &&
+			report.Runtimes[3].LastError != "" && report.Runtimes[5].LastError != "" {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	for n, api := range []string{"runtime", "runtime", "runtime.v1alpha2", "runtime.v1alpha2", "runtime.v1", "runtime.v1"} {
+		status := report.Runtimes[n]
+		expectedName, expectedSocket := "primary", fakeCriSocketPath1
+		if n%2 == 1 {
+			expectedName, expectedSocket = "alt", fakeCriSocketPath2
+		}
+		if status.Api != api || status.Name != expectedName || status.Socket != expectedSocket {
+			t.Errorf("bad runtime status #%d: %#v", n, status)
+		}
+		if n%2 == 1 && status.State == "connected" {
+			t.Errorf("the alt runtime is reported as connected: %#v", status)
+		}
+	}
+}
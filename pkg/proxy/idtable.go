@@ -0,0 +1,253 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// idTable is a persistent table that maps the ids of the pod
+// sandboxes and containers of the secondary runtimes to the ids of
+// these runtimes. It's stored as a JSON file. The table is loaded
+// lazily upon the first access.
+type idTable struct {
+	sync.Mutex
+	path   string
+	loaded bool
+	ids    map[string]string
+	// added holds the time when the ids were added to the table
+	// by this process. The ids loaded from the file are not there.
+	added map[string]time.Time
+	// lists holds the latest complete lists of the pod sandbox
+	// and container ids for each runtime
+	lists map[string]map[idKind]idList
+}
+
+// idKind denotes the kind of the objects listed by the runtime
+type idKind int
+
+const (
+	sandboxIds idKind = iota
+	containerIds
+)
+
+// idList is a complete list of pod sandbox or container ids
+// reported by the runtime
+type idList struct {
+	ids      map[string]bool
+	listedAt time.Time
+}
+
+func newIdTable(path string) *idTable {
+	return &idTable{path: path}
+}
+
+var idTables = struct {
+	sync.Mutex
+	tables map[string]*idTable
+}{tables: make(map[string]*idTable)}
+
+// getIdTable returns the id table for the specified path. The table
+// is shared between all of the RuntimeProxy instances and survives
+// config reloads.
+func getIdTable(path string) *idTable {
+	idTables.Lock()
+	defer idTables.Unlock()
+	t, found := idTables.tables[path]
+	if !found {
+		t = newIdTable(path)
+		idTables.tables[path] = t
+	}
+	return t
+}
+
+func (t *idTable) loadNonLocked() {
+	if t.loaded {
+		return
+	}
+	t.loaded = true
+	t.ids = make(map[string]string)
+	t.added = make(map[string]time.Time)
+	t.lists = make(map[string]map[idKind]idList)
+	data, err := ioutil.ReadFile(t.path)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case err != nil:
+		glog.Errorf("Can't read id table %q: %v", t.path, err)
+		return
+	}
+	if err := json.Unmarshal(data, &t.ids); err != nil {
+		// keep the corrupted table for investigation
+		// and so it's not overwritten by the next save
+		badPath := t.path + ".bad"
+		if renameErr := os.Rename(t.path, badPath); renameErr != nil {
+			glog.Errorf("Can't rename the corrupted id table %q to %q: %v", t.path, badPath, renameErr)
+		}
+		glog.Errorf("Can't parse id table %q, moved it to %q and starting with an empty one: %v", t.path, badPath, err)
+		t.ids = make(map[string]string)
+	}
+}
+
+func (t *idTable) saveNonLocked() error {
+	data, err := json.Marshal(t.ids)
+	if err != nil {
+		return fmt.Errorf("error marshalling id table: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("can't create the directory for %q: %v", t.path, err)
+	}
+	// write a temporary file, sync it and rename it, then sync
+	// the directory, so the table doesn't get corrupted if the
+	// proxy dies or the node crashes while saving it
+	tmpPath := t.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("can't create %q: %v", tmpPath, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("can't write %q: %v", tmpPath, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("can't sync %q: %v", tmpPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("can't close %q: %v", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, t.path); err != nil {
+		return fmt.Errorf("can't rename %q to %q: %v", tmpPath, t.path, err)
+	}
+	return syncDir(filepath.Dir(t.path))
+}
+
+// syncDir makes the changes in the directory entries,
+// such as file renames, durable.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("can't open directory %q: %v", path, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("can't sync directory %q: %v", path, err)
+	}
+	return nil
+}
+
+// lookup returns the id of the runtime that owns the object with
+// the specified id. found is false if the id isn't in the table.
+func (t *idTable) lookup(id string) (runtimeId string, found bool) {
+	t.Lock()
+	defer t.Unlock()
+	t.loadNonLocked()
+	runtimeId, found = t.ids[id]
+	return
+}
+
+// set records that the object with the specified id is owned by
+// the specified runtime.
+func (t *idTable) set(id, runtimeId string) {
+	t.Lock()
+	defer t.Unlock()
+	t.loadNonLocked()
+	if oldRuntimeId, found := t.ids[id]; found && oldRuntimeId == runtimeId {
+		return
+	}
+	t.ids[id] = runtimeId
+	t.added[id] = time.Now()
+	if err := t.saveNonLocked(); err != nil {
+		glog.Errorf("Error saving id table: %v", err)
+	}
+}
+
+// sync updates the table using the complete list of the pod sandbox
+// or container ids of the runtime that was requested at listedAt.
+// The missing ids are added to the table. The ids of the runtime
+// that are absent from the latest lists of both pod sandboxes and
+// containers are removed from the table unless they were added after
+// either of these lists was requested, so the objects which were
+// removed together with their pod sandbox or behind the proxy's back
+// don't stay in the table forever. The table is saved at most once.
+func (t *idTable) sync(runtimeId string, kind idKind, ids []string, listedAt time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	t.loadNonLocked()
+	changed := false
+	list := idList{ids: make(map[string]bool), listedAt: listedAt}
+	for _, id := range ids {
+		list.ids[id] = true
+		if oldRuntimeId, found := t.ids[id]; !found || oldRuntimeId != runtimeId {
+			t.ids[id] = runtimeId
+			t.added[id] = time.Now()
+			changed = true
+		}
+	}
+	if t.lists[runtimeId] == nil {
+		t.lists[runtimeId] = make(map[idKind]idList)
+	}
+	t.lists[runtimeId][kind] = list
+
+	sandboxes, haveSandboxes := t.lists[runtimeId][sandboxIds]
+	containers, haveContainers := t.lists[runtimeId][containerIds]
+	if haveSandboxes && haveContainers {
+		since := sandboxes.listedAt
+		if containers.listedAt.Before(since) {
+			since = containers.listedAt
+		}
+		for id, idRuntimeId := range t.ids {
+			if idRuntimeId != runtimeId || sandboxes.ids[id] || containers.ids[id] || !t.added[id].Before(since) {
+				continue
+			}
+			glog.V(3).Infof("Removing stale id %q of runtime %q from the id table", id, runtimeId)
+			delete(t.ids, id)
+			delete(t.added, id)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return
+	}
+	if err := t.saveNonLocked(); err != nil {
+		glog.Errorf("Error saving id table: %v", err)
+	}
+}
+
+// remove removes the specified id from the table.
+func (t *idTable) remove(id string) {
+	t.Lock()
+	defer t.Unlock()
+	t.loadNonLocked()
+	if _, found := t.ids[id]; !found {
+		return
+	}
+	delete(t.ids, id)
+	delete(t.added, id)
+	if err := t.saveNonLocked(); err != nil {
+		glog.Errorf("Error saving id table: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/golang/glog"
+)
+
+const (
+	defaultMountInfoPath = "/proc/self/mountinfo"
+	defaultUuidDir       = "/dev/disk/by-uuid"
+)
+
+// imageFsIds is used to restore the ids of the image filesystems
+// when converting ImageFsInfo responses between CRI 1.9 and the
+// newer CRI versions
+var imageFsIds = newFsIdMapper(defaultMountInfoPath, defaultUuidDir)
+
+// mountInfo describes an entry of /proc/PID/mountinfo
+type mountInfo struct {
+	// device is the "major:minor" device number
+	device string
+	// root is the root of the mount within the filesystem
+	root       string
+	mountPoint string
+	source     string
+}
+
+// unescapeMountInfo decodes the octal escapes like \040 that
+// are used for the spaces and some other chars in mountinfo
+func unescapeMountInfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var r []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				r = append(r, byte(c))
+				i += 3
+				continue
+			}
+		}
+		r = append(r, s[i])
+	}
+	return string(r)
+}
+
+func parseMountInfo(r io.Reader) ([]mountInfo, error) {
+	var mounts []mountInfo
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// the optional fields are terminated by a single hyphen
+		sep := -1
+		for n := 6; n < len(fields); n++ {
+			if fields[n] == "-" {
+				sep = n
+				break
+			}
+		}
+		if sep < 0 || sep+2 >= len(fields) {
+			return nil, fmt.Errorf("bad mountinfo line: %q", scanner.Text())
+		}
+		mounts = append(mounts, mountInfo{
+			device:     fields[2],
+			root:       unescapeMountInfo(fields[3]),
+			mountPoint: unescapeMountInfo(fields[4]),
+			source:     unescapeMountInfo(fields[sep+2]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// fsIdMapper maps the mount points of the filesystems which are
+// used as the filesystem ids by CRI 1.10+ to the UUIDs of the
+// devices used by CRI 1.9 and vice versa. The successfully resolved
+// ids are cached, so the ids stay stable for each runtime even
+// if the device can't be resolved later.
+type fsIdMapper struct {
+	sync.Mutex
+	mountInfoPath string
+	uuidDir       string
+	uuids         map[string]string
+	mountPoints   map[string]string
+}
+
+func newFsIdMapper(mountInfoPath, uuidDir string) *fsIdMapper {
+	return &fsIdMapper{
+		mountInfoPath: mountInfoPath,
+		uuidDir:       uuidDir,
+		uuids:         make(map[string]string),
+		mountPoints:   make(map[string]string),
+	}
+}
+
+func (m *fsIdMapper) mounts() ([]mountInfo, error) {
+	f, err := os.Open(m.mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
+
+// deviceNumber returns the "major:minor" number of a block
+// device, or "" if the path isn't a device
+func deviceNumber(path string) string {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil || st.Mode&syscall.S_IFMT != syscall.S_IFBLK {
+		return ""
+	}
+	dev := uint64(st.Rdev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	return fmt.Sprintf("%d:%d", major, minor)
+}
+
+// sameDevice returns true if the device path refers to
+// the device of the mount
+func sameDevice(devicePath string, mount mountInfo) bool {
+	if dev := deviceNumber(devicePath); dev != "" && dev == mount.device {
+		return true
+	}
+	source, err := filepath.EvalSymlinks(mount.source)
+	return err == nil && source == devicePath
+}
+
+func (m *fsIdMapper) lookupUuid(mountPoint string) (string, error) {
+	mounts, err := m.mounts()
+	if err != nil {
+		return "", err
+	}
+	// the image directory reported by the runtime is not necessarily
+	// a mount point itself, so let's find the mount that contains it.
+	// The later mounts hide the earlier ones on the same mount point
+	path := filepath.Clean(mountPoint)
+	var found *mountInfo
+	for n, mount := range mounts {
+		if path != mount.mountPoint && mount.mountPoint != "/" && !strings.HasPrefix(path, mount.mountPoint+"/") {
+			continue
+		}
+		if found == nil || len(mount.mountPoint) >= len(found.mountPoint) {
+			found = &mounts[n]
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("no mount found for %q", mountPoint)
+	}
+	entries, err := ioutil.ReadDir(m.uuidDir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		devicePath, err := filepath.EvalSymlinks(filepath.Join(m.uuidDir, entry.Name()))
+		if err == nil && sameDevice(devicePath, *found) {
+			return entry.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("no device UUID found for %q mounted on %q", found.source, found.mountPoint)
+}
+
+// preferMount returns true if the mount a should be used instead of
+// the mount b of the same device. The mounts of the root of the
+// filesystem are preferred over the bind mounts of its subdirectories,
+// and then the shorter mount points are preferred.
+func preferMount(a, b mountInfo) bool {
+	if (a.root == "/") != (b.root == "/") {
+		return a.root == "/"
+	}
+	return len(a.mountPoint) < len(b.mountPoint)
+}
+
+func (m *fsIdMapper) lookupMountPoint(uuid string) (string, error) {
+	if strings.Contains(uuid, "/") {
+		return "", fmt.Errorf("bad UUID %q", uuid)
+	}
+	devicePath, err := filepath.EvalSymlinks(filepath.Join(m.uuidDir, uuid))
+	if err != nil {
+		return "", err
+	}
+	mounts, err := m.mounts()
+	if err != nil {
+		return "", err
+	}
+	var found *mountInfo
+	for n, mount := range mounts {
+		if sameDevice(devicePath, mount) && (found == nil || preferMount(mount, *found)) {
+			found = &mounts[n]
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("device %q is not mounted", devicePath)
+	}
+	return found.mountPoint, nil
+}
+
+// uuid returns the UUID of the device holding the filesystem
+// which contains the specified directory, or "" if it can't be
+// determined.
+func (m *fsIdMapper) uuid(mountPoint string) string {
+	m.Lock()
+	defer m.Unlock()
+	if uuid, found := m.uuids[mountPoint]; found {
+		return uuid
+	}
+	uuid, err := m.lookupUuid(mountPoint)
+	if err != nil {
+		glog.V(2).Infof("Can't find the device UUID for image filesystem %q: %v", mountPoint, err)
+		return ""
+	}
+	m.uuids[mountPoint] = uuid
+	return uuid
+}
+
+// mountPoint returns the mount point of the filesystem on the
+// device with the specified UUID, or "" if it can't be determined.
+func (m *fsIdMapper) mountPoint(uuid string) string {
+	m.Lock()
+	defer m.Unlock()
+	if mountPoint, found := m.mountPoints[uuid]; found {
+		return mountPoint
+	}
+	mountPoint, err := m.lookupMountPoint(uuid)
+	if err != nil {
+		glog.V(2).Infof("Can't find the mount point for image filesystem UUID %q: %v", uuid, err)
+		return ""
+	}
+	m.mountPoints[uuid] = mountPoint
+	return mountPoint
+}
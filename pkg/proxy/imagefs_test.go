@@ -0,0 +1,107 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeFakeMounts makes a mountinfo file and a directory with the
+// device UUID links for fsIdMapper. The "devices" are plain files.
+// uuid-a is the root filesystem and uuid-b is mounted on
+// /var/lib/images, with its subdirectory also bind-mounted on /mnt/b.
+func makeFakeMounts(t *testing.T) (dir, mountInfoPath, uuidDir string) {
+	dir, err := ioutil.TempDir("", "criproxy-mounts")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	uuidDir = filepath.Join(dir, "by-uuid")
+	for _, name := range []string{"dev", "by-uuid"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatalf("Mkdir(): %v", err)
+		}
+	}
+	for uuid, dev := range map[string]string{
+		"uuid-a": "sda1",
+		"uuid-b": "sdb1",
+		"uuid-c": "sdc1",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "dev", dev), nil, 0644); err != nil {
+			t.Fatalf("WriteFile(): %v", err)
+		}
+		if err := os.Symlink("../dev/"+dev, filepath.Join(uuidDir, uuid)); err != nil {
+			t.Fatalf("Symlink(): %v", err)
+		}
+	}
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	mountInfo := fmt.Sprintf(
+		"20 1 8:1 / / rw,relatime shared:1 - ext4 %[1]s/dev/sda1 rw\n"+
+			"25 20 0:5 / /proc rw,nosuid - proc proc rw\n"+
+			"31 20 8:17 /sub /mnt/b rw,relatime shared:3 - xfs %[1]s/dev/sdb1 rw\n"+
+			"30 20 8:17 / /var/lib/images rw,relatime shared:2 master:1 - xfs %[1]s/dev/sdb1 rw\n"+
+			"32 20 8:33 / /mnt/with\\040space rw - ext4 %[1]s/dev/sdc1 rw\n", dir)
+	if err := ioutil.WriteFile(mountInfoPath, []byte(mountInfo), 0644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	return dir, mountInfoPath, uuidDir
+}
+
+func TestFsIdMapper(t *testing.T) {
+	dir, mountInfoPath, uuidDir := makeFakeMounts(t)
+	defer os.RemoveAll(dir)
+	m := newFsIdMapper(mountInfoPath, uuidDir)
+
+	for _, tc := range []struct{ mountPoint, uuid string }{
+		{"/var/lib/images", "uuid-b"},
+		{"/var/lib/images/containerd/", "uuid-b"},
+		{"/var/lib/imagesx", "uuid-a"},
+		{"/var/lib/docker", "uuid-a"},
+		{"/mnt/with space/images", "uuid-c"},
+		{"/proc/images", ""},
+	} {
+		if uuid := m.uuid(tc.mountPoint); uuid != tc.uuid {
+			t.Errorf("uuid(%q): expected %q, got %q", tc.mountPoint, tc.uuid, uuid)
+		}
+	}
+
+	for _, tc := range []struct{ uuid, mountPoint string }{
+		{"uuid-a", "/"},
+		{"uuid-b", "/var/lib/images"},
+		{"uuid-c", "/mnt/with space"},
+		{"uuid-d", ""},
+		{"../dev/sda1", ""},
+	} {
+		if mountPoint := m.mountPoint(tc.uuid); mountPoint != tc.mountPoint {
+			t.Errorf("mountPoint(%q): expected %q, got %q", tc.uuid, tc.mountPoint, mountPoint)
+		}
+	}
+
+	// the resolved ids are kept if the mounts can't be read anymore
+	if err := os.Remove(mountInfoPath); err != nil {
+		t.Fatalf("Remove(): %v", err)
+	}
+	if uuid := m.uuid("/var/lib/images"); uuid != "uuid-b" {
+		t.Errorf("uuid(): expected the cached uuid-b, got %q", uuid)
+	}
+	if mountPoint := m.mountPoint("uuid-b"); mountPoint != "/var/lib/images" {
+		t.Errorf("mountPoint(): expected the cached /var/lib/images, got %q", mountPoint)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Mirantis/criproxy/pkg/utils"
+)
+
+const activationHelperEnv = "CRIPROXY_TEST_ACTIVATION_HELPER"
+
+func TestSocketPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "criproxy-socket")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "criproxy.sock")
+
+	server := NewServer(nil, nil)
+	defer server.Stop()
+	server.SetSocketPermissions(utils.SocketPermissions{
+		Mode:  0660,
+		Group: strconv.Itoa(os.Getgid()),
+	})
+	startServer(t, server, "unix://"+path)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat(): %v", err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		t.Errorf("%q is not a socket", path)
+	}
+	if fi.Mode().Perm() != 0660 {
+		t.Errorf("bad socket mode %o", fi.Mode().Perm())
+	}
+	if gid := fi.Sys().(*syscall.Stat_t).Gid; int(gid) != os.Getgid() {
+		t.Errorf("bad socket group %d instead of %d", gid, os.Getgid())
+	}
+
+	if _, err := utils.Listen(filepath.Join(dir, "another.sock"), nil, utils.SocketPermissions{
+		Mode:  0660,
+		Group: "no-such-group-for-criproxy",
+	}); err == nil {
+		t.Errorf("didn't get an error for a nonexistent group")
+	}
+}
+
+// runActivationHelper is run in a child process which gets the
+// listening socket as fd 3, like the processes started by systemd
+// via socket activation. It says hello to the first client.
+func runActivationHelper() {
+	// the pid of the child isn't known before it's started
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	ln, err := utils.ActivationListener()
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "ActivationListener(): %v\n", err)
+		os.Exit(1)
+	case ln == nil:
+		fmt.Fprintf(os.Stderr, "no listener\n")
+		os.Exit(1)
+	case os.Getenv("LISTEN_FDS") != "":
+		fmt.Fprintf(os.Stderr, "LISTEN_FDS not unset\n")
+		os.Exit(1)
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Accept(): %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(conn, "hello\n")
+	conn.Close()
+	os.Exit(0)
+}
+
+func TestSocketActivation(t *testing.T) {
+	if os.Getenv(activationHelperEnv) != "" {
+		runActivationHelper()
+	}
+
+	dir, err := ioutil.TempDir("", "criproxy-socket")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "criproxy.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen(): %v", err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.UnixListener).File()
+	if err != nil {
+		t.Fatalf("File(): %v", err)
+	}
+	defer f.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSocketActivation$")
+	cmd.Env = append(os.Environ(), activationHelperEnv+"=1", "LISTEN_FDS=1")
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("can't start the helper: %v", err)
+	}
+
+	conn, err := net.DialTimeout("unix", path, 10*time.Second)
+	if err != nil {
+		t.Fatalf("Dial(): %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Errorf("error reading from the socket: %v", err)
+	} else if line != "hello\n" {
+		t.Errorf("bad greeting %q", line)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("the helper failed: %v", err)
+	}
+
+	// no socket activation if LISTEN_PID doesn't match
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	os.Setenv("LISTEN_FDS", "1")
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	if ln, err := utils.ActivationListener(); ln != nil || err != nil {
+		t.Errorf("unexpected listener or error: %v, %v", ln, err)
+	}
+}
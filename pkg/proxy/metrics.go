@@ -0,0 +1,147 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+const (
+	metricsNamespace   = "criproxy"
+	primaryRuntimeName = "primary"
+)
+
+var (
+	requestCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Number of CRI requests handled by the proxy.",
+		},
+		[]string{"api", "method", "code"},
+	)
+	requestLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of CRI requests handled by the proxy.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"api", "method"},
+	)
+	runtimeRequestCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "runtime_requests_total",
+			Help:      "Number of CRI requests passed by the proxy to the runtimes.",
+		},
+		[]string{"runtime", "method", "code"},
+	)
+	runtimeRequestLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "runtime_request_duration_seconds",
+			Help:      "Latency of CRI requests passed by the proxy to the runtimes.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"runtime", "method"},
+	)
+	runtimeState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "runtime_state",
+			Help:      "State of the proxy's connection to the runtime (1 for the current state, 0 for others).",
+		},
+		[]string{"runtime", "api", "state"},
+	)
+	runtimeDisconnectCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "runtime_disconnects_total",
+			Help:      "Number of times the proxy lost the connection to the runtime.",
+		},
+		[]string{"runtime", "api", "reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(requestCount, requestLatency, runtimeRequestCount, runtimeRequestLatency, runtimeState, runtimeDisconnectCount)
+}
+
+func (s clientState) String() string {
+	switch s {
+	case clientStateOffline:
+		return "offline"
+	case clientStateConnecting:
+		return "connecting"
+	case clientStateConnected:
+		return "connected"
+	default:
+		return "unknown"
+	}
+}
+
+// runtimeName returns the name of the runtime with the specified id
+// for use in the logs, metrics and status reports.
+func runtimeName(id string) string {
+	if id == "" {
+		return primaryRuntimeName
+	}
+	return id
+}
+
+// shortMethodName strips the proto package from the full gRPC method
+// name, e.g. /runtime.v1alpha2.RuntimeService/Version becomes
+// RuntimeService/Version
+func shortMethodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, ".")+1:]
+}
+
+func observeRequest(api, method string, start time.Time, err error) {
+	requestCount.WithLabelValues(api, method, grpc.Code(err).String()).Inc()
+	requestLatency.WithLabelValues(api, method).Observe(time.Since(start).Seconds())
+}
+
+func observeRuntimeRequest(id, fullMethod string, start time.Time, err error) {
+	name, method := runtimeName(id), shortMethodName(fullMethod)
+	runtimeRequestCount.WithLabelValues(name, method, grpc.Code(err).String()).Inc()
+	runtimeRequestLatency.WithLabelValues(name, method).Observe(time.Since(start).Seconds())
+}
+
+func setRuntimeStateMetric(id, api string, state clientState) {
+	for _, s := range []clientState{clientStateOffline, clientStateConnecting, clientStateConnected} {
+		v := float64(0)
+		if s == state {
+			v = 1
+		}
+		runtimeState.WithLabelValues(runtimeName(id), api, s.String()).Set(v)
+	}
+}
+
+func observeRuntimeDisconnect(id, api, reason string) {
+	runtimeDisconnectCount.WithLabelValues(runtimeName(id), api, reason).Inc()
+}
+
+func deleteRuntimeStateMetric(id, api string) {
+	for _, s := range []clientState{clientStateOffline, clientStateConnecting, clientStateConnected} {
+		runtimeState.DeleteLabelValues(runtimeName(id), api, s.String())
+	}
+}
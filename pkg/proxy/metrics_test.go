@@ -0,0 +1,65 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	proxytest "github.com/Mirantis/criproxy/pkg/proxy/testing"
+	runtimeapi "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_9"
+)
+
+func TestMetrics(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.waitForImages(t, 4)
+
+	requests := requestCount.WithLabelValues("runtime", "ImageService/ImageStatus", "OK")
+	runtimeRequests := runtimeRequestCount.WithLabelValues("alt", "ImageService/ImageStatus", "OK")
+	nRequests, nRuntimeRequests := testutil.ToFloat64(requests), testutil.ToFloat64(runtimeRequests)
+	if err := tester.invoke("/runtime.ImageService/ImageStatus", &runtimeapi.ImageStatusRequest{
+		Image: &runtimeapi.ImageSpec{Image: "alt/image2-1"},
+	}, &runtimeapi.ImageStatusResponse{}); err != nil {
+		t.Fatalf("ImageStatus() failed: %v", err)
+	}
+	if v := testutil.ToFloat64(requests) - nRequests; v != 1 {
+		t.Errorf("bad request count increment: %v instead of 1", v)
+	}
+	if v := testutil.ToFloat64(runtimeRequests) - nRuntimeRequests; v != 1 {
+		t.Errorf("bad runtime request count increment: %v instead of 1", v)
+	}
+
+	for _, name := range []string{"primary", "alt"} {
+		for _, state := range []clientState{clientStateOffline, clientStateConnecting, clientStateConnected} {
+			expected := float64(0)
+			if state == clientStateConnected {
+				expected = 1
+			}
+			if v := testutil.ToFloat64(runtimeState.WithLabelValues(name, "runtime", state.String())); v != expected {
+				t.Errorf("bad runtime state gauge for %q, %s: %v instead of %v", name, state, v, expected)
+			}
+		}
+	}
+}
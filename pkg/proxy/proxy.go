@@ -19,15 +19,19 @@ package proxy
 import (
 	"errors"
 	"fmt"
-	"net/url"
+	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
-	digest "github.com/opencontainers/go-digest"
+
 	"github.com/ghodss/yaml"
+	"github.com/gogo/protobuf/proto"
 	"github.com/golang/glog"
+	digest "github.com/opencontainers/go-digest"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -35,15 +39,38 @@ const (
 	criRequestLogLevel = 3
 	criNoisyLogLevel   = 4
 	criListLogLevel    = 5
+	// listDeadlineFraction is the fraction of the time remaining
+	// until the deadline of a List* request that the runtimes are
+	// given to respond
+	listDeadlineFraction = 0.8
+	// runtimeReadyCondition and networkReadyCondition are the
+	// types of the runtime conditions that are checked by kubelet
+	runtimeReadyCondition = "RuntimeReady"
+	networkReadyCondition = "NetworkReady"
+	// runtimeUnavailableReason is the reason of the RuntimeReady
+	// condition that's reported for the secondary runtimes that
+	// don't respond to Status requests
+	runtimeUnavailableReason = "RuntimeUnavailable"
 )
 
 // RuntimeProxy is a gRPC implementation of internalapi.RuntimeService.
 type RuntimeProxy struct {
-	criVersion   CRIVersion
-	streamUrl    url.URL
-	conn         *grpc.ClientConn
-	clients      []client
-	methodPrefix string
+	sync.Mutex
+	criVersion     CRIVersion
+	conn           *grpc.ClientConn
+	clients        []client
+	runtimeConfigs []RuntimeConfig
+	router         Router
+	methodPrefix   string
+	discoverOwners bool
+	streamServer   *StreamServer
+	auditLog       *AuditLog
+	timeouts       *requestTimeouts
+	redactEnv      func(name string) bool
+	// owners maps the unprefixed ids of the pod sandboxes and
+	// containers that were found on the secondary runtimes
+	// by the owner discovery to the ids of these runtimes
+	owners map[string]string
 }
 
 var _ Interceptor = &RuntimeProxy{}
@@ -56,29 +83,98 @@ type dispatchItem struct {
 }
 
 // NewRuntimeProxy creates a new internalapi.RuntimeService.
-func NewRuntimeProxy(criVersion CRIVersion, addrs []string, connectionTimout time.Duration, streamUrl *url.URL) (*RuntimeProxy, error) {
-	if len(addrs) == 0 {
-		return nil, errors.New("no sockets specified to connect to")
+func NewRuntimeProxy(criVersion CRIVersion, config *Config) (*RuntimeProxy, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	router, err := NewRouter(config)
+	if err != nil {
+		return nil, err
 	}
 
 	r := &RuntimeProxy{
-		criVersion:   criVersion,
-		streamUrl:    *streamUrl,
-		methodPrefix: fmt.Sprintf("/%s.", criVersion.ProtoPackage()),
+		criVersion:     criVersion,
+		router:         router,
+		methodPrefix:   fmt.Sprintf("/%s.", criVersion.ProtoPackage()),
+		discoverOwners: config.DiscoverOwners,
+		timeouts:       newRequestTimeouts(config),
+		redactEnv:      envRedactor(config.RedactEnv),
+		owners:         make(map[string]string),
 	}
-	for _, addr := range addrs {
-		r.clients = append(r.clients, newAutoClient(criVersion, addr, connectionTimout))
+	for _, runtimeConfig := range config.Runtimes {
+		r.clients = append(r.clients, newAutoClient(criVersion, runtimeConfig))
+		r.runtimeConfigs = append(r.runtimeConfigs, runtimeConfig)
 	}
-	if !r.clients[0].isPrimary() {
-		return nil, errors.New("the first client should be primary (no id)")
+
+	return r, nil
+}
+
+// Reload updates the list of runtimes according to the new
+// config. The clients of the runtimes which configuration didn't
+// change are kept intact. The clients of the runtimes that were
+// removed or changed are disconnected after their in-flight requests
+// are finished.
+func (r *RuntimeProxy) Reload(config *Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	router, err := NewRouter(config)
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	oldClients := make(map[string]client)
+	oldConfigs := make(map[string]RuntimeConfig)
+	for n, client := range r.clients {
+		oldClients[client.getID()] = client
+		oldConfigs[client.getID()] = r.runtimeConfigs[n]
 	}
-	for _, client := range r.clients[1:] {
-		if client.isPrimary() {
-			return nil, errors.New("only the first client should be primary (no id)")
+
+	var newClients []client
+	var newConfigs []RuntimeConfig
+	newIds := make(map[string]bool)
+	for _, runtimeConfig := range config.Runtimes {
+		id := runtimeConfig.Id
+		newIds[id] = true
+		client, found := oldClients[id]
+		switch {
+		case !found:
+			glog.V(1).Infof("Adding runtime %q", id)
+			client = newAutoClient(r.criVersion, runtimeConfig)
+		case !reflect.DeepEqual(oldConfigs[id].withoutTimeouts(), runtimeConfig.withoutTimeouts()):
+			glog.V(1).Infof("Updating runtime %q", id)
+			client = newAutoClient(r.criVersion, runtimeConfig)
+		default:
+			delete(oldClients, id)
 		}
+		newClients = append(newClients, client)
+		newConfigs = append(newConfigs, runtimeConfig)
 	}
+	r.clients = newClients
+	r.runtimeConfigs = newConfigs
+	r.router = router
+	r.discoverOwners = config.DiscoverOwners
+	r.timeouts = newRequestTimeouts(config)
+	r.redactEnv = envRedactor(config.RedactEnv)
+	// the runtimes may have changed, so the owners need to be
+	// discovered again
+	r.owners = make(map[string]string)
+	r.Unlock()
 
-	return r, nil
+	// The clients that remain in oldClients are either removed
+	// or replaced with the new ones
+	for id, oldClient := range oldClients {
+		glog.V(1).Infof("Draining the old client for runtime %q", id)
+		go func(id string, c client, removed bool) {
+			c.drain(clientDrainTimeout)
+			if removed {
+				deleteRuntimeStateMetric(id, r.criVersion.ProtoPackage())
+			}
+		}(id, oldClient, !newIds[id])
+	}
+	return nil
 }
 
 // Register implements Register method of the Interceptor interface.
@@ -88,7 +184,7 @@ func (r *RuntimeProxy) Register(s *grpc.Server) {
 
 // Stop implements Stop method of the Interceptor interface.
 func (r *RuntimeProxy) Stop() {
-	for _, client := range r.clients {
+	for _, client := range r.getClients() {
 		client.stop()
 	}
 }
@@ -105,7 +201,14 @@ func (r *RuntimeProxy) Match(fullMethod string) bool {
 // Intercept implements Intercept method of the Interceptor interface.
 func (r *RuntimeProxy) Intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	var err error
+	var resp interface{}
+	start := time.Now()
+	method := shortMethodName(info.FullMethod)
+	auditLog := r.getAuditLog()
+	auditEntry := auditLog.start(info.FullMethod, req, r.redact)
 	defer func() {
+		observeRequest(r.criVersion.ProtoPackage(), method, start, err)
+		auditLog.finish(auditEntry, resp, err)
 		if err != nil {
 			glog.V(criErrorLogLevel).Infof("FAIL: %s(): %v", info.FullMethod, err)
 		}
@@ -115,20 +218,24 @@ func (r *RuntimeProxy) Intercept(ctx context.Context, req interface{}, info *grp
 		return nil, err
 	}
 
-	method := info.FullMethod[len(r.methodPrefix):]
 	dispatchItem, found := dispatchTable[method]
 	if !found {
-		err = fmt.Errorf("no handler for method %q", method) // make it logged in defer
+		// newer CRI versions have methods that CRI proxy doesn't
+		// support yet. Kubelet handles Unimplemented errors for
+		// the optional ones gracefully
+		err = grpc.Errorf(codes.Unimplemented, "no handler for method %q", method) // make it logged in defer
 		return nil, err
 	}
 	if glog.V(dispatchItem.logLevel) {
-		glog.Infof("ENTER: %s():\n%s", info.FullMethod, dump(req))
+		glog.Infof("ENTER: %s():\n%s", info.FullMethod, dump(r.redact(req)))
 	}
 	wrappedReq, wrappedResp, err := r.criVersion.WrapObject(req)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := dispatchItem.handler(r, ctx, info.FullMethod, wrappedReq, wrappedResp)
+	ctx = withRequestTimeouts(ctx, r.getTimeouts())
+	ctx = withAuditEntry(ctx, auditEntry)
+	resp, err = dispatchItem.handler(r, ctx, info.FullMethod, wrappedReq, wrappedResp)
 	if err != nil {
 		return nil, err
 	}
@@ -136,89 +243,369 @@ func (r *RuntimeProxy) Intercept(ctx context.Context, req interface{}, info *grp
 		resp = wrappedResp.Unwrap()
 	}
 	if glog.V(dispatchItem.logLevel) {
-		glog.Infof("LEAVE: %s():\n%s", info.FullMethod, dump(resp))
+		glog.Infof("LEAVE: %s():\n%s", info.FullMethod, dump(r.redact(resp)))
 	}
 	return resp, nil
 }
 
+// RuntimeStatus describes the state of the proxy's connection to
+// a runtime.
+type RuntimeStatus struct {
+	// Id is the id of the runtime (empty for the primary runtime).
+	Id string `json:"id"`
+	// Name is the runtime id or "primary" for the primary runtime.
+	Name string `json:"name"`
+	// Api is the proto package of CRI version served by the proxy.
+	Api string `json:"api"`
+	// Socket is the socket of the runtime.
+	Socket string `json:"socket"`
+	// State is the state of the connection to the runtime:
+	// offline, connecting or connected.
+	State string `json:"state"`
+	// LastError is the error of the last failed connection
+	// attempt, if any.
+	LastError string `json:"lastError,omitempty"`
+	// Breaker is the state of the circuit breaker of the runtime:
+	// closed, open or half-open. It's empty if the runtime has
+	// no circuit breaker.
+	Breaker string `json:"breaker,omitempty"`
+	// Version is the version of the runtime reported by it when
+	// the connection was last established, if any.
+	Version *RuntimeVersion `json:"version,omitempty"`
+	// ImageFilesystems are the ids of the image filesystems
+	// reported by the runtime in the last ImageFsInfo response.
+	ImageFilesystems []string `json:"imageFilesystems,omitempty"`
+}
+
+// RuntimeStatuses returns the status of the connections to the
+// runtimes. The first item corresponds to the primary runtime.
+func (r *RuntimeProxy) RuntimeStatuses() []RuntimeStatus {
+	r.Lock()
+	clients := r.clients
+	configs := r.runtimeConfigs
+	r.Unlock()
+	var statuses []RuntimeStatus
+	for n, client := range clients {
+		status := RuntimeStatus{
+			Id:               client.getID(),
+			Name:             runtimeName(client.getID()),
+			Api:              r.criVersion.ProtoPackage(),
+			Socket:           configs[n].Socket,
+			State:            client.currentState().String(),
+			Breaker:          client.currentBreakerState().String(),
+			Version:          client.getRuntimeVersion(),
+			ImageFilesystems: client.getImageFilesystems(),
+		}
+		if err := client.lastConnectError(); err != nil {
+			status.LastError = err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// ConnectOffline makes the proxy start connecting to the runtimes
+// that are offline without waiting for the connection to be
+// established.
+func (r *RuntimeProxy) ConnectOffline() {
+	for _, client := range r.getClients() {
+		if client.currentState() == clientStateOffline {
+			client.connect()
+		}
+	}
+}
+
+func (r *RuntimeProxy) getClients() []client {
+	r.Lock()
+	defer r.Unlock()
+	return r.clients
+}
+
 func (r *RuntimeProxy) primaryClient() (client, error) {
-	if err := <-r.clients[0].connect(); err != nil {
+	client := r.getClients()[0]
+	if err := <-client.connect(); err != nil {
 		return nil, err
 	}
-	return r.clients[0], nil
+	return client, nil
 }
 
-func (r *RuntimeProxy) clientForAnnotations(annotations map[string]string) (client, error) {
-	for _, client := range r.clients {
-		if client.annotationsMatch(annotations) {
-			if err := <-client.connect(); err != nil {
-				return nil, err
-			}
-			return client, nil
+// SetStreamServer makes RuntimeProxy replace the URLs returned by
+// the runtimes for Exec, Attach and PortForward requests with the
+// URLs served by the specified StreamServer. Passing nil disables
+// the replacement.
+func (r *RuntimeProxy) SetStreamServer(s *StreamServer) {
+	r.Lock()
+	defer r.Unlock()
+	r.streamServer = s
+}
+
+func (r *RuntimeProxy) getStreamServer() *StreamServer {
+	r.Lock()
+	defer r.Unlock()
+	return r.streamServer
+}
+
+// SetAuditLog makes RuntimeProxy log the requests to the
+// specified AuditLog. Passing nil disables the audit.
+func (r *RuntimeProxy) SetAuditLog(a *AuditLog) {
+	r.Lock()
+	defer r.Unlock()
+	r.auditLog = a
+}
+
+func (r *RuntimeProxy) getAuditLog() *AuditLog {
+	r.Lock()
+	defer r.Unlock()
+	return r.auditLog
+}
+
+func (r *RuntimeProxy) getTimeouts() *requestTimeouts {
+	r.Lock()
+	defer r.Unlock()
+	return r.timeouts
+}
+
+func (r *RuntimeProxy) getRouter() Router {
+	r.Lock()
+	defer r.Unlock()
+	return r.router
+}
+
+func (r *RuntimeProxy) findClient(runtimeId string) (client, error) {
+	for _, c := range r.getClients() {
+		if c.getID() == runtimeId {
+			return c, nil
 		}
 	}
-	return nil, fmt.Errorf("criproxy: unknown runtime: %q", annotations[targetRuntimeAnnotationKey])
+	return nil, fmt.Errorf("criproxy: unknown runtime: %q", runtimeId)
 }
 
-func (r *RuntimeProxy) clientForId(id string) (client, string, error) {
-	client := r.clients[0]
-	unprefixed := id
-	for _, c := range r.clients[1:] {
-		if ok, unpref := c.idPrefixMatches(id); ok {
-			c.connect()
-			if c.currentState() != clientStateConnected {
-				return nil, "", fmt.Errorf("CRI proxy: target runtime is not available")
-			}
-			client = c
-			unprefixed = unpref
-			break
+// clientForRuntime returns the client for the runtime with the
+// specified id. It waits for the primary runtime to connect, but
+// returns errRuntimeNotAvailable right away if a secondary runtime
+// is not connected.
+func (r *RuntimeProxy) clientForRuntime(runtimeId string) (client, error) {
+	c, err := r.findClient(runtimeId)
+	if err != nil {
+		return nil, err
+	}
+	if c.isPrimary() {
+		if err := <-c.connect(); err != nil {
+			return nil, err
 		}
+		return c, nil
+	}
+	// don't wait for additional runtimes
+	c.connect()
+	if c.currentState() != clientStateConnected {
+		return nil, errRuntimeNotAvailable
+	}
+	return c, nil
+}
+
+// clientForRunPodSandbox returns the client for the runtime that
+// should run the pod sandbox according to the router. The router
+// may also alter the runtime handler in the request.
+func (r *RuntimeProxy) clientForRunPodSandbox(req RunPodSandboxRequest) (client, error) {
+	info := &PodSandboxInfo{
+		Name:           req.GetName(),
+		Namespace:      req.GetNamespace(),
+		Labels:         req.GetLabels(),
+		Annotations:    req.GetAnnotations(),
+		RuntimeHandler: req.RuntimeHandler(),
+	}
+	runtimeId, err := r.getRouter().PodSandboxRuntime(info)
+	if err != nil {
+		return nil, err
+	}
+	client, err := r.findClient(runtimeId)
+	if err != nil {
+		return nil, err
 	}
 	if err := <-client.connect(); err != nil {
+		return nil, err
+	}
+	req.SetRuntimeHandler(info.RuntimeHandler)
+	return client, nil
+}
+
+func (r *RuntimeProxy) clientForId(id string) (client, string, error) {
+	runtimeId, unprefixed := r.getRouter().IdRuntime(id)
+	if runtimeId == "" {
+		runtimeId = r.cachedOwner(id)
+	}
+	client, err := r.clientForRuntime(runtimeId)
+	if err != nil {
 		return nil, "", err
 	}
 	return client, unprefixed, nil
 }
 
-func (r *RuntimeProxy) clientForImage(image string, noErrorIfNotConnected bool) (client, string, error) {
-	client := r.clients[0]
-	unprefixed := image
-	for _, c := range r.clients[1:] {
-		if ok, unpref := c.imageMatches(image); ok {
-			c.connect()
-			// don't wait for additional runtimes
-			if c.currentState() != clientStateConnected {
-				if noErrorIfNotConnected {
-					return nil, "", nil
-				}
-				return nil, "", fmt.Errorf("CRI proxy: target runtime is not available")
-			}
-			client = c
-			unprefixed = unpref
-			break
+func (r *RuntimeProxy) cachedOwner(id string) string {
+	r.Lock()
+	defer r.Unlock()
+	return r.owners[id]
+}
+
+func (r *RuntimeProxy) setCachedOwner(id, runtimeId string) {
+	r.Lock()
+	defer r.Unlock()
+	if runtimeId == "" {
+		delete(r.owners, id)
+	} else {
+		r.owners[id] = runtimeId
+	}
+}
+
+// forgetCachedOwner removes the cached owner of the object with the
+// specified id if it's the specified runtime
+func (r *RuntimeProxy) forgetCachedOwner(id, runtimeId string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.owners[id] == runtimeId {
+		glog.V(2).Infof("Runtime %q no longer knows about %q, forgetting its owner", runtimeName(runtimeId), id)
+		delete(r.owners, id)
+	}
+}
+
+// shouldDiscoverOwner checks whether the proxy should look for
+// the pod sandbox or container on the secondary runtimes after
+// the call to the primary runtime failed with err
+func (r *RuntimeProxy) shouldDiscoverOwner(c client, err error) bool {
+	r.Lock()
+	discoverOwners := r.discoverOwners
+	r.Unlock()
+	return discoverOwners && c.isPrimary() && isNotFoundError(err)
+}
+
+// isNotFoundError checks whether the error returned by a runtime
+// means that the pod sandbox or container doesn't exist. Besides
+// NotFound status code, some runtimes such as dockershim use Unknown
+// code with "not found" or "No such ..." message.
+func isNotFoundError(err error) bool {
+	switch grpc.Code(err) {
+	case codes.NotFound:
+		return true
+	case codes.Unknown:
+		desc := strings.ToLower(grpc.ErrorDesc(err))
+		return strings.Contains(desc, "not found") || strings.Contains(desc, "no such")
+	default:
+		return false
+	}
+}
+
+// discoverOwner asks the connected secondary runtimes about the
+// pod sandbox or container with the specified id using the specified
+// status method, e.g. "RuntimeService/PodSandboxStatus". It caches
+// and returns the client for the runtime that owns the object or nil
+// if the object isn't found. If req and resp are not nil, they're
+// used for the status requests, so the response of the owner is
+// stored in resp. Otherwise, new request objects are made.
+func (r *RuntimeProxy) discoverOwner(ctx context.Context, id, statusMethod string, req, resp CRIObject) client {
+	if req == nil {
+		var err error
+		req, resp, err = r.newRequest(statusMethod)
+		if err != nil {
+			glog.Errorf("Can't make %s request: %v", statusMethod, err)
+			return nil
+		}
+		switch in := req.(type) {
+		case PodSandboxIdObject:
+			in.SetPodSandboxId(id)
+		case ContainerIdObject:
+			in.SetContainerId(id)
 		}
 	}
-	if err := <-client.connect(); err != nil {
+	for _, c := range r.getClients() {
+		if c.isPrimary() || c.currentState() != clientStateConnected {
+			continue
+		}
+		if c.currentBreakerState() == breakerOpen {
+			// the runtime keeps failing, don't wait for it
+			continue
+		}
+		// don't let a hung runtime use up the whole deadline
+		// of the incoming request
+		runtimeCtx, cancel, _ := runtimeContext(ctx, c.getID(), statusMethod)
+		_, err := c.invoke(runtimeCtx, r.methodPrefix+statusMethod, req, resp)
+		cancel()
+		if err != nil {
+			glog.V(2).Infof("Runtime %q doesn't know about %q: %v", runtimeName(c.getID()), id, err)
+			continue
+		}
+		glog.V(1).Infof("Discovered runtime %q as the owner of %q", runtimeName(c.getID()), id)
+		r.setCachedOwner(id, c.getID())
+		return c
+	}
+	return nil
+}
+
+// newRequest makes a new wrapped request for the specified method,
+// e.g. "RuntimeService/PodSandboxStatus", along with the matching
+// response.
+func (r *RuntimeProxy) newRequest(method string) (CRIObject, CRIObject, error) {
+	typeName := fmt.Sprintf("%s.%sRequest", r.criVersion.ProtoPackage(), method[strings.Index(method, "/")+1:])
+	mtype := proto.MessageType(typeName)
+	if mtype == nil {
+		return nil, nil, fmt.Errorf("unknown request type %q", typeName)
+	}
+	return r.criVersion.WrapObject(reflect.New(mtype.Elem()).Interface())
+}
+
+func (r *RuntimeProxy) clientForImage(image string, noErrorIfNotConnected bool) (client, string, error) {
+	runtimeId, unprefixed := r.getRouter().ImageRuntime(image)
+	client, err := r.clientForRuntime(runtimeId)
+	switch {
+	case err == errRuntimeNotAvailable && noErrorIfNotConnected:
+		return nil, "", nil
+	case err != nil:
 		return nil, "", err
 	}
 	return client, unprefixed, nil
 }
 
-func (r *RuntimeProxy) fixStreamingUrl(url string) string {
+func (r *RuntimeProxy) fixStreamingUrl(c client, url string) string {
 	// The URLs provided by dockershim in k8s 1.11+ look like this:
 	// //[::]:35057/cri/exec/tb8rgDBh
 	// These can be passed as-is to the client because they
 	// include the port.
 	// In k8s 1.10-, the following URLs are passed:
 	// /cri/exec/94B_NhGa
-	// These need to be replaced to make exec/attach work with
-	// dockershim.
-	if strings.HasPrefix(url, "/") && !strings.Contains(url, ":") {
-		u := r.streamUrl
-		u.Path = url
-		return u.String()
+	// These need to be resolved against the stream URL of the
+	// runtime to make exec/attach work with dockershim. The
+	// runtimes that have no stream URL configured use the one of
+	// the primary runtime.
+	if !strings.HasPrefix(url, "/") || strings.Contains(url, ":") {
+		return url
 	}
-	return url
+	u := c.getStreamUrl()
+	if u.Host == "" && !c.isPrimary() {
+		u = r.getClients()[0].getStreamUrl()
+	}
+	u.Path = url
+	return u.String()
+}
+
+// rewriteStreamingUrl fixes up the streaming URL in the response
+// of the runtime to Exec, Attach or PortForward request and, if the
+// streaming proxy is enabled, replaces it with the URL of
+// StreamServer.
+func (r *RuntimeProxy) rewriteStreamingUrl(c client, resp CRIObject) (interface{}, error) {
+	out, ok := resp.(UrlObject)
+	if !ok {
+		return resp, nil
+	}
+	streamingUrl := r.fixStreamingUrl(c, out.Url())
+	if s := r.getStreamServer(); s != nil {
+		if proxyUrl, err := s.ProxyUrl(streamingUrl); err != nil {
+			glog.Warningf("Not proxying the stream: %v", err)
+		} else {
+			glog.V(2).Infof("Streaming URL %s replaced with %s", streamingUrl, proxyUrl)
+			streamingUrl = proxyUrl
+		}
+	}
+	out.SetUrl(streamingUrl)
+	return resp, nil
 }
 
 func (r *RuntimeProxy) passToPrimary(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
@@ -229,15 +616,136 @@ func (r *RuntimeProxy) passToPrimary(ctx context.Context, method string, req, re
 	return client.invokeWithErrorHandling(ctx, method, req, resp)
 }
 
+// version passes Version request to the primary runtime and
+// replaces the runtime name and version in the response with the
+// ones of CRI proxy. The versions of all the runtimes behind the
+// proxy are reported by RuntimeStatuses.
+func (r *RuntimeProxy) version(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
+	if _, err := r.passToPrimary(ctx, method, req, resp); err != nil {
+		return nil, err
+	}
+	out := resp.(VersionResponse)
+	out.SetRuntimeName(ProxyName)
+	out.SetRuntimeVersion(Version)
+	return resp, nil
+}
+
+// status passes Status request to all the runtimes and merges
+// the runtime conditions reported by them. The conditions of the
+// primary runtime go first, as kubelet uses the first condition of
+// each type. They're followed by the conditions of the secondary
+// runtimes that have the runtime name in their messages. The
+// conditions of the primary runtime are made false if the
+// corresponding conditions of the secondary runtimes with "required"
+// status policy are false.
+func (r *RuntimeProxy) status(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
+	primary, err := r.primaryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	r.Lock()
+	clients := r.clients[1:]
+	configs := r.runtimeConfigs[1:]
+	r.Unlock()
+	results := make([][]RuntimeCondition, len(clients))
+	var wg sync.WaitGroup
+	for n, c := range clients {
+		wg.Add(1)
+		go func(n int, c client) {
+			defer wg.Done()
+			results[n] = r.runtimeConditions(ctx, c, method, req, resp)
+		}(n, c)
+	}
+	_, err = primary.invokeWithErrorHandling(ctx, method, req, resp)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	out := resp.(StatusResponse)
+	conditions := out.Conditions()
+	numPrimary := len(conditions)
+	for n, result := range results {
+		for _, condition := range result {
+			conditions = append(conditions, condition)
+			if configs[n].StatusPolicy != StatusPolicyRequired || condition.Status {
+				continue
+			}
+			if condition.Type != runtimeReadyCondition && condition.Type != networkReadyCondition {
+				continue
+			}
+			for i := 0; i < numPrimary; i++ {
+				if conditions[i].Type == condition.Type && conditions[i].Status {
+					conditions[i].Status = false
+					conditions[i].Reason = condition.Reason
+					conditions[i].Message = condition.Message
+				}
+			}
+		}
+	}
+	out.SetConditions(conditions)
+	return resp, nil
+}
+
+// runtimeConditions returns the runtime conditions of a secondary
+// runtime with the runtime name added to their messages. If the
+// runtime doesn't respond, a false RuntimeReady condition is
+// returned.
+func (r *RuntimeProxy) runtimeConditions(ctx context.Context, c client, method string, req, resp CRIObject) []RuntimeCondition {
+	name := runtimeName(c.getID())
+	var err error
+	var conditions []RuntimeCondition
+	if c.currentState() != clientStateConnected {
+		// This does nothing if the state is clientStateConnecting,
+		// otherwise it tries to connect asynchronously
+		c.connect()
+		err = errNotConnected
+	} else {
+		runtimeCtx, cancel := listContext(ctx)
+		defer cancel()
+		runtimeResp := reflect.New(reflect.TypeOf(resp).Elem()).Interface().(CRIObject)
+		runtimeResp.Wrap(nil)
+		if _, err = c.invoke(runtimeCtx, method, req, runtimeResp); err != nil {
+			err = c.handleError(err, false)
+		} else {
+			conditions = runtimeResp.(StatusResponse).Conditions()
+		}
+	}
+	if err != nil {
+		glog.Warningf("Status request failed for runtime %q: %v", name, err)
+		return []RuntimeCondition{
+			{
+				Type:    runtimeReadyCondition,
+				Status:  false,
+				Reason:  runtimeUnavailableReason,
+				Message: fmt.Sprintf("runtime %q: %v", name, err),
+			},
+		}
+	}
+	for n := range conditions {
+		if conditions[n].Message == "" {
+			conditions[n].Message = fmt.Sprintf("runtime %q", name)
+		} else {
+			conditions[n].Message = fmt.Sprintf("runtime %q: %s", name, conditions[n].Message)
+		}
+	}
+	return conditions
+}
+
 func (r *RuntimeProxy) updateRuntimeConfig(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
 	var errs []string
-	for _, client := range r.clients {
+	for _, client := range r.getClients() {
 		if client.currentState() != clientStateConnected {
 			// This does nothing if the state is clientStateConnecting,
 			// otherwise it tries to connect asynchronously
 			client.connect()
 			continue
 		}
+		if client.currentBreakerState() == breakerOpen {
+			glog.V(2).Infof("Skipping %s for runtime %q because its circuit breaker is open", method, runtimeName(client.getID()))
+			continue
+		}
 
 		_, err := client.invoke(ctx, method, req, resp)
 		if err != nil {
@@ -254,7 +762,8 @@ func (r *RuntimeProxy) updateRuntimeConfig(ctx context.Context, method string, r
 
 func (r *RuntimeProxy) listObjects(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
 	out := resp.(ObjectList)
-	clients := r.clients
+	clients := r.getClients()
+	router := r.getRouter()
 	var singleClient client
 	useSingleClient := false
 	if in, ok := req.(IdFilterObject); ok && in.IdFilter() != "" {
@@ -317,33 +826,182 @@ func (r *RuntimeProxy) listObjects(ctx context.Context, method string, req, resp
 	}
 
 	var items []CRIObject
-	for _, client := range clients {
-		if client.currentState() != clientStateConnected {
+	for _, result := range r.queryRuntimes(ctx, clients, router, method, req, resp) {
+		items = append(items, result...)
+	}
+	out.SetItems(items)
+	return resp, nil
+}
+
+// queryRuntimes invokes a List* method for each of the clients
+// and returns the augmented items for each of them. Nil is returned
+// for the runtimes that aren't connected or fail to respond.
+func (r *RuntimeProxy) queryRuntimes(ctx context.Context, clients []client, router Router, method string, req, resp CRIObject) [][]CRIObject {
+	// Query the runtimes concurrently so a slow runtime doesn't
+	// delay the others. Each runtime gets its own response object.
+	results := make([][]CRIObject, len(clients))
+	var wg sync.WaitGroup
+	for n, c := range clients {
+		if c.currentState() != clientStateConnected {
 			// This does nothing if the state is clientStateConnecting,
 			// otherwise it tries to connect asynchronously
-			client.connect()
+			c.connect()
+			continue
+		}
+		if c.currentBreakerState() == breakerOpen {
+			// the runtime keeps failing, don't wait for it
 			continue
 		}
+		wg.Add(1)
+		go func(n int, c client) {
+			defer wg.Done()
+			results[n] = r.listRuntimeObjects(ctx, c, router, method, req, resp)
+		}(n, c)
+	}
+	wg.Wait()
+	return results
+}
 
-		out.SetItems(nil)
-		_, err := client.invoke(ctx, method, req, resp)
-		if err != nil {
-			// if the runtime server is gone, let's just skip it
-			err = client.handleError(err, true)
-			if err != nil {
-				// for more serious errors, log a warning but don't
-				// block the other runtimes by making List* fail
-				glog.Warningf("List request failed for runtime %q: %v", client.getID(), err)
+// imageFsInfo handles ImageFsInfo requests. The filesystems of the
+// runtimes are listed in the order of the runtimes, and the usage
+// of the runtimes that share a filesystem is summed up, so kubelet,
+// which only looks at the first filesystem, takes into account the
+// images of all the runtimes stored on the primary one. The
+// filesystems used by each runtime are reported in its status.
+func (r *RuntimeProxy) imageFsInfo(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
+	clients := r.getClients()
+	results := r.queryRuntimes(ctx, clients, r.getRouter(), method, req, resp)
+	var items []CRIObject
+	owners := make(map[string]client)
+	byFsId := make(map[string]FilesystemUsage)
+	for n, result := range results {
+		if result == nil {
+			// keep the last known filesystems of the runtimes
+			// that didn't respond
+			continue
+		}
+		var fsIds []string
+		for _, item := range result {
+			fs := item.(FilesystemUsage)
+			fsId := fs.FsId()
+			if fsId != "" {
+				fsIds = append(fsIds, fsId)
+			}
+			if first, found := byFsId[fsId]; found {
+				glog.V(3).Infof("Runtime %q shares image filesystem %q with runtime %q", runtimeName(clients[n].getID()), fsId, runtimeName(owners[fsId].getID()))
+				first.AddUsage(fs)
+				continue
 			}
+			if fsId != "" {
+				byFsId[fsId] = fs
+				owners[fsId] = clients[n]
+			}
+			items = append(items, fs)
+		}
+		clients[n].setImageFilesystems(fsIds)
+	}
+	resp.(ImageFsInfoResponse).SetItems(items)
+	return resp, nil
+}
+
+// listRuntimeObjects invokes a List* method for a single runtime
+// and returns the augmented items. If the runtime fails to respond
+// before its deadline, the error is logged and nil is returned, so
+// that the results from the other runtimes can still be used.
+func (r *RuntimeProxy) listRuntimeObjects(ctx context.Context, c client, router Router, method string, req, resp CRIObject) []CRIObject {
+	runtimeCtx, cancel := listContext(ctx)
+	defer cancel()
+	runtimeResp := reflect.New(reflect.TypeOf(resp).Elem()).Interface().(CRIObject)
+	runtimeResp.Wrap(nil)
+	listedAt := time.Now()
+	if _, err := c.invoke(runtimeCtx, method, req, runtimeResp); err != nil {
+		// if the runtime server is gone, let's just skip it
+		err = c.handleError(err, true)
+		if err != nil {
+			// for more serious errors, log a warning but don't
+			// block the other runtimes by making List* fail
+			glog.Warningf("List request failed for runtime %q: %v", c.getID(), err)
 		}
-		for _, item := range out.Items() {
-			items = append(items, client.addPrefix(item))
+		return nil
+	}
+	runtimeItems := runtimeResp.(ObjectList).Items()
+	if syncer, ok := router.(idSyncer); ok {
+		if kind, ok := listedIdKind(req); ok {
+			var ids []string
+			for _, item := range runtimeItems {
+				ids = append(ids, item.(IdObject).Id())
+			}
+			syncer.SyncIds(c.getID(), kind, ids, listedAt)
 		}
 	}
+	var items []CRIObject
+	for _, item := range runtimeItems {
+		items = append(items, augmentObject(router, c.getID(), item))
+	}
+	return items
+}
 
-	out.SetItems(items)
-	return resp, nil
+// listedIdKind returns the kind of the objects listed by the request
+// if it's an unfiltered ListPodSandbox or ListContainers request.
+func listedIdKind(req CRIObject) (idKind, bool) {
+	if in, ok := req.(FilterObject); !ok || in.HasFilter() {
+		return 0, false
+	}
+	// ListContainersRequest must be checked first as it
+	// also satisfies ListPodSandboxRequest
+	switch req.(type) {
+	case ListContainersRequest:
+		return containerIds, true
+	case ListPodSandboxRequest:
+		return sandboxIds, true
+	default:
+		return 0, false
+	}
+}
 
+// listContext derives the context for a List* request to a single
+// runtime from the context of the incoming request. The runtime is
+// given listDeadlineFraction of the remaining time, so that the
+// proxy still has time to return the partial results if the runtime
+// doesn't respond.
+func listContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	remaining := deadline.Sub(time.Now())
+	return context.WithTimeout(ctx, time.Duration(float64(remaining)*listDeadlineFraction))
+}
+
+// invokeWithOwnerDiscovery invokes the method using the client. If
+// the owner discovery is enabled and the primary runtime doesn't know
+// about the object with the specified id, it looks for the object on
+// the secondary runtimes and retries the call there.
+func (r *RuntimeProxy) invokeWithOwnerDiscovery(ctx context.Context, c client, id, statusMethod, method string, req, resp CRIObject) (client, error) {
+	_, err := c.invokeWithErrorHandling(ctx, method, req, resp)
+	if isNotFoundError(err) {
+		// the object may have been removed behind
+		// the proxy's back
+		r.forgetCachedOwner(id, c.getID())
+	}
+	if err == nil || !r.shouldDiscoverOwner(c, err) {
+		return c, err
+	}
+	if bareMethodName(method) == bareMethodName(statusMethod) {
+		// the status request made during the discovery
+		// is the one that's being handled
+		owner := r.discoverOwner(ctx, id, statusMethod, req, resp)
+		if owner == nil {
+			return c, err
+		}
+		return owner, nil
+	}
+	owner := r.discoverOwner(ctx, id, statusMethod, nil, nil)
+	if owner == nil {
+		return c, err
+	}
+	_, err = owner.invokeWithErrorHandling(ctx, method, req, resp)
+	return owner, err
 }
 
 func (r *RuntimeProxy) invokePodSandboxMethod(ctx context.Context, method string, req, resp CRIObject) (client, error) {
@@ -353,8 +1011,7 @@ func (r *RuntimeProxy) invokePodSandboxMethod(ctx context.Context, method string
 		return nil, err
 	}
 	in.SetPodSandboxId(unprefixed)
-	_, err = client.invokeWithErrorHandling(ctx, method, req, resp)
-	return client, err
+	return r.invokeWithOwnerDiscovery(ctx, client, unprefixed, "RuntimeService/PodSandboxStatus", method, req, resp)
 }
 
 func (r *RuntimeProxy) invokeContainerMethod(ctx context.Context, method string, req, resp CRIObject) (client, error) {
@@ -364,32 +1021,39 @@ func (r *RuntimeProxy) invokeContainerMethod(ctx context.Context, method string,
 		return nil, err
 	}
 	in.SetContainerId(unprefixed)
-
-	_, err = client.invokeWithErrorHandling(ctx, method, req, resp)
-	return client, err
-
+	return r.invokeWithOwnerDiscovery(ctx, client, unprefixed, "RuntimeService/ContainerStatus", method, req, resp)
 }
 
 func (r *RuntimeProxy) runPodSandbox(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
-	client, err := r.clientForAnnotations(req.(RunPodSandboxRequest).GetAnnotations())
+	client, err := r.clientForRunPodSandbox(req.(RunPodSandboxRequest))
 	if err != nil {
 		return nil, err
 	}
 	if _, err = client.invokeWithErrorHandling(ctx, method, req, resp); err == nil {
 		out := resp.(RunPodSandboxResponse)
-		out.SetPodSandboxId(client.augmentId(out.PodSandboxId()))
+		out.SetPodSandboxId(r.getRouter().ObjectId(client.getID(), out.PodSandboxId()))
 	}
 	return resp, err
 }
 
 func (r *RuntimeProxy) handlePodSandbox(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
-	_, err := r.invokePodSandboxMethod(ctx, method, req, resp)
-	if err == nil {
-		if out, ok := resp.(UrlObject); ok {
-			out.SetUrl(r.fixStreamingUrl(out.Url()))
-		}
+	client, err := r.invokePodSandboxMethod(ctx, method, req, resp)
+	if err != nil {
+		return nil, err
 	}
-	return resp, err
+	return r.rewriteStreamingUrl(client, resp)
+}
+
+func (r *RuntimeProxy) removePodSandbox(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
+	id := req.(PodSandboxIdObject).PodSandboxId()
+	if _, err := r.invokePodSandboxMethod(ctx, method, req, resp); err != nil {
+		return nil, err
+	}
+	if f, ok := r.getRouter().(idForgetter); ok {
+		f.ForgetId(id)
+	}
+	r.setCachedOwner(id, "")
+	return resp, nil
 }
 
 func (r *RuntimeProxy) podSandboxStatus(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
@@ -398,7 +1062,7 @@ func (r *RuntimeProxy) podSandboxStatus(ctx context.Context, method string, req,
 		return nil, err
 	}
 	if status := resp.(PodSandboxStatusResponse).Status(); status != nil {
-		status.SetId(client.augmentId(status.Id()))
+		status.SetId(r.getRouter().ObjectId(client.getID(), status.Id()))
 	}
 	return resp, nil
 }
@@ -433,18 +1097,28 @@ func (r *RuntimeProxy) createContainer(ctx context.Context, method string, req,
 	}
 
 	out := resp.(CreateContainerResponse)
-	out.SetContainerId(client.augmentId(out.ContainerId()))
+	out.SetContainerId(r.getRouter().ObjectId(client.getID(), out.ContainerId()))
 	return out, nil
 }
 
 func (r *RuntimeProxy) handleContainer(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
-	_, err := r.invokeContainerMethod(ctx, method, req, resp)
-	if err == nil {
-		if out, ok := resp.(UrlObject); ok {
-			out.SetUrl(r.fixStreamingUrl(out.Url()))
-		}
+	client, err := r.invokeContainerMethod(ctx, method, req, resp)
+	if err != nil {
+		return nil, err
 	}
-	return resp, err
+	return r.rewriteStreamingUrl(client, resp)
+}
+
+func (r *RuntimeProxy) removeContainer(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
+	id := req.(ContainerIdObject).ContainerId()
+	if _, err := r.invokeContainerMethod(ctx, method, req, resp); err != nil {
+		return nil, err
+	}
+	if f, ok := r.getRouter().(idForgetter); ok {
+		f.ForgetId(id)
+	}
+	r.setCachedOwner(id, "")
+	return resp, nil
 }
 
 func (r *RuntimeProxy) containerStatus(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
@@ -453,8 +1127,9 @@ func (r *RuntimeProxy) containerStatus(ctx context.Context, method string, req,
 		return nil, err
 	}
 	if status := resp.(ContainerStatusResponse).Status(); status != nil {
-		status.SetId(client.augmentId(status.Id()))
-		status.SetImage(client.imageName(status.Image()))
+		router := r.getRouter()
+		status.SetId(router.ObjectId(client.getID(), status.Id()))
+		status.SetImage(router.ImageName(client.getID(), status.Image()))
 	}
 	return resp, nil
 }
@@ -465,11 +1140,47 @@ func (r *RuntimeProxy) containerStats(ctx context.Context, method string, req, r
 		return nil, err
 	}
 	if stats := resp.(ContainerStatsResponse).Stats(); stats != nil {
-		stats.SetId(client.augmentId(stats.Id()))
+		stats.SetId(r.getRouter().ObjectId(client.getID(), stats.Id()))
 	}
 	return resp, nil
 }
 
+// checkImageRemoval returns an error if the image GC policy of the
+// runtime doesn't allow removing its images.
+func (r *RuntimeProxy) checkImageRemoval(c client, image string) error {
+	r.Lock()
+	var policy string
+	for n, rc := range r.runtimeConfigs {
+		if r.clients[n] == c {
+			policy = rc.ImageGCPolicy
+		}
+	}
+	primary := r.clients[0]
+	r.Unlock()
+
+	var reason string
+	switch policy {
+	case ImageGCPolicyNever:
+		reason = "image GC policy of the runtime is \"never\""
+	case ImageGCPolicySharedFs:
+		primaryFsIds := primary.getImageFilesystems()
+		if len(primaryFsIds) == 0 {
+			reason = "the image filesystem of the primary runtime is unknown"
+			break
+		}
+		for _, fsId := range c.getImageFilesystems() {
+			if fsId == primaryFsIds[0] {
+				return nil
+			}
+		}
+		reason = fmt.Sprintf("the runtime doesn't store the images on the image filesystem %q of the primary runtime", primaryFsIds[0])
+	default:
+		return nil
+	}
+	glog.Warningf("Refusing to remove image %q of runtime %q: %s", image, runtimeName(c.getID()), reason)
+	return grpc.Errorf(codes.FailedPrecondition, "criproxy: can't remove image %q of runtime %q: %s", image, runtimeName(c.getID()), reason)
+}
+
 func (r *RuntimeProxy) handleImage(ctx context.Context, method string, req, resp CRIObject) (interface{}, error) {
 	in := req.(ImageObject)
 	client, unprefixed, err := r.clientForImage(in.Image(), true)
@@ -477,6 +1188,11 @@ func (r *RuntimeProxy) handleImage(ctx context.Context, method string, req, resp
 		// the client is offline
 		return resp, nil
 	}
+	if bareMethodName(method) == "RemoveImage" {
+		if err := r.checkImageRemoval(client, in.Image()); err != nil {
+			return nil, err
+		}
+	}
 	in.SetImage(unprefixed)
 
 	_, err = client.invokeWithErrorHandling(ctx, method, req, resp)
@@ -484,32 +1200,44 @@ func (r *RuntimeProxy) handleImage(ctx context.Context, method string, req, resp
 		return nil, err
 	}
 
+	router := r.getRouter()
 	if out, ok := resp.(ImageStatusResponse); ok && out.Image() != nil {
-		out.SetImage(client.addPrefix(out.Image()).(Image))
+		out.SetImage(augmentObject(router, client.getID(), out.Image()).(Image))
 	}
 
 	if out, ok := resp.(ImageObject); ok {
-		out.SetImage(client.imageName(out.Image()))
+		out.SetImage(router.ImageName(client.getID(), out.Image()))
 	}
 
 	return resp, err
 }
 
+// isKnownMethod returns true if the proxy handles the CRI method
+// with the specified name, which doesn't include the service.
+func isKnownMethod(name string) bool {
+	for method := range dispatchTable {
+		if bareMethodName(method) == name {
+			return true
+		}
+	}
+	return false
+}
+
 var dispatchTable = map[string]dispatchItem{
-	"RuntimeService/Version":                  {(*RuntimeProxy).passToPrimary, criNoisyLogLevel},
-	"RuntimeService/Status":                   {(*RuntimeProxy).passToPrimary, criNoisyLogLevel},
+	"RuntimeService/Version":                  {(*RuntimeProxy).version, criNoisyLogLevel},
+	"RuntimeService/Status":                   {(*RuntimeProxy).status, criNoisyLogLevel},
 	"RuntimeService/UpdateRuntimeConfig":      {(*RuntimeProxy).updateRuntimeConfig, criRequestLogLevel},
 	"RuntimeService/RunPodSandbox":            {(*RuntimeProxy).runPodSandbox, criRequestLogLevel},
 	"RuntimeService/ListPodSandbox":           {(*RuntimeProxy).listObjects, criListLogLevel},
 	"RuntimeService/StopPodSandbox":           {(*RuntimeProxy).handlePodSandbox, criRequestLogLevel},
-	"RuntimeService/RemovePodSandbox":         {(*RuntimeProxy).handlePodSandbox, criRequestLogLevel},
+	"RuntimeService/RemovePodSandbox":         {(*RuntimeProxy).removePodSandbox, criRequestLogLevel},
 	"RuntimeService/PodSandboxStatus":         {(*RuntimeProxy).podSandboxStatus, criNoisyLogLevel},
 	"RuntimeService/CreateContainer":          {(*RuntimeProxy).createContainer, criRequestLogLevel},
 	"RuntimeService/ListContainers":           {(*RuntimeProxy).listObjects, criListLogLevel},
 	"RuntimeService/ListContainerStats":       {(*RuntimeProxy).listObjects, criListLogLevel},
 	"RuntimeService/StartContainer":           {(*RuntimeProxy).handleContainer, criRequestLogLevel},
 	"RuntimeService/StopContainer":            {(*RuntimeProxy).handleContainer, criRequestLogLevel},
-	"RuntimeService/RemoveContainer":          {(*RuntimeProxy).handleContainer, criRequestLogLevel},
+	"RuntimeService/RemoveContainer":          {(*RuntimeProxy).removeContainer, criRequestLogLevel},
 	"RuntimeService/ContainerStatus":          {(*RuntimeProxy).containerStatus, criNoisyLogLevel},
 	"RuntimeService/ContainerStats":           {(*RuntimeProxy).containerStats, criNoisyLogLevel},
 	"RuntimeService/UpdateContainerResources": {(*RuntimeProxy).handleContainer, criRequestLogLevel},
@@ -522,7 +1250,7 @@ var dispatchTable = map[string]dispatchItem{
 	"ImageService/ImageStatus":                {(*RuntimeProxy).handleImage, criNoisyLogLevel},
 	"ImageService/PullImage":                  {(*RuntimeProxy).handleImage, criRequestLogLevel},
 	"ImageService/RemoveImage":                {(*RuntimeProxy).handleImage, criRequestLogLevel},
-	"ImageService/ImageFsInfo":                {(*RuntimeProxy).listObjects, criRequestLogLevel},
+	"ImageService/ImageFsInfo":                {(*RuntimeProxy).imageFsInfo, criRequestLogLevel},
 }
 
 var replaceRx = regexp.MustCompile(`\(\*(v1alpha2.\w+)\)\(0x[0-9a-f]+\)`)
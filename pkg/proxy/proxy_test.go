@@ -19,7 +19,10 @@ package proxy
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -28,8 +31,10 @@ import (
 	"github.com/ghodss/yaml"
 	"github.com/golang/glog"
 	"github.com/pmezard/go-difflib/difflib"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	proxytest "github.com/Mirantis/criproxy/pkg/proxy/testing"
 	"github.com/Mirantis/criproxy/pkg/runtimeapis"
@@ -41,6 +46,7 @@ import (
 const (
 	fakeCriSocketPath1        = "/tmp/fake-cri-1.socket"
 	fakeCriSocketPath2        = "/tmp/fake-cri-2.socket"
+	fakeCriSocketPath3        = "/tmp/fake-cri-3.socket"
 	altSocketSpec             = "alt:" + fakeCriSocketPath2
 	criProxySocketForTests    = "/tmp/cri-proxy.socket"
 	connectionTimeoutForTests = 20 * time.Second
@@ -85,7 +91,9 @@ type proxyTester struct {
 	hookCallCount   int
 	journal         *proxytest.SimpleJournal
 	servers         []proxytest.FakeCriServer
-	proxy           *RuntimeProxy
+	serverAddrs     []string
+	config          *Config
+	proxies         []*RuntimeProxy
 	proxyServer     *Server
 	conn            *grpc.ClientConn
 	containerStats  []*runtimeapi.ContainerStats
@@ -121,22 +129,29 @@ func newProxyTester(t *testing.T, secondSocketSpec string, fakeCriServerMakers [
 	tester := &proxyTester{
 		journal:         journal,
 		servers:         servers,
+		serverAddrs:     []string{fakeCriSocketPath1, fakeCriSocketPath2},
 		containerStats:  containerStats,
 		filesystemUsage: filesystemUsage,
 	}
+	config, err := ConfigFromConnectSpec(fakeCriSocketPath1 + "," + secondSocketSpec)
+	if err != nil {
+		t.Fatalf("failed to make the config: %v", err)
+	}
+	for n := range config.Runtimes {
+		config.Runtimes[n].ConnectionTimeout.Duration = connectionTimeoutForTests
+	}
 	// NOTE: in reality the loopback address should not be
 	// actually used for streaming unless you're absolutely sure
 	// that the only apiserver instance resides on this node
-	streamUrl, err := url.Parse("http://127.0.0.1:11250/")
-	if err != nil {
-		t.Fatalf("error parsing stream url: %v", err)
-	}
+	config.Runtimes[0].StreamUrl = "http://127.0.0.1:11250/"
+	tester.config = config
 	var interceptors []Interceptor
-	for _, criVersion := range []CRIVersion{&CRI19{}, &CRI112{}} {
-		proxy, err := NewRuntimeProxy(criVersion, []string{fakeCriSocketPath1, secondSocketSpec}, connectionTimeoutForTests, streamUrl)
+	for _, criVersion := range []CRIVersion{&CRI19{}, &CRI112{}, &CRIv1{}} {
+		proxy, err := NewRuntimeProxy(criVersion, config)
 		if err != nil {
 			t.Fatalf("failed to create runtime proxy: %v", err)
 		}
+		tester.proxies = append(tester.proxies, proxy)
 		interceptors = append(interceptors, proxy)
 	}
 	tester.proxyServer = NewServer(interceptors, func() {
@@ -147,10 +162,9 @@ func newProxyTester(t *testing.T, secondSocketSpec string, fakeCriServerMakers [
 }
 
 func (tester *proxyTester) startServers(t *testing.T, which int) {
-	paths := []string{fakeCriSocketPath1, fakeCriSocketPath2}
 	for i := 0; i < 2; i++ {
 		if which < 0 || i == which {
-			startServer(t, tester.servers[i], paths[i])
+			startServer(t, tester.servers[i], tester.serverAddrs[i])
 		}
 	}
 }
@@ -189,6 +203,48 @@ func (tester *proxyTester) verifyJournal(t *testing.T, expectedItems []string) {
 	}
 }
 
+// verifyJournalUnordered verifies the journal ignoring the order of
+// the items, which is needed for List* requests that are passed to
+// the runtimes concurrently
+func (tester *proxyTester) verifyJournalUnordered(t *testing.T, expectedItems []string) {
+	if err := tester.journal.VerifyUnordered(expectedItems); err != nil {
+		t.Error(err)
+	}
+}
+
+func (tester *proxyTester) clearJournal() {
+	tester.journal.Lock()
+	defer tester.journal.Unlock()
+	tester.journal.Items = nil
+}
+
+func (tester *proxyTester) reload(t *testing.T, config *Config) {
+	for _, proxy := range tester.proxies {
+		if err := proxy.Reload(config); err != nil {
+			t.Fatalf("Reload(): %v", err)
+		}
+	}
+}
+
+// waitForImages lists the images via CRI proxy until the list
+// contains the specified number of images
+func (tester *proxyTester) waitForImages(t *testing.T, count int) []*runtimeapi.Image {
+	for i := 0; ; i++ {
+		if i == 100 {
+			t.Fatalf("timed out waiting for %d images", count)
+		}
+		var resp runtimeapi.ListImagesResponse
+		if err := tester.invoke("/runtime.ImageService/ListImages", &runtimeapi.ListImagesRequest{}, &resp); err != nil {
+			t.Fatalf("ListImages() failed: %v", err)
+		}
+		if len(resp.GetImages()) == count {
+			tester.clearJournal()
+			return resp.GetImages()
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
 func (tester *proxyTester) invoke(method string, in, resp interface{}) error {
 	return grpc.Invoke(context.Background(), method, in, resp, tester.conn)
 }
@@ -227,7 +283,15 @@ func (tester *proxyTester) verifyCall(t *testing.T, method string, in, resp inte
 	}
 }
 
-func verifyCRIProxy(t *testing.T, secondSocketSpec string, useNewCriVersionForProxy bool, fakeCriServerMakers []makeFakeCriServerFunc) {
+// methodForProtoPackage replaces the proto package in the full
+// CRI method name
+func methodForProtoPackage(method, protoPackage string) string {
+	parts := strings.SplitN(method, "/", 3)
+	service := parts[1][strings.LastIndex(parts[1], ".")+1:]
+	return fmt.Sprintf("/%s.%s/%s", protoPackage, service, parts[2])
+}
+
+func verifyCRIProxy(t *testing.T, secondSocketSpec string, proxyProtoPackage string, fakeCriServerMakers []makeFakeCriServerFunc) {
 	tester := newProxyTester(t, secondSocketSpec, fakeCriServerMakers)
 	defer tester.stop()
 	tester.startServers(t, -1)
@@ -263,36 +327,14 @@ func verifyCRIProxy(t *testing.T, secondSocketSpec string, useNewCriVersionForPr
 			in:     &runtimeapi.VersionRequest{},
 			resp: &runtimeapi.VersionResponse{
 				Version:           "0.1.0",
-				RuntimeName:       "fakeRuntime",
-				RuntimeVersion:    "0.1.0",
+				RuntimeName:       ProxyName,
+				RuntimeVersion:    Version,
 				RuntimeApiVersion: "0.1.0",
 			},
 			// the first Version request is done by CRI proxy itself
 			// to verify the connection
 			journal: []string{"1/runtime/Version", "1/runtime/Version"},
 		},
-		{
-			name:   "status",
-			method: "/runtime.RuntimeService/Status",
-			in:     &runtimeapi.StatusRequest{},
-			resp: &runtimeapi.StatusResponse{
-				Status: &runtimeapi.RuntimeStatus{
-					Conditions: []*runtimeapi.RuntimeCondition{
-						{
-							Type:   "RuntimeReady",
-							Status: true,
-						},
-						{
-							Type:   "NetworkReady",
-							Status: true,
-						},
-					},
-				},
-			},
-			// FIXME: actually, both runtimes need to be contacted and
-			// the result needs to be combined
-			journal: []string{"1/runtime/Status"},
-		},
 		{
 			name:   "run pod sandbox 1",
 			method: "/runtime.RuntimeService/RunPodSandbox",
@@ -336,6 +378,36 @@ func verifyCRIProxy(t *testing.T, secondSocketSpec string, useNewCriVersionForPr
 			// to verify the connection
 			journal: []string{"2/runtime/Version", "2/runtime/RunPodSandbox"},
 		},
+		{
+			name:   "status",
+			method: "/runtime.RuntimeService/Status",
+			in:     &runtimeapi.StatusRequest{},
+			resp: &runtimeapi.StatusResponse{
+				Status: &runtimeapi.RuntimeStatus{
+					Conditions: []*runtimeapi.RuntimeCondition{
+						{
+							Type:   "RuntimeReady",
+							Status: true,
+						},
+						{
+							Type:   "NetworkReady",
+							Status: true,
+						},
+						{
+							Type:    "RuntimeReady",
+							Status:  true,
+							Message: `runtime "alt"`,
+						},
+						{
+							Type:    "NetworkReady",
+							Status:  true,
+							Message: `runtime "alt"`,
+						},
+					},
+				},
+			},
+			journal: []string{"1/runtime/Status", "2/runtime/Status"},
+		},
 		{
 			name:   "run pod sandbox with bad runtime id",
 			method: "/runtime.RuntimeService/RunPodSandbox",
@@ -1405,7 +1477,7 @@ func verifyCRIProxy(t *testing.T, secondSocketSpec string, useNewCriVersionForPr
 
 	nCalls := 0
 	for _, step := range testCases {
-		if step.newVersion && !useNewCriVersionForProxy {
+		if step.newVersion && proxyProtoPackage == runtimeapis.ProtoPackage19 {
 			continue
 		}
 		var ins []interface{}
@@ -1428,20 +1500,24 @@ func verifyCRIProxy(t *testing.T, secondSocketSpec string, useNewCriVersionForPr
 				method := step.method
 				req := in
 				resp := step.resp
-				if useNewCriVersionForProxy && !step.newVersion {
-					method = strings.Replace(method, "runtime.", "runtime.v1alpha2.", 1)
+				if proxyProtoPackage != runtimeapis.ProtoPackage19 {
+					method = methodForProtoPackage(method, proxyProtoPackage)
 					var err error
-					req, err = runtimeapis.Upgrade(in)
+					req, err = runtimeapis.ConvertTo(in, proxyProtoPackage)
 					if err != nil {
-						t.Fatalf("Upgrade %T: %v", in, err)
+						t.Fatalf("ConvertTo %T: %v", in, err)
 					}
-					resp, err = runtimeapis.Upgrade(step.resp)
+					resp, err = runtimeapis.ConvertTo(step.resp, proxyProtoPackage)
 					if err != nil {
-						t.Fatalf("Upgrade %T: %v", step.resp, err)
+						t.Fatalf("ConvertTo %T: %v", step.resp, err)
 					}
 				}
 				tester.verifyCall(t, method, req, resp, step.error)
-				tester.verifyJournal(t, step.journal)
+				if strings.Contains(method, "/List") || strings.HasSuffix(method, "/ImageFsInfo") || strings.HasSuffix(method, "Service/Status") {
+					tester.verifyJournalUnordered(t, step.journal)
+				} else {
+					tester.verifyJournal(t, step.journal)
+				}
 			})
 		}
 
@@ -1455,26 +1531,47 @@ func verifyCRIProxy(t *testing.T, secondSocketSpec string, useNewCriVersionForPr
 }
 
 func TestCriProxy19(t *testing.T) {
-	verifyCRIProxy(t, altSocketSpec, false, []makeFakeCriServerFunc{
+	verifyCRIProxy(t, altSocketSpec, runtimeapis.ProtoPackage19, []makeFakeCriServerFunc{
 		proxytest.NewFakeCriServer19,
 		proxytest.NewFakeCriServer19,
 	})
 }
 
 func TestCriProxy19To110(t *testing.T) {
-	verifyCRIProxy(t, altSocketSpec, false, []makeFakeCriServerFunc{
+	verifyCRIProxy(t, altSocketSpec, runtimeapis.ProtoPackage19, []makeFakeCriServerFunc{
 		proxytest.NewFakeCriServer19,
 		proxytest.NewFakeCriServer110,
 	})
 }
 
 func TestCriProxy110(t *testing.T) {
-	verifyCRIProxy(t, altSocketSpec, true, []makeFakeCriServerFunc{
+	verifyCRIProxy(t, altSocketSpec, runtimeapis.ProtoPackage112, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer110,
 		proxytest.NewFakeCriServer110,
+	})
+}
+
+func TestCriProxy19ToV1(t *testing.T) {
+	verifyCRIProxy(t, altSocketSpec, runtimeapis.ProtoPackage19, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServerV1,
+		proxytest.NewFakeCriServerV1,
+	})
+}
+
+func TestCriProxy110ToV1(t *testing.T) {
+	verifyCRIProxy(t, altSocketSpec, runtimeapis.ProtoPackage112, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServerV1,
 		proxytest.NewFakeCriServer110,
 	})
 }
 
+func TestCriProxyV1(t *testing.T) {
+	verifyCRIProxy(t, altSocketSpec, runtimeapis.ProtoPackageV1, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer110,
+		proxytest.NewFakeCriServerV1,
+	})
+}
+
 func TestCriProxyInactiveServers(t *testing.T) {
 	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
 		proxytest.NewFakeCriServer19,
@@ -1542,7 +1639,7 @@ func TestCriProxyInactiveServers(t *testing.T) {
 			t.Fatalf("ListImages() failed while waiting for 2nd client to connect: %v", err)
 		}
 		if len(resp.GetImages()) == 4 {
-			tester.verifyJournal(t, []string{"1/image/ListImages", "2/image/ListImages"})
+			tester.verifyJournalUnordered(t, []string{"1/image/ListImages", "2/image/ListImages"})
 			break
 		} else {
 			tester.verifyJournal(t, []string{"1/image/ListImages"})
@@ -1576,7 +1673,7 @@ func TestCriProxyInactiveServers(t *testing.T) {
 			},
 		},
 	}, "")
-	tester.verifyJournal(t, []string{"1/image/ListImages", "2/image/ListImages"})
+	tester.verifyJournalUnordered(t, []string{"1/image/ListImages", "2/image/ListImages"})
 
 	tester.servers[1].Stop()
 	for i := 0; ; i++ {
@@ -1589,7 +1686,7 @@ func TestCriProxyInactiveServers(t *testing.T) {
 			t.Fatalf("ListImages() failed while waiting for 2nd client to disconnect: %v", err)
 		}
 		if len(resp.GetImages()) == 4 {
-			tester.verifyJournal(t, []string{"1/image/ListImages", "2/image/ListImages"})
+			tester.verifyJournalUnordered(t, []string{"1/image/ListImages", "2/image/ListImages"})
 		} else {
 			tester.verifyJournal(t, []string{"1/image/ListImages"})
 			break
@@ -1641,10 +1738,1053 @@ func TestCriProxyInactiveServers(t *testing.T) {
 	tester.verifyJournal(t, []string{"1/runtime/ListContainers"})
 }
 
+func TestCriProxyReload(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.skipJournalItems("1/runtime/Version", "2/runtime/Version", "3/runtime/Version")
+
+	server3 := proxytest.NewFakeCriServer19(proxytest.NewPrefixJournal(tester.journal, "3/"), "/cri")
+	server3.SetFakeImageSize(fakeImageSize2)
+	server3.SetFakeImages([]string{"image3-1"})
+	startServer(t, server3, fakeCriSocketPath3)
+	defer server3.Stop()
+
+	tester.waitForImages(t, 4)
+	primaryClients := make([]client, len(tester.proxies))
+	for n, proxy := range tester.proxies {
+		primaryClients[n] = proxy.getClients()[0]
+	}
+
+	// re-point the alt runtime to another socket
+	config := *tester.config
+	config.Runtimes = append([]RuntimeConfig(nil), tester.config.Runtimes...)
+	config.Runtimes[1].Socket = fakeCriSocketPath3
+	tester.reload(t, &config)
+
+	images := tester.waitForImages(t, 3)
+	if images[2].Id != "alt/image3-1" {
+		t.Errorf("unexpected image after re-pointing the runtime: %q", images[2].Id)
+	}
+	tester.verifyCall(t, "/runtime.ImageService/ImageStatus",
+		&runtimeapi.ImageStatusRequest{
+			Image: &runtimeapi.ImageSpec{Image: "alt/image3-1"},
+		},
+		&runtimeapi.ImageStatusResponse{
+			Image: &runtimeapi.Image{
+				Id:       "alt/image3-1",
+				RepoTags: []string{"alt/image3-1"},
+				Size_:    fakeImageSize2,
+			},
+		}, "")
+	tester.verifyJournal(t, []string{"3/image/ImageStatus"})
+
+	// remove the alt runtime
+	config.Runtimes = config.Runtimes[:1]
+	tester.reload(t, &config)
+	tester.verifyCall(t, "/runtime.ImageService/ListImages", &runtimeapi.ListImagesRequest{}, &runtimeapi.ListImagesResponse{
+		Images: []*runtimeapi.Image{
+			{
+				Id:       "image1-1",
+				RepoTags: []string{"image1-1"},
+				Size_:    fakeImageSize1,
+			},
+			{
+				Id:       "image1-2",
+				RepoTags: []string{"image1-2"},
+				Size_:    fakeImageSize1,
+			},
+		},
+	}, "")
+	tester.verifyJournal(t, []string{"1/image/ListImages"})
+
+	// the connection to the primary runtime must be kept intact
+	for n, proxy := range tester.proxies {
+		if proxy.getClients()[0] != primaryClients[n] {
+			t.Errorf("the primary client was replaced during reload")
+		}
+	}
+}
+
+func TestCriProxyRuntimeHandlers(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer110,
+		proxytest.NewFakeCriServer110,
+	})
+	defer tester.stop()
+	config := *tester.config
+	config.Runtimes = append([]RuntimeConfig(nil), tester.config.Runtimes...)
+	config.Runtimes[1].RuntimeHandlers = []string{"alt-handler"}
+	tester.reload(t, &config)
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.skipJournalItems("1/runtime/Version", "2/runtime/Version")
+
+	runPodSandbox := func(name, uid, handler string, annotations map[string]string) *v1_12.RunPodSandboxRequest {
+		return &v1_12.RunPodSandboxRequest{
+			Config: &v1_12.PodSandboxConfig{
+				Metadata: &v1_12.PodSandboxMetadata{
+					Name:      name,
+					Uid:       uid,
+					Namespace: "default",
+				},
+				Annotations: annotations,
+			},
+			RuntimeHandler: handler,
+		}
+	}
+	for _, tc := range []struct {
+		name    string
+		in      *v1_12.RunPodSandboxRequest
+		forward bool
+		id      string
+		journal []string
+	}{
+		{
+			name:    "handler of the alt runtime",
+			in:      runPodSandbox("pod-2-1", podUid2, "alt-handler", nil),
+			id:      podSandboxId2,
+			journal: []string{"2/runtime/RunPodSandbox"},
+		},
+		{
+			name:    "handler of the alt runtime with forwarding",
+			in:      runPodSandbox("pod-2-1", podUid2, "alt-handler", nil),
+			forward: true,
+			id:      podSandboxId2,
+			journal: []string{"2/runtime/RunPodSandbox:alt-handler"},
+		},
+		{
+			name:    "handler takes precedence over the annotation",
+			in:      runPodSandbox("pod-2-1", podUid2, "alt-handler", map[string]string{targetRuntimeAnnotationKey: "foobar"}),
+			id:      podSandboxId2,
+			journal: []string{"2/runtime/RunPodSandbox"},
+		},
+		{
+			name:    "unknown handler",
+			in:      runPodSandbox("pod-1-1", podUid1, "kata", nil),
+			id:      podSandboxId1,
+			journal: []string{"1/runtime/RunPodSandbox:kata"},
+		},
+		{
+			name:    "unknown handler with the annotation",
+			in:      runPodSandbox("pod-2-1", podUid2, "kata", map[string]string{targetRuntimeAnnotationKey: "alt"}),
+			id:      podSandboxId2,
+			journal: []string{"2/runtime/RunPodSandbox:kata"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if config.Runtimes[1].ForwardRuntimeHandler != tc.forward {
+				config.Runtimes[1].ForwardRuntimeHandler = tc.forward
+				tester.reload(t, &config)
+			}
+			tester.verifyCall(t, "/runtime.v1alpha2.RuntimeService/RunPodSandbox", tc.in, &v1_12.RunPodSandboxResponse{
+				PodSandboxId: tc.id,
+			}, "")
+			tester.verifyJournal(t, tc.journal)
+		})
+	}
+}
+
+func TestCriProxyIdTable(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "criproxy-ids")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	config := *tester.config
+	config.IdMapping = IdMappingTable
+	config.IdTablePath = filepath.Join(tmpDir, "ids.json")
+	tester.reload(t, &config)
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.skipJournalItems("1/runtime/Version", "2/runtime/Version")
+
+	tester.verifyCall(t, "/runtime.RuntimeService/RunPodSandbox", &runtimeapi.RunPodSandboxRequest{
+		Config: &runtimeapi.PodSandboxConfig{
+			Metadata: &runtimeapi.PodSandboxMetadata{
+				Name:      "pod-2-1",
+				Uid:       podUid2,
+				Namespace: "default",
+			},
+			Annotations: map[string]string{targetRuntimeAnnotationKey: "alt"},
+		},
+	}, &runtimeapi.RunPodSandboxResponse{
+		// the id is not prefixed
+		PodSandboxId: podSandboxId2unprefixed,
+	}, "")
+	tester.verifyJournal(t, []string{"2/runtime/RunPodSandbox"})
+
+	tester.verifyCall(t, "/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{
+		PodSandboxId: podSandboxId2unprefixed,
+	}, &runtimeapi.StopPodSandboxResponse{}, "")
+	tester.verifyJournal(t, []string{"2/runtime/StopPodSandbox"})
+
+	tester.verifyCall(t, "/runtime.RuntimeService/RemovePodSandbox", &runtimeapi.RemovePodSandboxRequest{
+		PodSandboxId: podSandboxId2unprefixed,
+	}, &runtimeapi.RemovePodSandboxResponse{}, "")
+	tester.verifyJournal(t, []string{"2/runtime/RemovePodSandbox"})
+
+	// the id is forgotten after the pod sandbox is removed,
+	// so the request goes to the primary runtime
+	tester.verifyCall(t, "/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{
+		PodSandboxId: podSandboxId2unprefixed,
+	}, &runtimeapi.StopPodSandboxResponse{}, "not found")
+	tester.verifyJournal(t, []string{"1/runtime/StopPodSandbox"})
+}
+
+func TestCriProxyOwnerDiscovery(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.skipJournalItems("1/runtime/Version", "2/runtime/Version")
+
+	tester.verifyCall(t, "/runtime.RuntimeService/RunPodSandbox", &runtimeapi.RunPodSandboxRequest{
+		Config: &runtimeapi.PodSandboxConfig{
+			Metadata: &runtimeapi.PodSandboxMetadata{
+				Name:      "pod-2-1",
+				Uid:       podUid2,
+				Namespace: "default",
+			},
+			Annotations: map[string]string{targetRuntimeAnnotationKey: "alt"},
+		},
+	}, &runtimeapi.RunPodSandboxResponse{
+		PodSandboxId: podSandboxId2,
+	}, "")
+	tester.verifyJournal(t, []string{"2/runtime/RunPodSandbox"})
+
+	// the unprefixed id goes to the primary runtime
+	// when the owner discovery is disabled
+	var resp runtimeapi.PodSandboxStatusResponse
+	err := tester.invoke("/runtime.RuntimeService/PodSandboxStatus", &runtimeapi.PodSandboxStatusRequest{
+		PodSandboxId: podSandboxId2unprefixed,
+	}, &resp)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("didn't get the expected error, got %v", err)
+	}
+	tester.verifyJournal(t, []string{"1/runtime/PodSandboxStatus"})
+
+	config := *tester.config
+	config.DiscoverOwners = true
+	tester.reload(t, &config)
+	if err := tester.invoke("/runtime.RuntimeService/PodSandboxStatus", &runtimeapi.PodSandboxStatusRequest{
+		PodSandboxId: podSandboxId2unprefixed,
+	}, &resp); err != nil {
+		t.Fatalf("PodSandboxStatus(): %v", err)
+	}
+	if resp.Status.GetId() != podSandboxId2 {
+		t.Errorf("bad pod sandbox id in the status: %q", resp.Status.GetId())
+	}
+	tester.verifyJournal(t, []string{
+		"1/runtime/PodSandboxStatus",
+		// discovery, the status request isn't repeated
+		"2/runtime/PodSandboxStatus",
+	})
+
+	// the owner is cached
+	tester.verifyCall(t, "/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{
+		PodSandboxId: podSandboxId2unprefixed,
+	}, &runtimeapi.StopPodSandboxResponse{}, "")
+	tester.verifyJournal(t, []string{"2/runtime/StopPodSandbox"})
+
+	// unknown ids still produce errors
+	tester.verifyCall(t, "/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{
+		PodSandboxId: "foobar",
+	}, &runtimeapi.StopPodSandboxResponse{}, "not found")
+	tester.verifyJournal(t, []string{"1/runtime/StopPodSandbox", "2/runtime/PodSandboxStatus"})
+
+	// the cached owner is forgotten after the pod sandbox
+	// is removed behind the proxy's back
+	altServer := tester.servers[1].(*proxytest.FakeCriServer19).FakeRuntimeServer19
+	if _, err := altServer.RemovePodSandbox(context.Background(), &runtimeapi.RemovePodSandboxRequest{
+		PodSandboxId: podSandboxId2unprefixed,
+	}); err != nil {
+		t.Fatalf("RemovePodSandbox(): %v", err)
+	}
+	tester.verifyJournal(t, []string{"2/runtime/RemovePodSandbox"})
+	tester.verifyCall(t, "/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{
+		PodSandboxId: podSandboxId2unprefixed,
+	}, &runtimeapi.StopPodSandboxResponse{}, "not found")
+	tester.verifyJournal(t, []string{"2/runtime/StopPodSandbox"})
+	tester.verifyCall(t, "/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{
+		PodSandboxId: podSandboxId2unprefixed,
+	}, &runtimeapi.StopPodSandboxResponse{}, "not found")
+	tester.verifyJournal(t, []string{"1/runtime/StopPodSandbox", "2/runtime/PodSandboxStatus"})
+}
+
+func TestCriProxyParallelList(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.skipJournalItems("1/runtime/Version", "2/runtime/Version")
+	tester.waitForImages(t, 4)
+
+	listImages := func(timeout time.Duration) ([]string, time.Duration) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		start := time.Now()
+		var resp runtimeapi.ListImagesResponse
+		if err := grpc.Invoke(ctx, "/runtime.ImageService/ListImages", &runtimeapi.ListImagesRequest{}, &resp, tester.conn); err != nil {
+			t.Fatalf("ListImages() failed: %v", err)
+		}
+		var ids []string
+		for _, image := range resp.Images {
+			ids = append(ids, image.Id)
+		}
+		return ids, time.Since(start)
+	}
+
+	// the runtimes are queried concurrently and the results
+	// are merged in the order of the runtimes
+	tester.servers[0].SetDelay("ListImages", 500*time.Millisecond)
+	tester.servers[1].SetDelay("ListImages", 200*time.Millisecond)
+	ids, elapsed := listImages(10 * time.Second)
+	expectedIds := []string{"image1-1", "image1-2", "alt/image2-1", "alt/image2-2"}
+	if !reflect.DeepEqual(ids, expectedIds) {
+		t.Errorf("bad image list %#v instead of %#v", ids, expectedIds)
+	}
+	if elapsed >= 700*time.Millisecond {
+		t.Errorf("the runtimes weren't queried concurrently (ListImages took %v)", elapsed)
+	}
+	tester.verifyJournalUnordered(t, []string{"1/image/ListImages", "2/image/ListImages"})
+
+	// a runtime that doesn't respond in time is skipped
+	tester.servers[0].SetDelay("ListImages", 0)
+	tester.servers[1].SetDelay("ListImages", 5*time.Second)
+	ids, elapsed = listImages(time.Second)
+	expectedIds = []string{"image1-1", "image1-2"}
+	if !reflect.DeepEqual(ids, expectedIds) {
+		t.Errorf("bad partial image list %#v instead of %#v", ids, expectedIds)
+	}
+	if elapsed >= time.Second {
+		t.Errorf("the partial result took too long: %v", elapsed)
+	}
+	tester.verifyJournal(t, []string{"1/image/ListImages"})
+}
+
+func TestCriProxyMethodTimeouts(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.skipJournalItems("1/runtime/Version", "2/runtime/Version")
+	tester.waitForImages(t, 4)
+
+	// changing the timeouts doesn't cause reconnection
+	config := *tester.config
+	config.Runtimes = append([]RuntimeConfig(nil), config.Runtimes...)
+	config.MethodTimeouts = map[string]Duration{
+		"ImageStatus": {300 * time.Millisecond},
+	}
+	config.Runtimes[0].MethodTimeouts = map[string]Duration{
+		"ImageStatus": {0},
+	}
+	tester.reload(t, &config)
+
+	imageStatus := func(image string) (time.Duration, error) {
+		start := time.Now()
+		err := tester.invoke("/runtime.ImageService/ImageStatus", &runtimeapi.ImageStatusRequest{
+			Image: &runtimeapi.ImageSpec{Image: image},
+		}, &runtimeapi.ImageStatusResponse{})
+		return time.Since(start), err
+	}
+
+	// the timeout of the runtime takes precedence
+	// over the global one
+	tester.servers[0].SetDelay("ImageStatus", 500*time.Millisecond)
+	if _, err := imageStatus("image1-2"); err != nil {
+		t.Errorf("ImageStatus() failed: %v", err)
+	}
+
+	tester.servers[1].SetDelay("ImageStatus", 10*time.Second)
+	elapsed, err := imageStatus("alt/image2-1")
+	switch {
+	case grpc.Code(err) != codes.DeadlineExceeded:
+		t.Errorf("ImageStatus() didn't fail with DeadlineExceeded error: %v", err)
+	case !strings.Contains(grpc.ErrorDesc(err), `runtime "alt": ImageStatus timed out after 300ms`):
+		t.Errorf("bad error message: %v", err)
+	}
+	if elapsed >= 5*time.Second {
+		t.Errorf("the timeout wasn't applied (ImageStatus took %v)", elapsed)
+	}
+	tester.verifyJournal(t, []string{"1/image/ImageStatus"})
+}
+
+func TestCriProxyCircuitBreaker(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.skipJournalItems("1/runtime/Version", "2/runtime/Version")
+	tester.waitForImages(t, 4)
+
+	config := *tester.config
+	config.Runtimes = append([]RuntimeConfig(nil), config.Runtimes...)
+	config.Runtimes[1].MethodTimeouts = map[string]Duration{
+		"ListImages": {200 * time.Millisecond},
+	}
+	config.Runtimes[1].BreakerThreshold = 2
+	config.Runtimes[1].BreakerCooldown = Duration{time.Second}
+	tester.reload(t, &config)
+	// wait for the proxy to reconnect to the updated runtime
+	tester.waitForImages(t, 4)
+
+	listImages := func() []*runtimeapi.Image {
+		var resp runtimeapi.ListImagesResponse
+		if err := tester.invoke("/runtime.ImageService/ListImages", &runtimeapi.ListImagesRequest{}, &resp); err != nil {
+			t.Fatalf("ListImages() failed: %v", err)
+		}
+		return resp.Images
+	}
+	breakerState := func() string {
+		return tester.proxies[0].RuntimeStatuses()[1].Breaker
+	}
+
+	tester.servers[1].SetDelay("ListImages", 10*time.Second)
+	for i := 0; i < 2; i++ {
+		if images := listImages(); len(images) != 2 {
+			t.Errorf("bad number of images: %d", len(images))
+		}
+	}
+	if state := breakerState(); state != "open" {
+		t.Errorf("bad breaker state %q", state)
+	}
+	tester.clearJournal()
+
+	// the runtime is skipped
+	start := time.Now()
+	if images := listImages(); len(images) != 2 {
+		t.Errorf("bad number of images: %d", len(images))
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("the runtime wasn't skipped (ListImages took %v)", elapsed)
+	}
+	tester.verifyJournal(t, []string{"1/image/ListImages"})
+
+	// the requests targeted at the runtime fail fast
+	err := tester.invoke("/runtime.ImageService/ImageStatus", &runtimeapi.ImageStatusRequest{
+		Image: &runtimeapi.ImageSpec{Image: "alt/image2-1"},
+	}, &runtimeapi.ImageStatusResponse{})
+	switch {
+	case grpc.Code(err) != codes.Unavailable:
+		t.Errorf("ImageStatus() didn't fail with Unavailable error: %v", err)
+	case !strings.Contains(grpc.ErrorDesc(err), `runtime "alt" is skipped after 2 consecutive failures`):
+		t.Errorf("bad error message: %v", err)
+	}
+	tester.verifyJournal(t, nil)
+
+	// the runtime is tried again after the cooldown period
+	tester.servers[1].SetDelay("ListImages", 0)
+	time.Sleep(time.Second)
+	if state := breakerState(); state != "half-open" {
+		t.Errorf("bad breaker state %q", state)
+	}
+	if images := listImages(); len(images) != 4 {
+		t.Errorf("bad number of images: %d", len(images))
+	}
+	if state := breakerState(); state != "closed" {
+		t.Errorf("bad breaker state %q", state)
+	}
+}
+
+func TestCriProxyStatusPolicy(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.waitForImages(t, 4)
+
+	status := func() []*runtimeapi.RuntimeCondition {
+		var resp runtimeapi.StatusResponse
+		if err := tester.invoke("/runtime.RuntimeService/Status", &runtimeapi.StatusRequest{}, &resp); err != nil {
+			t.Fatalf("Status() failed: %v", err)
+		}
+		return resp.GetStatus().GetConditions()
+	}
+	verifyStatus := func(expected []*runtimeapi.RuntimeCondition) {
+		conditions := status()
+		if !reflect.DeepEqual(conditions, expected) {
+			t.Errorf("bad conditions:\n%s\ninstead of:\n%s", dump(conditions), dump(expected))
+		}
+	}
+
+	tester.servers[1].SetFakeRuntimeCondition("NetworkReady", false, "NetworkPluginNotReady", "cni config uninitialized")
+	altConditions := []*runtimeapi.RuntimeCondition{
+		{
+			Type:    "RuntimeReady",
+			Status:  true,
+			Message: `runtime "alt"`,
+		},
+		{
+			Type:    "NetworkReady",
+			Status:  false,
+			Reason:  "NetworkPluginNotReady",
+			Message: `runtime "alt": cni config uninitialized`,
+		},
+	}
+	// by default, the secondary runtimes don't affect
+	// the readiness of the node
+	verifyStatus(append([]*runtimeapi.RuntimeCondition{
+		{Type: "RuntimeReady", Status: true},
+		{Type: "NetworkReady", Status: true},
+	}, altConditions...))
+
+	config := *tester.config
+	config.Runtimes = append([]RuntimeConfig(nil), config.Runtimes...)
+	config.Runtimes[1].StatusPolicy = StatusPolicyRequired
+	tester.reload(t, &config)
+	tester.waitForImages(t, 4)
+	verifyStatus(append([]*runtimeapi.RuntimeCondition{
+		{Type: "RuntimeReady", Status: true},
+		{
+			Type:    "NetworkReady",
+			Status:  false,
+			Reason:  "NetworkPluginNotReady",
+			Message: `runtime "alt": cni config uninitialized`,
+		},
+	}, altConditions...))
+
+	// the runtime that doesn't respond is not ready
+	tester.servers[1].Stop()
+	conditions := status()
+	if len(conditions) != 3 {
+		t.Fatalf("bad conditions:\n%s", dump(conditions))
+	}
+	for _, n := range []int{0, 2} {
+		c := conditions[n]
+		if c.Type != "RuntimeReady" || c.Status || c.Reason != "RuntimeUnavailable" || !strings.HasPrefix(c.Message, `runtime "alt": `) {
+			t.Errorf("bad condition %d:\n%s", n, dump(c))
+		}
+	}
+}
+
+func TestCriProxyRuntimeVersions(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer110,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.waitForImages(t, 4)
+
+	var resp runtimeapi.VersionResponse
+	if err := tester.invoke("/runtime.RuntimeService/Version", &runtimeapi.VersionRequest{}, &resp); err != nil {
+		t.Fatalf("Version() failed: %v", err)
+	}
+	if resp.RuntimeName != ProxyName || resp.RuntimeVersion != Version {
+		t.Errorf("bad runtime name / version: %q / %q", resp.RuntimeName, resp.RuntimeVersion)
+	}
+
+	var versions []*RuntimeVersion
+	for _, status := range tester.proxies[0].RuntimeStatuses() {
+		versions = append(versions, status.Version)
+	}
+	expectedVersions := []*RuntimeVersion{
+		{
+			Name:       proxytest.FakeRuntimeName,
+			Version:    "0.1.0",
+			ApiVersion: "0.1.0",
+			Api:        "runtime",
+		},
+		{
+			Name:       proxytest.FakeRuntimeName,
+			Version:    "0.1.0",
+			ApiVersion: "0.1.0",
+			Api:        "runtime.v1alpha2",
+			Converted:  true,
+		},
+	}
+	if !reflect.DeepEqual(versions, expectedVersions) {
+		t.Errorf("bad runtime versions:\n%s\ninstead of:\n%s", dump(versions), dump(expectedVersions))
+	}
+}
+
+func TestCriProxyImageFsInfo(t *testing.T) {
+	dir, mountInfoPath, uuidDir := makeFakeMounts(t)
+	defer os.RemoveAll(dir)
+	oldImageFsIds := imageFsIds
+	imageFsIds = newFsIdMapper(mountInfoPath, uuidDir)
+	defer func() { imageFsIds = oldImageFsIds }()
+
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer110,
+	})
+	defer tester.stop()
+	tester.servers[0].(*proxytest.FakeCriServer19).FakeImageServer19.SetFakeFilesystemUsage([]*runtimeapi.FilesystemUsage{
+		{
+			Timestamp:  1000,
+			StorageId:  &runtimeapi.StorageIdentifier{Uuid: "uuid-a"},
+			UsedBytes:  &runtimeapi.UInt64Value{Value: 100},
+			InodesUsed: &runtimeapi.UInt64Value{Value: 10},
+		},
+	})
+	// the secondary runtime keeps its images on its own
+	// filesystem and also on the one of the primary runtime
+	tester.servers[1].(*proxytest.FakeCriServer110).FakeImageServer110.SetFakeFilesystemUsage([]*v1_12.FilesystemUsage{
+		{
+			Timestamp:  2000,
+			FsId:       &v1_12.FilesystemIdentifier{Mountpoint: "/var/lib/images/containerd"},
+			UsedBytes:  &v1_12.UInt64Value{Value: 200},
+			InodesUsed: &v1_12.UInt64Value{Value: 20},
+		},
+		{
+			Timestamp:  3000,
+			FsId:       &v1_12.FilesystemIdentifier{Mountpoint: "/srv/images"},
+			UsedBytes:  &v1_12.UInt64Value{Value: 300},
+			InodesUsed: &v1_12.UInt64Value{Value: 30},
+		},
+	})
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.waitForImages(t, 4)
+
+	tester.verifyCall(t, "/runtime.ImageService/ImageFsInfo", &runtimeapi.ImageFsInfoRequest{}, &runtimeapi.ImageFsInfoResponse{
+		ImageFilesystems: []*runtimeapi.FilesystemUsage{
+			{
+				Timestamp:  1000,
+				StorageId:  &runtimeapi.StorageIdentifier{Uuid: "uuid-a"},
+				UsedBytes:  &runtimeapi.UInt64Value{Value: 400},
+				InodesUsed: &runtimeapi.UInt64Value{Value: 40},
+			},
+			{
+				Timestamp:  2000,
+				StorageId:  &runtimeapi.StorageIdentifier{Uuid: "uuid-b"},
+				UsedBytes:  &runtimeapi.UInt64Value{Value: 200},
+				InodesUsed: &runtimeapi.UInt64Value{Value: 20},
+			},
+		},
+	}, "")
+	tester.verifyJournalUnordered(t, []string{"1/image/ImageFsInfo", "2/image/ImageFsInfo"})
+
+	var fsIds [][]string
+	for _, status := range tester.proxies[0].RuntimeStatuses() {
+		fsIds = append(fsIds, status.ImageFilesystems)
+	}
+	expectedFsIds := [][]string{{"uuid-a"}, {"uuid-b", "uuid-a"}}
+	if !reflect.DeepEqual(fsIds, expectedFsIds) {
+		t.Errorf("bad image filesystems of the runtimes: %#v instead of %#v", fsIds, expectedFsIds)
+	}
+}
+
+func TestCriProxyImageGCPolicy(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.waitForImages(t, 4)
+
+	setPolicy := func(policy string, numImages int) {
+		config := *tester.config
+		config.Runtimes = append([]RuntimeConfig(nil), config.Runtimes...)
+		config.Runtimes[1].ImageGCPolicy = policy
+		tester.reload(t, &config)
+		tester.waitForImages(t, numImages)
+	}
+	removeImage := func(image string, allowed bool) {
+		err := tester.invoke("/runtime.ImageService/RemoveImage", &runtimeapi.RemoveImageRequest{
+			Image: &runtimeapi.ImageSpec{Image: image},
+		}, &runtimeapi.RemoveImageResponse{})
+		switch {
+		case allowed && err != nil:
+			t.Errorf("RemoveImage(%q) failed: %v", image, err)
+		case !allowed && grpc.Code(err) != codes.FailedPrecondition:
+			t.Errorf("RemoveImage(%q): expected FailedPrecondition error, got %v", image, err)
+		}
+	}
+	imageFsInfo := func() {
+		if err := tester.invoke("/runtime.ImageService/ImageFsInfo", &runtimeapi.ImageFsInfoRequest{}, &runtimeapi.ImageFsInfoResponse{}); err != nil {
+			t.Fatalf("ImageFsInfo() failed: %v", err)
+		}
+		tester.clearJournal()
+	}
+
+	setPolicy(ImageGCPolicyNever, 4)
+	removeImage("alt/image2-1", false)
+	removeImage("image1-1", true)
+	tester.verifyJournal(t, []string{"1/image/RemoveImage"})
+
+	setPolicy(ImageGCPolicySharedFs, 3)
+	// the image filesystems aren't known yet
+	removeImage("alt/image2-1", false)
+	// the runtimes use different image filesystems
+	imageFsInfo()
+	removeImage("alt/image2-1", false)
+	tester.verifyJournal(t, nil)
+
+	tester.servers[1].SetFakeFilesystemUsage(imageFsUUID1)
+	imageFsInfo()
+	removeImage("alt/image2-1", true)
+	tester.verifyJournal(t, []string{"2/image/RemoveImage"})
+}
+
+func TestCriProxyAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "criproxy-audit")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	auditPath := filepath.Join(dir, "audit.log")
+	auditLog := NewAuditLog(&AuditConfig{
+		Path:    auditPath,
+		Payload: true,
+		Include: []string{"*PodSandbox"},
+		Exclude: []string{"RemovePodSandbox"},
+	})
+	defer auditLog.Close()
+
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	for _, proxy := range tester.proxies {
+		proxy.SetAuditLog(auditLog)
+	}
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.waitForImages(t, 4)
+
+	tester.verifyCall(t, "/runtime.RuntimeService/RunPodSandbox", &runtimeapi.RunPodSandboxRequest{
+		Config: &runtimeapi.PodSandboxConfig{
+			Metadata: &runtimeapi.PodSandboxMetadata{
+				Name:      "pod-2-1",
+				Uid:       podUid2,
+				Namespace: "default",
+			},
+			Annotations: map[string]string{targetRuntimeAnnotationKey: "alt"},
+		},
+	}, &runtimeapi.RunPodSandboxResponse{
+		PodSandboxId: podSandboxId2,
+	}, "")
+	tester.verifyCall(t, "/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{
+		PodSandboxId: podSandboxId2,
+	}, &runtimeapi.StopPodSandboxResponse{}, "")
+	tester.verifyCall(t, "/runtime.RuntimeService/RemovePodSandbox", &runtimeapi.RemovePodSandboxRequest{
+		PodSandboxId: podSandboxId2,
+	}, &runtimeapi.RemovePodSandboxResponse{}, "")
+	tester.verifyCall(t, "/runtime.RuntimeService/StopPodSandbox", &runtimeapi.StopPodSandboxRequest{
+		PodSandboxId: "alt__no-such-pod",
+	}, &runtimeapi.StopPodSandboxResponse{}, "not found")
+
+	entries := readAuditLog(t, auditPath)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 audit log entries, got:\n%s", dump(entries))
+	}
+	for n, expected := range []*auditEntry{
+		{
+			Method:   "/runtime.RuntimeService/RunPodSandbox",
+			Runtimes: []string{"alt"},
+			Ids:      []string{podSandboxId2unprefixed},
+			Code:     "OK",
+		},
+		{
+			Method:   "/runtime.RuntimeService/StopPodSandbox",
+			Runtimes: []string{"alt"},
+			Ids:      []string{podSandboxId2unprefixed},
+			Code:     "OK",
+		},
+		{
+			Method:   "/runtime.RuntimeService/StopPodSandbox",
+			Runtimes: []string{"alt"},
+			Ids:      []string{"no-such-pod"},
+			Code:     "Unknown",
+		},
+	} {
+		e := entries[n]
+		if e.Method != expected.Method || !reflect.DeepEqual(e.Runtimes, expected.Runtimes) || !reflect.DeepEqual(e.Ids, expected.Ids) || e.Code != expected.Code {
+			t.Errorf("bad audit log entry %d:\n%s", n, dump(e))
+		}
+	}
+
+	// the payload is the request received by the proxy
+	// and the response returned by it
+	if !strings.Contains(string(entries[0].Request), `"kubernetes.io/target-runtime":"alt"`) {
+		t.Errorf("bad request in the audit log: %s", entries[0].Request)
+	}
+	if !strings.Contains(string(entries[1].Request), podSandboxId2) {
+		t.Errorf("the request in the audit log doesn't contain the original id: %s", entries[1].Request)
+	}
+	if !strings.Contains(string(entries[0].Response), podSandboxId2) {
+		t.Errorf("bad response in the audit log: %s", entries[0].Response)
+	}
+	if entries[2].Response != nil || !strings.Contains(entries[2].Error, "not found") {
+		t.Errorf("bad audit log entry for the failed request:\n%s", dump(entries[2]))
+	}
+}
+
+func TestCriProxyPerRuntimeStreamUrl(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		func(journal proxytest.Journal, streamUrl string) proxytest.FakeCriServer {
+			return proxytest.NewFakeCriServer19(journal, "/virtlet/exec/abcd")
+		},
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.skipJournalItems("1/runtime/Version", "2/runtime/Version")
+	tester.waitForImages(t, 4)
+
+	exec := func(containerId, expectedUrl string) {
+		tester.verifyCall(t, "/runtime.RuntimeService/Exec", &runtimeapi.ExecRequest{
+			ContainerId: containerId,
+			Cmd:         []string{"ls"},
+		}, &runtimeapi.ExecResponse{
+			Url: expectedUrl,
+		}, "")
+	}
+
+	// the runtimes without their own stream url
+	// use the one of the primary runtime
+	exec(containerId1, "http://127.0.0.1:11250/cri")
+	exec(containerId2, "http://127.0.0.1:11250/virtlet/exec/abcd")
+	tester.verifyJournal(t, []string{"1/runtime/Exec", "2/runtime/Exec"})
+
+	config := *tester.config
+	config.Runtimes = append([]RuntimeConfig(nil), config.Runtimes...)
+	config.Runtimes[1].StreamUrl = "http://127.0.0.1:10300/"
+	tester.reload(t, &config)
+	tester.waitForImages(t, 4)
+	exec(containerId1, "http://127.0.0.1:11250/cri")
+	exec(containerId2, "http://127.0.0.1:10300/virtlet/exec/abcd")
+	tester.verifyJournal(t, []string{"1/runtime/Exec", "2/runtime/Exec"})
+}
+
+func TestCriProxyStreamServer(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	streamServer, err := NewStreamServer("http://10.0.0.1:11251", 0)
+	if err != nil {
+		t.Fatalf("NewStreamServer(): %v", err)
+	}
+	for _, proxy := range tester.proxies {
+		proxy.SetStreamServer(streamServer)
+	}
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.skipJournalItems("1/runtime/Version", "2/runtime/Version")
+	tester.waitForImages(t, 4)
+
+	for _, tc := range []struct {
+		containerId string
+		runtimeUrl  string
+	}{
+		{containerId1, "http://127.0.0.1:11250/cri"},
+		{containerId2, "http://[::]:12345/stream"},
+	} {
+		var resp runtimeapi.ExecResponse
+		if err := tester.invoke("/runtime.RuntimeService/Exec", &runtimeapi.ExecRequest{
+			ContainerId: tc.containerId,
+			Cmd:         []string{"ls"},
+		}, &resp); err != nil {
+			t.Fatalf("Exec(): %v", err)
+		}
+		if !strings.HasPrefix(resp.Url, "http://10.0.0.1:11251/stream/") {
+			t.Errorf("the streaming url wasn't replaced: %q", resp.Url)
+			continue
+		}
+		u, err := url.Parse(resp.Url)
+		if err != nil {
+			t.Fatalf("can't parse the streaming url %q: %v", resp.Url, err)
+		}
+		target := streamServer.takeTarget(strings.TrimPrefix(u.Path, streamPathPrefix))
+		if target == nil || target.String() != tc.runtimeUrl {
+			t.Errorf("bad runtime url for %q: %v instead of %q", resp.Url, target, tc.runtimeUrl)
+		}
+	}
+	tester.verifyJournal(t, []string{"1/runtime/Exec", "2/runtime/Exec"})
+}
+
+func TestCriProxyGracefulShutdown(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		delay        time.Duration
+		timeout      time.Duration
+		expectFinish bool
+		expectError  bool
+	}{
+		{
+			name:         "in-flight request finishes",
+			delay:        500 * time.Millisecond,
+			timeout:      10 * time.Second,
+			expectFinish: true,
+		},
+		{
+			name:         "in-flight request is cancelled after the timeout",
+			delay:        10 * time.Second,
+			timeout:      300 * time.Millisecond,
+			expectFinish: false,
+			expectError:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+				proxytest.NewFakeCriServer19,
+				proxytest.NewFakeCriServer19,
+			})
+			defer tester.stop()
+			tester.startServers(t, -1)
+			tester.startProxy(t)
+			tester.connectToProxy(t)
+			tester.waitForImages(t, 4)
+
+			tester.servers[0].SetDelay("ImageStatus", tc.delay)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- tester.invoke("/runtime.ImageService/ImageStatus", &runtimeapi.ImageStatusRequest{
+					Image: &runtimeapi.ImageSpec{Image: "image1-2"},
+				}, &runtimeapi.ImageStatusResponse{})
+			}()
+			waitForDelayedCall(t, tester.servers[0], "ImageStatus")
+
+			if finished := tester.proxyServer.Shutdown(tc.timeout); finished != tc.expectFinish {
+				t.Errorf("Shutdown() returned %v", finished)
+			}
+			err := <-errCh
+			switch {
+			case !tc.expectError && err != nil:
+				t.Errorf("ImageStatus() failed: %v", err)
+			case tc.expectError && grpc.Code(err) != codes.Unavailable:
+				t.Errorf("ImageStatus() didn't fail with Unavailable error: %v", err)
+			}
+
+			// the connections to the runtimes are closed
+			for _, proxy := range tester.proxies {
+				for _, status := range proxy.RuntimeStatuses() {
+					if status.State != "offline" {
+						t.Errorf("runtime %q (%s) is %s after shutdown", status.Name, status.Api, status.State)
+					}
+				}
+			}
+
+			// new connections are not accepted
+			conn, err := utils.Dial(criProxySocketForTests, time.Second)
+			if err == nil {
+				conn.Close()
+				t.Errorf("the proxy still accepts connections after shutdown")
+			}
+		})
+	}
+}
+
+// waitForDelayedCall waits for a request for the method to reach
+// the fake runtime and get delayed there
+func waitForDelayedCall(t *testing.T, server proxytest.FakeCriServer, method string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for server.DelayedCalls(method) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s request to reach the runtime", method)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForRuntimeState waits for the connection of CRI 1.9 proxy to
+// the runtime to be in the specified state without making any CRI
+// requests
+func (tester *proxyTester) waitForRuntimeState(t *testing.T, name, state string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		for _, status := range tester.proxies[0].RuntimeStatuses() {
+			if status.Name == name && status.State == state {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for runtime %q to become %s", name, state)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestCriProxyRuntimeRestart(t *testing.T) {
+	tester := newProxyTester(t, altSocketSpec, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.startServers(t, -1)
+	tester.startProxy(t)
+	tester.connectToProxy(t)
+	tester.waitForImages(t, 4)
+
+	disconnects := runtimeDisconnectCount.WithLabelValues("alt", "runtime", disconnectReasonTransport)
+	nDisconnects := testutil.ToFloat64(disconnects)
+
+	// the proxy notices that the runtime is gone
+	// without waiting for a request to fail
+	tester.servers[1].Stop()
+	tester.waitForRuntimeState(t, "alt", "connecting")
+	if v := testutil.ToFloat64(disconnects) - nDisconnects; v != 1 {
+		t.Errorf("bad disconnect count increment: %v instead of 1", v)
+	}
+
+	// the primary runtime is still available
+	images := tester.waitForImages(t, 2)
+	if images[0].Id != "image1-1" || images[1].Id != "image1-2" {
+		t.Errorf("bad images from the primary runtime: %#v", images)
+	}
+
+	// the proxy reconnects to the restarted runtime by itself
+	server := proxytest.NewFakeCriServer19(proxytest.NewPrefixJournal(tester.journal, "2/"), "//[::]:12345/stream")
+	server.SetFakeImageSize(fakeImageSize2)
+	server.SetFakeImages([]string{"image2-1", "image2-2"})
+	tester.servers[1] = server
+	tester.startServers(t, 1)
+	tester.waitForRuntimeState(t, "alt", "connected")
+	tester.waitForImages(t, 4)
+}
+
 func init() {
 	// FIXME: testing.Verbose() always returns false
 	flag.Set("logtostderr", "true")
 	flag.Set("v", "5")
 }
-
-// TODO: test reconnecting after restart of a runtime
@@ -0,0 +1,93 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"strings"
+)
+
+// redactedValue replaces the secrets in the logged CRI objects
+const redactedValue = "<redacted>"
+
+// DefaultRedactEnv lists the patterns for the names of the
+// environment variables of the containers which values are
+// redacted in the logs and the audit log. The patterns use
+// path.Match syntax and are matched against the upper-cased names.
+var DefaultRedactEnv = []string{
+	"*PASSWORD*",
+	"*PASSWD*",
+	"*SECRET*",
+	"*TOKEN*",
+	"*KEY*",
+	"*CREDENTIAL*",
+	"*AUTH*",
+}
+
+// envRedactor returns a function that tells whether the value of
+// the environment variable with the specified name must be redacted.
+// The patterns are used in addition to DefaultRedactEnv.
+func envRedactor(patterns []string) func(name string) bool {
+	all := append([]string(nil), DefaultRedactEnv...)
+	for _, pattern := range patterns {
+		all = append(all, strings.ToUpper(pattern))
+	}
+	return func(name string) bool {
+		return matchAny(all, strings.ToUpper(name))
+	}
+}
+
+// redactString replaces a non-empty secret with redactedValue
+func redactString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// redactCmd keeps the command name but replaces its arguments,
+// which may contain secrets, with redactedValue
+func redactCmd(cmd []string) []string {
+	if len(cmd) <= 1 {
+		return cmd
+	}
+	r := []string{cmd[0]}
+	for range cmd[1:] {
+		r = append(r, redactedValue)
+	}
+	return r
+}
+
+// redact returns the raw CRI object with the secrets redacted if
+// its wrapped type implements Redactable. Otherwise, it returns
+// the object itself.
+func (r *RuntimeProxy) redact(o interface{}) interface{} {
+	if o == nil {
+		return nil
+	}
+	wrapped, _, err := r.criVersion.WrapObject(o)
+	if err != nil {
+		return o
+	}
+	redactable, ok := wrapped.(Redactable)
+	if !ok {
+		return o
+	}
+	r.Lock()
+	redactEnv := r.redactEnv
+	r.Unlock()
+	return redactable.Redacted(redactEnv)
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "github.com/Mirantis/criproxy/pkg/runtimeapis/v1"
+	v1_12 "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_12"
+	runtimeapi "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_9"
+)
+
+func TestRedact(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		criVersion CRIVersion
+		in         func() interface{}
+		expected   interface{}
+	}{
+		{
+			name:       "1.9 PullImage",
+			criVersion: &CRI19{},
+			in: func() interface{} {
+				return &runtimeapi.PullImageRequest{
+					Image: &runtimeapi.ImageSpec{Image: "image1"},
+					Auth: &runtimeapi.AuthConfig{
+						Username:      "user",
+						Password:      "secret",
+						Auth:          "dXNlcjpzZWNyZXQ=",
+						ServerAddress: "registry.example.com",
+						IdentityToken: "idtoken",
+					},
+				}
+			},
+			expected: &runtimeapi.PullImageRequest{
+				Image: &runtimeapi.ImageSpec{Image: "image1"},
+				Auth: &runtimeapi.AuthConfig{
+					Username:      "user",
+					Password:      redactedValue,
+					Auth:          redactedValue,
+					ServerAddress: "registry.example.com",
+					IdentityToken: redactedValue,
+				},
+			},
+		},
+		{
+			name:       "1.9 PullImage without auth",
+			criVersion: &CRI19{},
+			in: func() interface{} {
+				return &runtimeapi.PullImageRequest{Image: &runtimeapi.ImageSpec{Image: "image1"}}
+			},
+			expected: &runtimeapi.PullImageRequest{Image: &runtimeapi.ImageSpec{Image: "image1"}},
+		},
+		{
+			name:       "1.9 CreateContainer",
+			criVersion: &CRI19{},
+			in: func() interface{} {
+				return &runtimeapi.CreateContainerRequest{
+					PodSandboxId: "pod-1",
+					Config: &runtimeapi.ContainerConfig{
+						Image: &runtimeapi.ImageSpec{Image: "image1"},
+						Envs: []*runtimeapi.KeyValue{
+							{Key: "PATH", Value: "/bin"},
+							{Key: "Db_Password", Value: "secret"},
+							{Key: "API_TOKEN", Value: ""},
+							{Key: "DB_URL", Value: "postgres://u:p@db"},
+						},
+					},
+				}
+			},
+			expected: &runtimeapi.CreateContainerRequest{
+				PodSandboxId: "pod-1",
+				Config: &runtimeapi.ContainerConfig{
+					Image: &runtimeapi.ImageSpec{Image: "image1"},
+					Envs: []*runtimeapi.KeyValue{
+						{Key: "PATH", Value: "/bin"},
+						{Key: "Db_Password", Value: redactedValue},
+						{Key: "API_TOKEN", Value: ""},
+						{Key: "DB_URL", Value: redactedValue},
+					},
+				},
+			},
+		},
+		{
+			name:       "1.9 ExecSync",
+			criVersion: &CRI19{},
+			in: func() interface{} {
+				return &runtimeapi.ExecSyncRequest{
+					ContainerId: "container-1",
+					Cmd:         []string{"mysql", "-psecret"},
+					Timeout:     10,
+				}
+			},
+			expected: &runtimeapi.ExecSyncRequest{
+				ContainerId: "container-1",
+				Cmd:         []string{"mysql", redactedValue},
+				Timeout:     10,
+			},
+		},
+		{
+			name:       "1.9 objects without secrets",
+			criVersion: &CRI19{},
+			in: func() interface{} {
+				return &runtimeapi.StopPodSandboxRequest{PodSandboxId: "pod-1"}
+			},
+			expected: &runtimeapi.StopPodSandboxRequest{PodSandboxId: "pod-1"},
+		},
+		{
+			name:       "1.12 PullImage",
+			criVersion: &CRI112{},
+			in: func() interface{} {
+				return &v1_12.PullImageRequest{
+					Image: &v1_12.ImageSpec{Image: "image1"},
+					Auth: &v1_12.AuthConfig{
+						Username:      "user",
+						Password:      "secret",
+						RegistryToken: "regtoken",
+					},
+				}
+			},
+			expected: &v1_12.PullImageRequest{
+				Image: &v1_12.ImageSpec{Image: "image1"},
+				Auth: &v1_12.AuthConfig{
+					Username:      "user",
+					Password:      redactedValue,
+					RegistryToken: redactedValue,
+				},
+			},
+		},
+		{
+			name:       "1.12 CreateContainer",
+			criVersion: &CRI112{},
+			in: func() interface{} {
+				return &v1_12.CreateContainerRequest{
+					PodSandboxId: "pod-1",
+					Config: &v1_12.ContainerConfig{
+						Envs: []*v1_12.KeyValue{
+							{Key: "HOME", Value: "/root"},
+							{Key: "AWS_SECRET_ACCESS_KEY", Value: "secret"},
+						},
+					},
+				}
+			},
+			expected: &v1_12.CreateContainerRequest{
+				PodSandboxId: "pod-1",
+				Config: &v1_12.ContainerConfig{
+					Envs: []*v1_12.KeyValue{
+						{Key: "HOME", Value: "/root"},
+						{Key: "AWS_SECRET_ACCESS_KEY", Value: redactedValue},
+					},
+				},
+			},
+		},
+		{
+			name:       "1.12 Exec",
+			criVersion: &CRI112{},
+			in: func() interface{} {
+				return &v1_12.ExecRequest{
+					ContainerId: "container-1",
+					Cmd:         []string{"sh", "-c", "echo secret"},
+					Stdout:      true,
+				}
+			},
+			expected: &v1_12.ExecRequest{
+				ContainerId: "container-1",
+				Cmd:         []string{"sh", redactedValue, redactedValue},
+				Stdout:      true,
+			},
+		},
+		{
+			name:       "1.12 ExecSync without args",
+			criVersion: &CRI112{},
+			in: func() interface{} {
+				return &v1_12.ExecSyncRequest{ContainerId: "container-1", Cmd: []string{"ls"}}
+			},
+			expected: &v1_12.ExecSyncRequest{ContainerId: "container-1", Cmd: []string{"ls"}},
+		},
+		{
+			name:       "v1 ExecSync",
+			criVersion: &CRIv1{},
+			in: func() interface{} {
+				return &v1.ExecSyncRequest{ContainerId: "container-1", Cmd: []string{"cat", "/etc/shadow"}}
+			},
+			expected: &v1.ExecSyncRequest{ContainerId: "container-1", Cmd: []string{"cat", redactedValue}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &RuntimeProxy{
+				criVersion: tc.criVersion,
+				redactEnv:  envRedactor([]string{"db_*"}),
+			}
+			in := tc.in()
+			redacted := r.redact(in)
+			if !reflect.DeepEqual(redacted, tc.expected) {
+				t.Errorf("bad redacted object:\n%s\ninstead of:\n%s", dump(redacted), dump(tc.expected))
+			}
+			// the request that's passed to the runtime is not modified
+			if original := tc.in(); !reflect.DeepEqual(in, original) {
+				t.Errorf("the original object was modified:\n%s\ninstead of:\n%s", dump(in), dump(original))
+			}
+		})
+	}
+}
+
+func TestRedactAuditPayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "criproxy-audit")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	auditPath := filepath.Join(dir, "audit.log")
+	auditLog := NewAuditLog(&AuditConfig{Path: auditPath, Payload: true})
+	defer auditLog.Close()
+
+	r := &RuntimeProxy{criVersion: &CRI19{}, redactEnv: envRedactor(nil)}
+	e := auditLog.start("/runtime.ImageService/PullImage", &runtimeapi.PullImageRequest{
+		Image: &runtimeapi.ImageSpec{Image: "image1"},
+		Auth:  &runtimeapi.AuthConfig{Username: "user", Password: "secret"},
+	}, r.redact)
+	auditLog.finish(e, &runtimeapi.PullImageResponse{ImageRef: "image1"}, nil)
+
+	entries := readAuditLog(t, auditPath)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit log entry, got:\n%s", dump(entries))
+	}
+	var req runtimeapi.PullImageRequest
+	if err := json.Unmarshal(entries[0].Request, &req); err != nil {
+		t.Fatalf("can't unmarshal the request from the audit log: %v", err)
+	}
+	if req.Auth.GetUsername() != "user" || req.Auth.GetPassword() != redactedValue {
+		t.Errorf("the password is not redacted in the audit log: %s", entries[0].Request)
+	}
+}
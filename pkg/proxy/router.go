@@ -0,0 +1,405 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	idPrefixSeparator = "__"
+)
+
+// PodSandboxInfo contains the information about a new pod sandbox
+// that's used to choose the runtime for it.
+type PodSandboxInfo struct {
+	// Name is the name of the pod.
+	Name string
+	// Namespace is the namespace of the pod.
+	Namespace string
+	// Labels are the labels of the pod.
+	Labels map[string]string
+	// Annotations are the annotations of the pod.
+	Annotations map[string]string
+	// RuntimeHandler is the runtime handler requested for the pod
+	// (CRI 1.12+). The router may change it to alter the value
+	// that's passed on to the runtime.
+	RuntimeHandler string
+}
+
+// Router decides which runtimes handle CRI requests and translates
+// pod sandbox / container ids and image names between the proxy and
+// the runtimes. Empty runtime id denotes the primary runtime.
+type Router interface {
+	// PodSandboxRuntime returns the id of the runtime that should
+	// run the new pod sandbox.
+	PodSandboxRuntime(info *PodSandboxInfo) (string, error)
+	// ImageRuntime returns the id of the runtime that handles the
+	// image and the name of the image as it's known to that runtime.
+	ImageRuntime(imageName string) (runtimeId, runtimeImageName string)
+	// ImageName returns the image name reported by the proxy for
+	// the image name returned by the runtime.
+	ImageName(runtimeId, runtimeImageName string) string
+	// IdRuntime returns the id of the runtime that owns the pod
+	// sandbox or the container with the specified id and the id of
+	// the object as it's known to that runtime.
+	IdRuntime(id string) (runtimeId, runtimeObjectId string)
+	// ObjectId returns the pod sandbox or container id reported by
+	// the proxy for the id returned by the runtime.
+	ObjectId(runtimeId, runtimeObjectId string) string
+}
+
+// prefixRouter implements the default routing scheme. The pods are
+// placed according to their RuntimeHandler or
+// kubernetes.io/target-runtime annotation, the image names of the
+// secondary runtimes are prefixed with "prefix/" and the ids of
+// their pod sandboxes and containers are prefixed with "id__".
+type prefixRouter struct {
+	runtimes []RuntimeConfig
+}
+
+var _ Router = &prefixRouter{}
+
+func newPrefixRouter(runtimes []RuntimeConfig) *prefixRouter {
+	return &prefixRouter{runtimes: runtimes}
+}
+
+func (r *prefixRouter) runtimeConfig(runtimeId string) *RuntimeConfig {
+	for n := range r.runtimes {
+		if r.runtimes[n].Id == runtimeId {
+			return &r.runtimes[n]
+		}
+	}
+	return nil
+}
+
+// explicitPodSandboxRuntime returns the id of the runtime that's
+// explicitly requested for the pod sandbox via its runtime handler
+// or kubernetes.io/target-runtime annotation. found is false if
+// there's no such runtime.
+func (r *prefixRouter) explicitPodSandboxRuntime(info *PodSandboxInfo) (runtimeId string, found bool, err error) {
+	if info.RuntimeHandler != "" {
+		for _, rc := range r.runtimes {
+			for _, handler := range rc.RuntimeHandlers {
+				if handler != info.RuntimeHandler {
+					continue
+				}
+				if !rc.ForwardRuntimeHandler {
+					info.RuntimeHandler = ""
+				}
+				return rc.Id, true, nil
+			}
+		}
+	}
+	targetRuntime, found := info.Annotations[targetRuntimeAnnotationKey]
+	if !found {
+		return "", false, nil
+	}
+	for _, rc := range r.runtimes {
+		for _, value := range rc.AnnotationValues {
+			if value == targetRuntime {
+				return rc.Id, true, nil
+			}
+		}
+	}
+	return "", false, fmt.Errorf("criproxy: unknown runtime: %q", targetRuntime)
+}
+
+func (r *prefixRouter) PodSandboxRuntime(info *PodSandboxInfo) (string, error) {
+	runtimeId, _, err := r.explicitPodSandboxRuntime(info)
+	return runtimeId, err
+}
+
+func (r *prefixRouter) ImageRuntime(imageName string) (string, string) {
+	for _, rc := range r.runtimes {
+		for _, prefix := range rc.ImagePrefixes {
+			if strings.HasPrefix(imageName, prefix+"/") {
+				return rc.Id, imageName[len(prefix)+1:]
+			}
+		}
+	}
+	return "", imageName
+}
+
+func (r *prefixRouter) ImageName(runtimeId, runtimeImageName string) string {
+	rc := r.runtimeConfig(runtimeId)
+	if rc == nil || len(rc.ImagePrefixes) == 0 {
+		return runtimeImageName
+	}
+	return rc.ImagePrefixes[0] + "/" + runtimeImageName
+}
+
+func (r *prefixRouter) IdRuntime(id string) (string, string) {
+	for _, rc := range r.runtimes {
+		if !rc.isPrimary() && strings.HasPrefix(id, rc.Id+idPrefixSeparator) {
+			return rc.Id, id[len(rc.Id)+len(idPrefixSeparator):]
+		}
+	}
+	return "", id
+}
+
+func (r *prefixRouter) ObjectId(runtimeId, runtimeObjectId string) string {
+	if runtimeId == "" {
+		return runtimeObjectId
+	}
+	return runtimeId + idPrefixSeparator + runtimeObjectId
+}
+
+type podRoutingRule struct {
+	runtimeId  string
+	namespaces map[string]bool
+	selector   labels.Selector
+}
+
+func (rule *podRoutingRule) matches(info *PodSandboxInfo) bool {
+	if rule.namespaces != nil && !rule.namespaces[info.Namespace] {
+		return false
+	}
+	if rule.selector != nil && !rule.selector.Matches(labels.Set(info.Labels)) {
+		return false
+	}
+	return true
+}
+
+type imageRoutingRule struct {
+	runtimeId string
+	rx        *regexp.Regexp
+}
+
+// ruleRouter applies the routing rules from the config on top of
+// prefixRouter. The images that match an image rule are passed to
+// the runtime without any renaming.
+type ruleRouter struct {
+	*prefixRouter
+	podRules   []podRoutingRule
+	imageRules []imageRoutingRule
+}
+
+var _ Router = &ruleRouter{}
+
+func newRuleRouter(runtimes []RuntimeConfig, routing RoutingConfig) (*ruleRouter, error) {
+	r := &ruleRouter{prefixRouter: newPrefixRouter(runtimes)}
+	for _, rule := range routing.Pods {
+		podRule := podRoutingRule{runtimeId: rule.Runtime}
+		if len(rule.Namespaces) != 0 {
+			podRule.namespaces = make(map[string]bool)
+			for _, ns := range rule.Namespaces {
+				podRule.namespaces[ns] = true
+			}
+		}
+		if rule.LabelSelector != "" {
+			selector, err := labels.Parse(rule.LabelSelector)
+			if err != nil {
+				return nil, fmt.Errorf("bad label selector %q: %v", rule.LabelSelector, err)
+			}
+			podRule.selector = selector
+		}
+		r.podRules = append(r.podRules, podRule)
+	}
+	for _, rule := range routing.Images {
+		rx, err := regexp.Compile(rule.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("bad image regexp %q: %v", rule.Regexp, err)
+		}
+		r.imageRules = append(r.imageRules, imageRoutingRule{runtimeId: rule.Runtime, rx: rx})
+	}
+	return r, nil
+}
+
+// PodSandboxRuntime implements PodSandboxRuntime method of Router
+// interface. The runtime handler and the target runtime annotation
+// take precedence over the rules.
+func (r *ruleRouter) PodSandboxRuntime(info *PodSandboxInfo) (string, error) {
+	runtimeId, found, err := r.explicitPodSandboxRuntime(info)
+	if err != nil || found {
+		return runtimeId, err
+	}
+	for _, rule := range r.podRules {
+		if rule.matches(info) {
+			return rule.runtimeId, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *ruleRouter) ImageRuntime(imageName string) (string, string) {
+	for _, rule := range r.imageRules {
+		if rule.rx.MatchString(imageName) {
+			return rule.runtimeId, imageName
+		}
+	}
+	return r.prefixRouter.ImageRuntime(imageName)
+}
+
+func (r *ruleRouter) ImageName(runtimeId, runtimeImageName string) string {
+	for _, rule := range r.imageRules {
+		if rule.runtimeId == runtimeId && rule.rx.MatchString(runtimeImageName) {
+			return runtimeImageName
+		}
+	}
+	return r.prefixRouter.ImageName(runtimeId, runtimeImageName)
+}
+
+// idForgetter is implemented by the Routers that need to know about
+// the removal of pod sandboxes and containers.
+type idForgetter interface {
+	// ForgetId is called after the pod sandbox or the container
+	// with the specified id is removed.
+	ForgetId(id string)
+}
+
+// idSyncer is implemented by the Routers that need to know about
+// the complete lists of the pod sandboxes and containers of the
+// runtimes.
+type idSyncer interface {
+	// SyncIds is called with the ids of the pod sandboxes or the
+	// containers from the unfiltered List* response of the runtime
+	// that was requested at listedAt.
+	SyncIds(runtimeId string, kind idKind, ids []string, listedAt time.Time)
+}
+
+// tableRouter wraps another Router and passes the pod sandbox and
+// container ids of the secondary runtimes through unchanged, using
+// the persistent id table to find the runtimes that own them. The
+// ids that aren't in the table are resolved by the wrapped router,
+// so "id__" prefixed ids handed out before switching to this mode
+// keep working.
+type tableRouter struct {
+	Router
+	table *idTable
+}
+
+var _ Router = &tableRouter{}
+var _ idForgetter = &tableRouter{}
+var _ idSyncer = &tableRouter{}
+
+func newTableRouter(router Router, table *idTable) *tableRouter {
+	return &tableRouter{Router: router, table: table}
+}
+
+func (r *tableRouter) IdRuntime(id string) (string, string) {
+	if runtimeId, found := r.table.lookup(id); found {
+		return runtimeId, id
+	}
+	return r.Router.IdRuntime(id)
+}
+
+func (r *tableRouter) ObjectId(runtimeId, runtimeObjectId string) string {
+	if runtimeId != "" {
+		r.table.set(runtimeObjectId, runtimeId)
+	}
+	return runtimeObjectId
+}
+
+func (r *tableRouter) ForgetId(id string) {
+	r.table.remove(id)
+}
+
+func (r *tableRouter) SyncIds(runtimeId string, kind idKind, ids []string, listedAt time.Time) {
+	if runtimeId != "" {
+		r.table.sync(runtimeId, kind, ids, listedAt)
+	}
+}
+
+// NewRouter creates a Router for the specified config.
+func NewRouter(config *Config) (Router, error) {
+	var router Router
+	if len(config.Routing.Pods) == 0 && len(config.Routing.Images) == 0 {
+		router = newPrefixRouter(config.Runtimes)
+	} else {
+		var err error
+		if router, err = newRuleRouter(config.Runtimes, config.Routing); err != nil {
+			return nil, err
+		}
+	}
+	if config.IdMapping == IdMappingTable {
+		router = newTableRouter(router, getIdTable(config.IdTablePath))
+	}
+	return router, nil
+}
+
+func augmentSandbox(router Router, runtimeId string, runtimeSandbox PodSandbox) PodSandbox {
+	sandbox := runtimeSandbox.Copy()
+	sandbox.SetId(router.ObjectId(runtimeId, runtimeSandbox.Id()))
+	return sandbox
+}
+
+func augmentContainer(router Router, runtimeId string, runtimeContainer Container) Container {
+	container := runtimeContainer.Copy()
+	container.SetId(router.ObjectId(runtimeId, runtimeContainer.Id()))
+	container.SetPodSandboxId(router.ObjectId(runtimeId, runtimeContainer.PodSandboxId()))
+	// don't rename digests
+	if _, err := digest.Parse(runtimeContainer.Image()); err != nil {
+		container.SetImage(router.ImageName(runtimeId, runtimeContainer.Image()))
+	}
+	return container
+}
+
+func augmentContainerStats(router Router, runtimeId string, runtimeStats ContainerStats) ContainerStats {
+	stats := runtimeStats.Copy()
+	stats.SetId(router.ObjectId(runtimeId, runtimeStats.Id()))
+	return stats
+}
+
+func augmentImage(router Router, runtimeId string, runtimeImage Image) Image {
+	image := runtimeImage.Copy()
+	// only rename the image id if it's not a digest
+	// so we don't get prefix/sha256:... which doesn't make sense
+	if _, err := digest.Parse(image.Id()); err != nil {
+		image.SetId(router.ImageName(runtimeId, image.Id()))
+	}
+	newRepoTags := make([]string, len(image.RepoTags()))
+	for n, tag := range image.RepoTags() {
+		newRepoTags[n] = router.ImageName(runtimeId, tag)
+	}
+	image.SetRepoTags(newRepoTags)
+	// repo digests may or may not include the image name
+	newRepoDigests := make([]string, len(image.RepoDigests()))
+	for n, digest := range image.RepoDigests() {
+		p := strings.Index(digest, "@")
+		if p > 0 {
+			newRepoDigests[n] = router.ImageName(runtimeId, digest)
+		} else {
+			newRepoDigests[n] = digest
+		}
+	}
+	image.SetRepoDigests(newRepoDigests)
+	return image
+}
+
+// augmentObject converts the ids and the image names in the object
+// returned by the runtime to the ones reported by the proxy
+func augmentObject(router Router, runtimeId string, criObject CRIObject) CRIObject {
+	switch o := criObject.(type) {
+	case PodSandbox:
+		return augmentSandbox(router, runtimeId, o)
+	case Container:
+		return augmentContainer(router, runtimeId, o)
+	case ContainerStats:
+		return augmentContainerStats(router, runtimeId, o)
+	case Image:
+		return augmentImage(router, runtimeId, o)
+	default:
+		return o
+	}
+}
@@ -0,0 +1,423 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParseConfig(t *testing.T, data string) *Config {
+	config, err := ParseConfig([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseConfig(): %v", err)
+	}
+	return config
+}
+
+func mustMakeRouter(t *testing.T, config *Config) Router {
+	router, err := NewRouter(config)
+	if err != nil {
+		t.Fatalf("NewRouter(): %v", err)
+	}
+	return router
+}
+
+const routerTestConfig = `
+runtimes:
+- socket: /var/run/dockershim.sock
+- id: virtlet.cloud
+  socket: /run/virtlet.sock
+  annotationValues: [virtlet.cloud, virtlet]
+  runtimeHandlers: [virtlet]
+- id: alt
+  socket: /run/alt.sock
+  runtimeHandlers: [kata]
+  forwardRuntimeHandler: true
+`
+
+const routingRulesConfig = `
+routing:
+  pods:
+  - runtime: virtlet.cloud
+    namespaces: [vms]
+  - runtime: alt
+    labelSelector: "app in (db, cache),!legacy"
+  - namespaces: [kube-system]
+  - runtime: alt
+    namespaces: [kube-system, sandboxed]
+  images:
+  - runtime: virtlet.cloud
+    regexp: '^(images\.example\.com/vms|quay\.io/vm-images)/'
+  - regexp: '^docker\.io/'
+`
+
+func TestPodSandboxRouting(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		withRules       bool
+		info            PodSandboxInfo
+		runtimeId       string
+		expectedHandler string
+		error           string
+	}{
+		{
+			name: "no annotation",
+			info: PodSandboxInfo{Namespace: "default"},
+		},
+		{
+			name:      "annotation",
+			info:      PodSandboxInfo{Annotations: map[string]string{targetRuntimeAnnotationKey: "virtlet.cloud"}},
+			runtimeId: "virtlet.cloud",
+		},
+		{
+			name:      "alternative annotation value",
+			info:      PodSandboxInfo{Annotations: map[string]string{targetRuntimeAnnotationKey: "virtlet"}},
+			runtimeId: "virtlet.cloud",
+		},
+		{
+			name:  "unknown annotation value",
+			info:  PodSandboxInfo{Annotations: map[string]string{targetRuntimeAnnotationKey: "foobar"}},
+			error: "unknown runtime",
+		},
+		{
+			name:      "runtime handler (stripped)",
+			info:      PodSandboxInfo{RuntimeHandler: "virtlet"},
+			runtimeId: "virtlet.cloud",
+		},
+		{
+			name:            "runtime handler (forwarded)",
+			info:            PodSandboxInfo{RuntimeHandler: "kata"},
+			runtimeId:       "alt",
+			expectedHandler: "kata",
+		},
+		{
+			name:            "unknown runtime handler",
+			info:            PodSandboxInfo{RuntimeHandler: "gvisor"},
+			expectedHandler: "gvisor",
+		},
+		{
+			name:      "namespace rule",
+			withRules: true,
+			info:      PodSandboxInfo{Namespace: "vms"},
+			runtimeId: "virtlet.cloud",
+		},
+		{
+			name:      "label selector rule",
+			withRules: true,
+			info:      PodSandboxInfo{Namespace: "default", Labels: map[string]string{"app": "db"}},
+			runtimeId: "alt",
+		},
+		{
+			name:      "label selector rule mismatch",
+			withRules: true,
+			info:      PodSandboxInfo{Namespace: "default", Labels: map[string]string{"app": "db", "legacy": "1"}},
+		},
+		{
+			name:      "first matching rule wins",
+			withRules: true,
+			info:      PodSandboxInfo{Namespace: "kube-system"},
+		},
+		{
+			name:      "annotation takes precedence over the rules",
+			withRules: true,
+			info: PodSandboxInfo{
+				Namespace:   "sandboxed",
+				Annotations: map[string]string{targetRuntimeAnnotationKey: "virtlet"},
+			},
+			runtimeId: "virtlet.cloud",
+		},
+		{
+			name:            "runtime handler takes precedence over the rules",
+			withRules:       true,
+			info:            PodSandboxInfo{Namespace: "vms", RuntimeHandler: "kata"},
+			runtimeId:       "alt",
+			expectedHandler: "kata",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			configText := routerTestConfig
+			if tc.withRules {
+				configText += routingRulesConfig
+			}
+			router := mustMakeRouter(t, mustParseConfig(t, configText))
+			info := tc.info
+			runtimeId, err := router.PodSandboxRuntime(&info)
+			switch {
+			case tc.error == "" && err != nil:
+				t.Fatalf("PodSandboxRuntime(): %v", err)
+			case tc.error != "" && err == nil:
+				t.Fatalf("didn't get the expected error %q", tc.error)
+			case tc.error != "" && !strings.Contains(err.Error(), tc.error):
+				t.Fatalf("bad error message: %q instead of %q", err.Error(), tc.error)
+			case tc.error != "":
+				return
+			}
+			if runtimeId != tc.runtimeId {
+				t.Errorf("bad runtime id %q instead of %q", runtimeId, tc.runtimeId)
+			}
+			if info.RuntimeHandler != tc.expectedHandler {
+				t.Errorf("bad runtime handler %q instead of %q", info.RuntimeHandler, tc.expectedHandler)
+			}
+		})
+	}
+}
+
+func TestImageRouting(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		withRules        bool
+		image            string
+		runtimeId        string
+		runtimeImageName string
+	}{
+		{
+			name:             "primary runtime",
+			image:            "nginx:latest",
+			runtimeImageName: "nginx:latest",
+		},
+		{
+			name:             "image prefix",
+			image:            "virtlet.cloud/cirros",
+			runtimeId:        "virtlet.cloud",
+			runtimeImageName: "cirros",
+		},
+		{
+			name:             "default image prefix",
+			image:            "alt/busybox",
+			runtimeId:        "alt",
+			runtimeImageName: "busybox",
+		},
+		{
+			name:             "regexp rule",
+			withRules:        true,
+			image:            "quay.io/vm-images/cirros:0.4",
+			runtimeId:        "virtlet.cloud",
+			runtimeImageName: "quay.io/vm-images/cirros:0.4",
+		},
+		{
+			name:             "regexp rule for the primary runtime",
+			withRules:        true,
+			image:            "docker.io/alt/busybox",
+			runtimeImageName: "docker.io/alt/busybox",
+		},
+		{
+			name:             "image prefix with rules",
+			withRules:        true,
+			image:            "virtlet.cloud/cirros",
+			runtimeId:        "virtlet.cloud",
+			runtimeImageName: "cirros",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			configText := routerTestConfig
+			if tc.withRules {
+				configText += routingRulesConfig
+			}
+			router := mustMakeRouter(t, mustParseConfig(t, configText))
+			runtimeId, runtimeImageName := router.ImageRuntime(tc.image)
+			if runtimeId != tc.runtimeId || runtimeImageName != tc.runtimeImageName {
+				t.Errorf("ImageRuntime(%q) = %q, %q instead of %q, %q", tc.image, runtimeId, runtimeImageName, tc.runtimeId, tc.runtimeImageName)
+			}
+			if imageName := router.ImageName(runtimeId, runtimeImageName); imageName != tc.image {
+				t.Errorf("ImageName(%q, %q) = %q instead of %q", runtimeId, runtimeImageName, imageName, tc.image)
+			}
+		})
+	}
+}
+
+func TestIdRouting(t *testing.T) {
+	router := mustMakeRouter(t, mustParseConfig(t, routerTestConfig))
+	for _, tc := range []struct {
+		id              string
+		runtimeId       string
+		runtimeObjectId string
+	}{
+		{"abcdef", "", "abcdef"},
+		{"virtlet.cloud__abcdef", "virtlet.cloud", "abcdef"},
+		{"alt__abcdef", "alt", "abcdef"},
+		{"foobar__abcdef", "", "foobar__abcdef"},
+	} {
+		runtimeId, runtimeObjectId := router.IdRuntime(tc.id)
+		if runtimeId != tc.runtimeId || runtimeObjectId != tc.runtimeObjectId {
+			t.Errorf("IdRuntime(%q) = %q, %q instead of %q, %q", tc.id, runtimeId, runtimeObjectId, tc.runtimeId, tc.runtimeObjectId)
+		}
+		if id := router.ObjectId(runtimeId, runtimeObjectId); id != tc.id {
+			t.Errorf("ObjectId(%q, %q) = %q instead of %q", runtimeId, runtimeObjectId, id, tc.id)
+		}
+	}
+}
+
+func TestTableIdRouting(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "criproxy-ids")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tablePath := filepath.Join(tmpDir, "ids.json")
+	router := newTableRouter(mustMakeRouter(t, mustParseConfig(t, routerTestConfig)), newIdTable(tablePath))
+
+	for _, tc := range []struct {
+		runtimeId string
+		id        string
+	}{
+		{"", "abcdef"},
+		{"virtlet.cloud", "012345"},
+		{"alt", "6789ab"},
+	} {
+		if id := router.ObjectId(tc.runtimeId, tc.id); id != tc.id {
+			t.Errorf("ObjectId(%q, %q) = %q instead of %q", tc.runtimeId, tc.id, id, tc.id)
+		}
+	}
+
+	// the table must survive the proxy restart
+	router = newTableRouter(mustMakeRouter(t, mustParseConfig(t, routerTestConfig)), newIdTable(tablePath))
+	for _, tc := range []struct {
+		id              string
+		runtimeId       string
+		runtimeObjectId string
+	}{
+		{"abcdef", "", "abcdef"},
+		{"012345", "virtlet.cloud", "012345"},
+		{"6789ab", "alt", "6789ab"},
+		{"unknown", "", "unknown"},
+		// prefixed ids are still recognized
+		{"alt__cdef01", "alt", "cdef01"},
+	} {
+		runtimeId, runtimeObjectId := router.IdRuntime(tc.id)
+		if runtimeId != tc.runtimeId || runtimeObjectId != tc.runtimeObjectId {
+			t.Errorf("IdRuntime(%q) = %q, %q instead of %q, %q", tc.id, runtimeId, runtimeObjectId, tc.runtimeId, tc.runtimeObjectId)
+		}
+	}
+
+	router.ForgetId("012345")
+	router = newTableRouter(mustMakeRouter(t, mustParseConfig(t, routerTestConfig)), newIdTable(tablePath))
+	if runtimeId, _ := router.IdRuntime("012345"); runtimeId != "" {
+		t.Errorf("the removed id is still mapped to %q", runtimeId)
+	}
+	if runtimeId, _ := router.IdRuntime("6789ab"); runtimeId != "alt" {
+		t.Errorf("bad runtime id for the remaining id: %q", runtimeId)
+	}
+}
+
+func TestTableIdSync(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "criproxy-ids")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tablePath := filepath.Join(tmpDir, "ids.json")
+	newRouter := func() *tableRouter {
+		return newTableRouter(mustMakeRouter(t, mustParseConfig(t, routerTestConfig)), newIdTable(tablePath))
+	}
+	router := newRouter()
+	router.ObjectId("alt", "sandbox1")
+	router.ObjectId("alt", "container1")
+	router.ObjectId("alt", "container2")
+	router.ObjectId("virtlet.cloud", "other1")
+
+	verifyIds := func(expected map[string]string) {
+		for id, expectedRuntimeId := range expected {
+			if runtimeId, _ := router.IdRuntime(id); runtimeId != expectedRuntimeId {
+				t.Errorf("IdRuntime(%q) returned runtime id %q instead of %q", id, runtimeId, expectedRuntimeId)
+			}
+		}
+	}
+
+	// the ids loaded from the file are subject to pruning
+	router = newRouter()
+	listedAt := time.Now()
+	router.SyncIds("alt", sandboxIds, []string{"sandbox1"}, listedAt)
+	// nothing is removed until the containers are listed, too
+	verifyIds(map[string]string{"container1": "alt", "container2": "alt"})
+
+	router.SyncIds("alt", containerIds, []string{"container1", "container3"}, listedAt)
+	verifyIds(map[string]string{
+		"sandbox1":   "alt",
+		"container1": "alt",
+		// removed together with its pod sandbox
+		"container2": "",
+		// added by the sync
+		"container3": "alt",
+		// another runtime
+		"other1": "virtlet.cloud",
+	})
+
+	listedAt = time.Now()
+	// the container that's created after the lists were requested
+	// is kept
+	router.ObjectId("alt", "container4")
+	router.SyncIds("alt", sandboxIds, []string{"sandbox1"}, listedAt)
+	router.SyncIds("alt", containerIds, []string{"container1"}, listedAt)
+	verifyIds(map[string]string{
+		"container3": "",
+		"container4": "alt",
+	})
+
+	// the primary runtime ids are not synced
+	router.SyncIds("", sandboxIds, []string{"sandbox2"}, time.Now())
+	verifyIds(map[string]string{"sandbox2": ""})
+
+	// the changes are saved
+	router = newRouter()
+	verifyIds(map[string]string{
+		"sandbox1":   "alt",
+		"container1": "alt",
+		"container2": "",
+		"container3": "",
+		"container4": "alt",
+		"other1":     "virtlet.cloud",
+	})
+}
+
+func TestCorruptedIdTable(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "criproxy-ids")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tablePath := filepath.Join(tmpDir, "ids.json")
+	corrupted := []byte(`{"012345": "virtlet.cl`)
+	if err := ioutil.WriteFile(tablePath, corrupted, 0644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+
+	router := newTableRouter(mustMakeRouter(t, mustParseConfig(t, routerTestConfig)), newIdTable(tablePath))
+	if runtimeId, _ := router.IdRuntime("012345"); runtimeId != "" {
+		t.Errorf("unexpected runtime id for the id from the corrupted table: %q", runtimeId)
+	}
+	data, err := ioutil.ReadFile(tablePath + ".bad")
+	if err != nil {
+		t.Fatalf("the corrupted table is not moved aside: %v", err)
+	}
+	if string(data) != string(corrupted) {
+		t.Errorf("bad contents of the corrupted table: %q", data)
+	}
+
+	router.ObjectId("alt", "6789ab")
+	router = newTableRouter(mustMakeRouter(t, mustParseConfig(t, routerTestConfig)), newIdTable(tablePath))
+	if runtimeId, _ := router.IdRuntime("6789ab"); runtimeId != "alt" {
+		t.Errorf("bad runtime id after saving the new table: %q", runtimeId)
+	}
+	if _, err := os.Stat(tablePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("the temporary file is left behind: %v", err)
+	}
+}
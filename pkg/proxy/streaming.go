@@ -0,0 +1,267 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// DefaultStreamTokenTTL is the time during which the streaming
+	// URLs issued by StreamServer stay valid. It matches the token
+	// TTL of the kubelet's streaming server.
+	DefaultStreamTokenTTL = time.Minute
+	streamPathPrefix      = "/stream/"
+	streamTokenLength     = 16
+	streamDialTimeout     = 30 * time.Second
+)
+
+type streamTarget struct {
+	url     *url.URL
+	expires time.Time
+}
+
+// StreamServer is an HTTP server that proxies exec, attach and
+// port-forward streams to the streaming servers of the runtimes.
+// The URLs returned by the runtimes in Exec, Attach and PortForward
+// responses are replaced with the URLs of StreamServer that contain
+// single-use tokens issued by it, so the runtimes' streaming servers
+// don't need to be reachable by the apiserver. Both SPDY and
+// websocket upgrades are supported.
+type StreamServer struct {
+	sync.Mutex
+	baseUrl  url.URL
+	tokenTTL time.Duration
+	targets  map[string]streamTarget
+	proxy    *httputil.ReverseProxy
+}
+
+var _ http.Handler = &StreamServer{}
+
+// NewStreamServer creates a new StreamServer. baseUrl is the URL of
+// the server as seen by the apiserver, e.g. http://10.0.0.1:11251
+func NewStreamServer(baseUrl string, tokenTTL time.Duration) (*StreamServer, error) {
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("bad stream server url %q: %v", baseUrl, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("bad stream server url %q: the scheme must be http or https", baseUrl)
+	}
+	if tokenTTL <= 0 {
+		tokenTTL = DefaultStreamTokenTTL
+	}
+	s := &StreamServer{
+		baseUrl:  *u,
+		tokenTTL: tokenTTL,
+		targets:  make(map[string]streamTarget),
+	}
+	// the protocol upgrades (SPDY/3.1, websocket) are handled
+	// by proxyUpgrade, the rest of the requests go through
+	// httputil.ReverseProxy
+	s.proxy = &httputil.ReverseProxy{
+		Director: func(req *http.Request) {},
+	}
+	return s, nil
+}
+
+func newStreamToken() (string, error) {
+	b := make([]byte, streamTokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("can't generate stream token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ProxyUrl registers the runtime's streaming URL and returns the
+// URL of the StreamServer that should be passed to the kubelet
+// instead of it.
+func (s *StreamServer) ProxyUrl(runtimeUrl string) (string, error) {
+	target, err := url.Parse(runtimeUrl)
+	if err != nil {
+		return "", fmt.Errorf("bad streaming url %q: %v", runtimeUrl, err)
+	}
+	// dockershim returns URLs without the scheme,
+	// e.g. //[::]:35057/cri/exec/tb8rgDBh
+	if target.Scheme == "" {
+		target.Scheme = "http"
+	}
+	switch {
+	case target.Scheme != "http" && target.Scheme != "https":
+		return "", fmt.Errorf("can't proxy streaming url %q: the scheme must be http or https", runtimeUrl)
+	case target.Host == "":
+		return "", fmt.Errorf("can't proxy streaming url %q: no host specified", runtimeUrl)
+	}
+	token, err := newStreamToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	for t, st := range s.targets {
+		if now.After(st.expires) {
+			delete(s.targets, t)
+		}
+	}
+	s.targets[token] = streamTarget{url: target, expires: now.Add(s.tokenTTL)}
+
+	u := s.baseUrl
+	u.Path = strings.TrimSuffix(u.Path, "/") + streamPathPrefix + token
+	return u.String(), nil
+}
+
+// takeTarget returns the runtime's streaming URL for the token and
+// invalidates the token.
+func (s *StreamServer) takeTarget(token string) *url.URL {
+	s.Lock()
+	defer s.Unlock()
+	st, found := s.targets[token]
+	if !found {
+		return nil
+	}
+	delete(s.targets, token)
+	if time.Now().After(st.expires) {
+		return nil
+	}
+	return st.url
+}
+
+// ServeHTTP implements ServeHTTP method of http.Handler interface.
+func (s *StreamServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p := strings.Index(req.URL.Path, streamPathPrefix)
+	if p < 0 {
+		http.NotFound(w, req)
+		return
+	}
+	target := s.takeTarget(req.URL.Path[p+len(streamPathPrefix):])
+	if target == nil {
+		http.NotFound(w, req)
+		return
+	}
+	glog.V(2).Infof("Proxying stream %s to %s", req.URL.Path, target)
+	outReq := req.WithContext(req.Context())
+	outReq.URL = &url.URL{
+		Scheme:   target.Scheme,
+		Host:     target.Host,
+		Path:     target.Path,
+		RawQuery: target.RawQuery,
+	}
+	// kubelet passes the query of the original request
+	// (command, stdin etc.) along with the streaming URL
+	if outReq.URL.RawQuery == "" {
+		outReq.URL.RawQuery = req.URL.RawQuery
+	}
+	outReq.Host = target.Host
+	if isUpgradeRequest(req) {
+		s.proxyUpgrade(w, outReq)
+		return
+	}
+	s.proxy.ServeHTTP(w, outReq)
+}
+
+// isUpgradeRequest returns true if the request asks for a protocol
+// upgrade, such as SPDY/3.1 or websocket.
+func isUpgradeRequest(req *http.Request) bool {
+	for _, v := range req.Header["Connection"] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyUpgrade passes the upgrade request to the runtime's streaming
+// server as is and then splices the connections. httputil.ReverseProxy
+// can't be used for this before Go 1.12 because it drops the
+// Connection and Upgrade headers.
+func (s *StreamServer) proxyUpgrade(w http.ResponseWriter, req *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		glog.Errorf("Can't proxy stream to %s: the connection can't be hijacked", req.URL)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	backendConn, err := dialStreamTarget(req.URL)
+	if err != nil {
+		glog.Errorf("Error proxying stream to %s: %v", req.URL, err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer backendConn.Close()
+	if err := req.Write(backendConn); err != nil {
+		glog.Errorf("Error proxying stream to %s: %v", req.URL, err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		glog.Errorf("Error proxying stream to %s: can't hijack the connection: %v", req.URL, err)
+		return
+	}
+	defer conn.Close()
+
+	// the response of the streaming server, including the
+	// 101 Switching Protocols status line, is passed through as is.
+	// The stream is done when either side closes its connection.
+	errCh := make(chan error, 2)
+	go func() {
+		// rw.Reader may contain the data that has been
+		// already read from the client connection
+		_, err := io.Copy(backendConn, rw.Reader)
+		errCh <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, backendConn)
+		errCh <- err
+	}()
+	if err := <-errCh; err != nil {
+		glog.V(2).Infof("Stream to %s closed: %v", req.URL, err)
+	}
+}
+
+// dialStreamTarget connects to the runtime's streaming server.
+func dialStreamTarget(u *url.URL) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: streamDialTimeout}
+	port := u.Port()
+	if u.Scheme == "https" {
+		if port == "" {
+			port = "443"
+		}
+		return tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(u.Hostname(), port), nil)
+	}
+	if port == "" {
+		port = "80"
+	}
+	return dialer.Dial("tcp", net.JoinHostPort(u.Hostname(), port))
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStreamServer(t *testing.T, tokenTTL time.Duration) (*StreamServer, *httptest.Server) {
+	var s *StreamServer
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.ServeHTTP(w, req)
+	}))
+	var err error
+	s, err = NewStreamServer(ts.URL, tokenTTL)
+	if err != nil {
+		ts.Close()
+		t.Fatalf("NewStreamServer(): %v", err)
+	}
+	return s, ts
+}
+
+func httpGet(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("error reading the response body: %v", err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestStreamServer(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "%s?%s", req.URL.Path, req.URL.RawQuery)
+	}))
+	defer backend.Close()
+
+	s, ts := newTestStreamServer(t, 0)
+	defer ts.Close()
+
+	proxyUrl, err := s.ProxyUrl(backend.URL + "/cri/exec/abcd")
+	if err != nil {
+		t.Fatalf("ProxyUrl(): %v", err)
+	}
+	if !strings.HasPrefix(proxyUrl, ts.URL+"/stream/") {
+		t.Errorf("bad proxy url %q", proxyUrl)
+	}
+	code, body := httpGet(t, proxyUrl+"?command=ls&stdout=1")
+	if code != http.StatusOK || body != "/cri/exec/abcd?command=ls&stdout=1" {
+		t.Errorf("bad response: %d %q", code, body)
+	}
+
+	// the tokens are single-use
+	if code, _ := httpGet(t, proxyUrl); code != http.StatusNotFound {
+		t.Errorf("reused token: expected status %d, got %d", http.StatusNotFound, code)
+	}
+	if code, _ := httpGet(t, ts.URL+"/stream/foobar"); code != http.StatusNotFound {
+		t.Errorf("unknown token: expected status %d, got %d", http.StatusNotFound, code)
+	}
+
+	// dockershim-style urls without the scheme
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("can't parse backend url: %v", err)
+	}
+	proxyUrl, err = s.ProxyUrl("//" + u.Host + "/cri/attach/efgh")
+	if err != nil {
+		t.Fatalf("ProxyUrl(): %v", err)
+	}
+	if code, body := httpGet(t, proxyUrl); code != http.StatusOK || body != "/cri/attach/efgh?" {
+		t.Errorf("bad response: %d %q", code, body)
+	}
+
+	if _, err := s.ProxyUrl("/cri/exec/abcd"); err == nil {
+		t.Errorf("didn't get an error for the url without the host")
+	}
+}
+
+func TestStreamServerTokenExpiration(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer backend.Close()
+
+	s, ts := newTestStreamServer(t, 50*time.Millisecond)
+	defer ts.Close()
+
+	proxyUrl, err := s.ProxyUrl(backend.URL + "/cri/exec/abcd")
+	if err != nil {
+		t.Fatalf("ProxyUrl(): %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if code, _ := httpGet(t, proxyUrl); code != http.StatusNotFound {
+		t.Errorf("expired token: expected status %d, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestStreamServerUpgrade(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Upgrade") != "SPDY/3.1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack(): %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: SPDY/3.1\r\n\r\n")
+		rw.Flush()
+		// echo the data back
+		io.Copy(conn, rw)
+	}))
+	defer backend.Close()
+
+	s, ts := newTestStreamServer(t, 0)
+	defer ts.Close()
+
+	proxyUrl, err := s.ProxyUrl(backend.URL + "/cri/exec/abcd")
+	if err != nil {
+		t.Fatalf("ProxyUrl(): %v", err)
+	}
+	u, err := url.Parse(proxyUrl)
+	if err != nil {
+		t.Fatalf("can't parse proxy url: %v", err)
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatalf("Dial(): %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	fmt.Fprintf(conn, "POST %s HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: SPDY/3.1\r\n\r\n", u.Path, u.Host)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse(): %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("bad status code %d", resp.StatusCode)
+	}
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatalf("Write(): %v", err)
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatalf("ReadString(): %v", err)
+	}
+	if line != "ping\n" {
+		t.Errorf("bad echo %q", line)
+	}
+}
@@ -17,16 +17,21 @@ limitations under the License.
 package testing
 
 import (
+	"crypto/tls"
 	"log"
-	"net"
-	"os"
-	"syscall"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	"github.com/Mirantis/criproxy/pkg/runtimeapis"
 	v1_12 "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_12"
 	v1_9 "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_9"
+	"github.com/Mirantis/criproxy/pkg/utils"
 )
 
 type FakeCriServer interface {
@@ -36,22 +41,90 @@ type FakeCriServer interface {
 	SetFakeImageSize(size uint64)
 	SetFakeContainerStats(containerId, containerName, imageFsUUID string) interface{}
 	SetFakeFilesystemUsage(imageFsUUID string) interface{}
+	SetFakeRuntimeCondition(conditionType string, status bool, reason, message string)
+	SetDelay(method string, delay time.Duration)
+	DelayedCalls(method string) int
+	SetTlsConfig(tlsConfig *tls.Config)
 	CurrentTime() int64
 }
 
 type fakeCriServerBase struct {
-	server *grpc.Server
+	mu        sync.Mutex
+	server    *grpc.Server
+	delays    map[string]time.Duration
+	delayed   map[string]int
+	tlsConfig *tls.Config
 }
 
-func newFakeCriServerBase() *fakeCriServerBase {
-	return &fakeCriServerBase{grpc.NewServer()}
+func newFakeCriServerBase(opts ...grpc.ServerOption) *fakeCriServerBase {
+	s := &fakeCriServerBase{
+		delays:  make(map[string]time.Duration),
+		delayed: make(map[string]int),
+	}
+	opts = append(opts, grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := s.wait(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}))
+	s.server = grpc.NewServer(opts...)
+	return s
 }
 
-func (s *fakeCriServerBase) Serve(addr string, readyCh chan struct{}) error {
-	if err := syscall.Unlink(addr); err != nil && !os.IsNotExist(err) {
-		return err
+// SetDelay makes the server wait for the specified duration before
+// handling the requests for the method, e.g. "ListPodSandbox". If
+// the request is cancelled or its deadline expires while waiting,
+// it fails without reaching the fake runtime.
+func (s *fakeCriServerBase) SetDelay(method string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delays[method] = delay
+}
+
+// DelayedCalls returns the number of the requests for the method
+// that are currently being delayed by the server.
+func (s *fakeCriServerBase) DelayedCalls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delayed[method]
+}
+
+func (s *fakeCriServerBase) wait(ctx context.Context, fullMethod string) error {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	s.mu.Lock()
+	delay := s.delays[method]
+	if delay != 0 {
+		s.delayed[method]++
+	}
+	s.mu.Unlock()
+	if delay == 0 {
+		return nil
 	}
-	ln, err := net.Listen("unix", addr)
+	defer func() {
+		s.mu.Lock()
+		s.delayed[method]--
+		s.mu.Unlock()
+	}()
+	select {
+	case <-ctx.Done():
+		return grpc.Errorf(codes.DeadlineExceeded, "%s: %v", fullMethod, ctx.Err())
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// SetTlsConfig sets the TLS config to be used for tls:// addresses.
+func (s *fakeCriServerBase) SetTlsConfig(tlsConfig *tls.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tlsConfig = tlsConfig
+}
+
+func (s *fakeCriServerBase) Serve(addr string, readyCh chan struct{}) error {
+	s.mu.Lock()
+	tlsConfig := s.tlsConfig
+	s.mu.Unlock()
+	ln, err := utils.Listen(addr, tlsConfig, utils.SocketPermissions{})
 	if err != nil {
 		return err
 	}
@@ -147,3 +220,52 @@ func (s *FakeCriServer110) SetFakeFilesystemUsage(imageFsUUID string) interface{
 func (s *FakeCriServer110) CurrentTime() int64 {
 	return s.FakeRuntimeServer110.CurrentTime
 }
+
+// FakeCriServerV1 serves CRI v1 using the fake CRI 1.10+ runtime and
+// image services. runtime.v1 is wire compatible with runtime.v1alpha2
+// for the fields used by the fake services, so the requests are just
+// decoded as their v1alpha2 counterparts. v1alpha2 services aren't
+// registered with the server.
+type FakeCriServerV1 struct {
+	*FakeCriServer110
+}
+
+var _ FakeCriServer = &FakeCriServerV1{}
+
+func NewFakeCriServerV1(journal Journal, streamUrl string) FakeCriServer {
+	s := &FakeCriServerV1{
+		FakeCriServer110: &FakeCriServer110{
+			FakeRuntimeServer110: NewFakeRuntimeServer110(NewPrefixJournal(journal, "runtime/"), streamUrl),
+			FakeImageServer110:   NewFakeImageServer110(NewPrefixJournal(journal, "image/")),
+		},
+	}
+	s.fakeCriServerBase = newFakeCriServerBase(grpc.UnknownServiceHandler(s.handle))
+	return s
+}
+
+func (s *FakeCriServerV1) handle(srv interface{}, stream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return grpc.Errorf(codes.Internal, "can't get the method name")
+	}
+	if err := s.wait(stream.Context(), fullMethod); err != nil {
+		return err
+	}
+	parts := strings.Split(fullMethod, "/")
+	if len(parts) != 3 || (parts[1] != "runtime.v1.RuntimeService" && parts[1] != "runtime.v1.ImageService") {
+		return grpc.Errorf(codes.Unimplemented, "unknown service for method %q", fullMethod)
+	}
+	m := reflect.ValueOf(s.FakeCriServer110).MethodByName(parts[2])
+	if !m.IsValid() || m.Type().NumIn() != 2 || m.Type().NumOut() != 2 {
+		return grpc.Errorf(codes.Unimplemented, "unknown method %q", fullMethod)
+	}
+	req := reflect.New(m.Type().In(1).Elem())
+	if err := stream.RecvMsg(req.Interface()); err != nil {
+		return err
+	}
+	out := m.Call([]reflect.Value{reflect.ValueOf(stream.Context()), req})
+	if err, _ := out[1].Interface().(error); err != nil {
+		return err
+	}
+	return stream.SendMsg(out[0].Interface())
+}
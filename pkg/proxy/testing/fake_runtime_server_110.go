@@ -146,7 +146,30 @@ func (r *FakeRuntimeServer110) Version(ctx context.Context, in *runtimeapi.Versi
 	}, nil
 }
 
+// SetFakeRuntimeCondition sets the runtime condition of the specified
+// type that's returned by Status.
+func (r *FakeRuntimeServer110) SetFakeRuntimeCondition(conditionType string, status bool, reason, message string) {
+	r.Lock()
+	defer r.Unlock()
+	// the old status may be in use by Status, so it's not modified
+	newStatus := &runtimeapi.RuntimeStatus{}
+	for _, c := range r.FakeStatus.Conditions {
+		if c.Type == conditionType {
+			c = &runtimeapi.RuntimeCondition{
+				Type:    conditionType,
+				Status:  status,
+				Reason:  reason,
+				Message: message,
+			}
+		}
+		newStatus.Conditions = append(newStatus.Conditions, c)
+	}
+	r.FakeStatus = newStatus
+}
+
 func (r *FakeRuntimeServer110) Status(ctx context.Context, in *runtimeapi.StatusRequest) (*runtimeapi.StatusResponse, error) {
+	r.Lock()
+	defer r.Unlock()
 	r.journal.Record("Status")
 	return &runtimeapi.StatusResponse{Status: r.FakeStatus}, nil
 }
@@ -155,7 +178,11 @@ func (r *FakeRuntimeServer110) RunPodSandbox(ctx context.Context, in *runtimeapi
 	r.Lock()
 	defer r.Unlock()
 
-	r.journal.Record("RunPodSandbox")
+	if in.RuntimeHandler != "" {
+		r.journal.Record("RunPodSandbox:" + in.RuntimeHandler)
+	} else {
+		r.journal.Record("RunPodSandbox")
+	}
 
 	// PodSandboxID should be randomized for real container runtime, but here just use
 	// fixed name from BuildSandboxName() for easily making fake sandboxes.
@@ -146,7 +146,30 @@ func (r *FakeRuntimeServer19) Version(ctx context.Context, in *runtimeapi.Versio
 	}, nil
 }
 
+// SetFakeRuntimeCondition sets the runtime condition of the specified
+// type that's returned by Status.
+func (r *FakeRuntimeServer19) SetFakeRuntimeCondition(conditionType string, status bool, reason, message string) {
+	r.Lock()
+	defer r.Unlock()
+	// the old status may be in use by Status, so it's not modified
+	newStatus := &runtimeapi.RuntimeStatus{}
+	for _, c := range r.FakeStatus.Conditions {
+		if c.Type == conditionType {
+			c = &runtimeapi.RuntimeCondition{
+				Type:    conditionType,
+				Status:  status,
+				Reason:  reason,
+				Message: message,
+			}
+		}
+		newStatus.Conditions = append(newStatus.Conditions, c)
+	}
+	r.FakeStatus = newStatus
+}
+
 func (r *FakeRuntimeServer19) Status(ctx context.Context, in *runtimeapi.StatusRequest) (*runtimeapi.StatusResponse, error) {
+	r.Lock()
+	defer r.Unlock()
 	r.journal.Record("Status")
 	return &runtimeapi.StatusResponse{Status: r.FakeStatus}, nil
 }
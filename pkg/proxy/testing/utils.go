@@ -36,6 +36,7 @@ package testing
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 )
@@ -116,6 +117,24 @@ func (j *SimpleJournal) Verify(expectedItems []string) error {
 	return nil
 }
 
+// VerifyUnordered verifies that the current contents of the journal
+// is expectedItems, ignoring the order of the items. It's used for
+// the requests that are passed to several runtimes concurrently.
+func (j *SimpleJournal) VerifyUnordered(expectedItems []string) error {
+	j.Lock()
+	defer j.Unlock()
+
+	actualItems := append([]string(nil), j.Items...)
+	j.Items = nil
+	sortedExpectedItems := append([]string(nil), expectedItems...)
+	sort.Strings(actualItems)
+	sort.Strings(sortedExpectedItems)
+	if !reflect.DeepEqual(actualItems, sortedExpectedItems) {
+		return fmt.Errorf("bad journal items. Expected %v (in any order), got %v", expectedItems, actualItems)
+	}
+	return nil
+}
+
 // PrefixJournal is an implementation of Journal interface that prefixes
 // every item passed to it with the specified prefix before passing it on
 // to the underlying Journal
@@ -0,0 +1,122 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type runtimeTimeouts struct {
+	request time.Duration
+	methods map[string]time.Duration
+}
+
+// requestTimeouts holds the deadlines for the requests that are
+// made to the runtimes.
+type requestTimeouts struct {
+	methods  map[string]time.Duration
+	runtimes map[string]runtimeTimeouts
+}
+
+// bareMethodName strips the package and the service from the method
+// name, e.g. "/runtime.ImageService/PullImage" becomes "PullImage".
+func bareMethodName(method string) string {
+	return method[strings.LastIndex(method, "/")+1:]
+}
+
+func durationMap(m map[string]Duration) map[string]time.Duration {
+	r := make(map[string]time.Duration)
+	for k, v := range m {
+		r[k] = v.Duration
+	}
+	return r
+}
+
+func newRequestTimeouts(config *Config) *requestTimeouts {
+	t := &requestTimeouts{
+		methods:  durationMap(config.MethodTimeouts),
+		runtimes: make(map[string]runtimeTimeouts),
+	}
+	for _, rc := range config.Runtimes {
+		t.runtimes[rc.Id] = runtimeTimeouts{
+			request: rc.RequestTimeout.Duration,
+			methods: durationMap(rc.MethodTimeouts),
+		}
+	}
+	return t
+}
+
+// timeout returns the timeout for the method of the runtime, or 0
+// if there's none. The method may be either a full gRPC method name
+// or a name without the service, e.g. "PullImage". The per-runtime
+// method timeouts take precedence over the global ones, which in
+// turn take precedence over the request timeout of the runtime.
+func (t *requestTimeouts) timeout(runtimeId, method string) time.Duration {
+	method = bareMethodName(method)
+	rt := t.runtimes[runtimeId]
+	if d, found := rt.methods[method]; found {
+		return d
+	}
+	if d, found := t.methods[method]; found {
+		return d
+	}
+	return rt.request
+}
+
+type requestTimeoutsKey struct{}
+
+func withRequestTimeouts(ctx context.Context, t *requestTimeouts) context.Context {
+	return context.WithValue(ctx, requestTimeoutsKey{}, t)
+}
+
+// runtimeContext derives the context for a request to a runtime from
+// the context of the incoming request, applying the timeout of the
+// method for the runtime. The timeout can only make the deadline of
+// the incoming request earlier. The timeout is returned together
+// with the context, with 0 meaning there's none.
+func runtimeContext(ctx context.Context, runtimeId, method string) (context.Context, context.CancelFunc, time.Duration) {
+	t, ok := ctx.Value(requestTimeoutsKey{}).(*requestTimeouts)
+	if !ok {
+		return ctx, func() {}, 0
+	}
+	timeout := t.timeout(runtimeId, method)
+	if timeout == 0 {
+		return ctx, func() {}, 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+// deadlineError makes the DeadlineExceeded error returned for a
+// request to a runtime mention the runtime and the timeout that
+// caused it, if any.
+func deadlineError(runtimeId, method string, timeout time.Duration, err error) error {
+	if grpc.Code(err) != codes.DeadlineExceeded {
+		return err
+	}
+	method = bareMethodName(method)
+	if timeout != 0 {
+		return status.Errorf(codes.DeadlineExceeded, "runtime %q: %s timed out after %v", runtimeName(runtimeId), method, timeout)
+	}
+	return status.Errorf(codes.DeadlineExceeded, "runtime %q: %s: %s", runtimeName(runtimeId), method, grpc.ErrorDesc(err))
+}
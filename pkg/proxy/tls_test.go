@@ -0,0 +1,203 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	proxytest "github.com/Mirantis/criproxy/pkg/proxy/testing"
+	runtimeapi "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_9"
+	"github.com/Mirantis/criproxy/pkg/utils"
+)
+
+type testCerts struct {
+	dir        string
+	caFile     string
+	serverCert string
+	serverKey  string
+	clientCert string
+	clientKey  string
+}
+
+func writePem(t *testing.T, path, blockType string, data []byte) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("can't create %q: %v", path, err)
+	}
+	defer f.Close()
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: data}); err != nil {
+		t.Fatalf("can't write %q: %v", path, err)
+	}
+}
+
+// makeTestCerts generates a CA and the server and client
+// certificates signed by it. The server certificate is valid
+// for 127.0.0.1
+func makeTestCerts(t *testing.T) *testCerts {
+	dir, err := ioutil.TempDir("", "criproxy-tls")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	certs := &testCerts{
+		dir:        dir,
+		caFile:     filepath.Join(dir, "ca.pem"),
+		serverCert: filepath.Join(dir, "server.pem"),
+		serverKey:  filepath.Join(dir, "server-key.pem"),
+		clientCert: filepath.Join(dir, "client.pem"),
+		clientKey:  filepath.Join(dir, "client-key.pem"),
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey(): %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "criproxy-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate(): %v", err)
+	}
+	writePem(t, certs.caFile, "CERTIFICATE", caDer)
+
+	for n, item := range []struct {
+		certFile, keyFile string
+		usage             x509.ExtKeyUsage
+	}{
+		{certs.serverCert, certs.serverKey, x509.ExtKeyUsageServerAuth},
+		{certs.clientCert, certs.clientKey, x509.ExtKeyUsageClientAuth},
+	} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey(): %v", err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(n + 2)),
+			Subject:      pkix.Name{CommonName: "criproxy-test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{item.usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("CreateCertificate(): %v", err)
+		}
+		writePem(t, item.certFile, "CERTIFICATE", der)
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatalf("MarshalECPrivateKey(): %v", err)
+		}
+		writePem(t, item.keyFile, "EC PRIVATE KEY", keyDer)
+	}
+	return certs
+}
+
+func freeTcpAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen(): %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestCriProxyTls(t *testing.T) {
+	certs := makeTestCerts(t)
+	defer os.RemoveAll(certs.dir)
+	serverTlsConfig := &TlsConfig{
+		CaFile:   certs.caFile,
+		CertFile: certs.serverCert,
+		KeyFile:  certs.serverKey,
+	}
+	serverConfig, err := serverTlsConfig.ServerConfig()
+	if err != nil {
+		t.Fatalf("ServerConfig(): %v", err)
+	}
+	clientTlsConfig := &TlsConfig{
+		CaFile:   certs.caFile,
+		CertFile: certs.clientCert,
+		KeyFile:  certs.clientKey,
+	}
+	clientConfig, err := clientTlsConfig.ClientConfig()
+	if err != nil {
+		t.Fatalf("ClientConfig(): %v", err)
+	}
+
+	runtimeAddr := "tls://" + freeTcpAddr(t)
+	tester := newProxyTester(t, "alt:"+runtimeAddr, []makeFakeCriServerFunc{
+		proxytest.NewFakeCriServer19,
+		proxytest.NewFakeCriServer19,
+	})
+	defer tester.stop()
+	tester.serverAddrs[1] = runtimeAddr
+	tester.servers[1].SetTlsConfig(serverConfig)
+	config := *tester.config
+	config.Runtimes = append([]RuntimeConfig(nil), config.Runtimes...)
+	config.Runtimes[1].Tls = clientTlsConfig
+	tester.reload(t, &config)
+	tester.startServers(t, -1)
+
+	proxyAddr := "tls://" + freeTcpAddr(t)
+	tester.proxyServer.SetTlsConfig(serverConfig)
+	startServer(t, tester.proxyServer, proxyAddr)
+	tester.conn, err = grpc.Dial(proxyAddr, grpc.WithInsecure(), grpc.WithTimeout(connectionTimeoutForTests), grpc.WithDialer(utils.NewDialer(clientConfig)))
+	if err != nil {
+		t.Fatalf("Connect to CRI proxy at %s failed: %v", proxyAddr, err)
+	}
+
+	// the images come from both the runtimes
+	tester.waitForImages(t, 4)
+
+	// the clients without the certificate are rejected
+	noCertConfig, err := (&TlsConfig{CaFile: certs.caFile}).ClientConfig()
+	if err != nil {
+		t.Fatalf("ClientConfig(): %v", err)
+	}
+	conn, err := grpc.Dial(proxyAddr, grpc.WithInsecure(), grpc.WithDialer(utils.NewDialer(noCertConfig)))
+	if err != nil {
+		t.Fatalf("Connect to CRI proxy at %s failed: %v", proxyAddr, err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := grpc.Invoke(ctx, "/runtime.ImageService/ListImages", &runtimeapi.ListImagesRequest{}, &runtimeapi.ListImagesResponse{}, conn); err == nil {
+		t.Errorf("ListImages() succeeded without the client certificate")
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+// ProxyName is the runtime name reported by CRI proxy in Version
+// responses.
+const ProxyName = "criproxy"
+
+// Version is the version of CRI proxy. It's set during the build
+// using -ldflags "-X github.com/Mirantis/criproxy/pkg/proxy.Version=..."
+var Version = "0.0.0"
+
+// RuntimeVersion describes the version of a runtime as reported
+// by it in response to Version request that's made when connecting
+// to it.
+type RuntimeVersion struct {
+	// Name is the name of the runtime, e.g. docker.
+	Name string `json:"name"`
+	// Version is the version of the runtime.
+	Version string `json:"version"`
+	// ApiVersion is the API version of the runtime.
+	ApiVersion string `json:"apiVersion"`
+	// Api is the proto package of CRI version that's used to
+	// talk to the runtime.
+	Api string `json:"api"`
+	// Converted is true if the requests and the responses are
+	// converted between the CRI version served by the proxy and
+	// the one used by the runtime.
+	Converted bool `json:"converted"`
+}
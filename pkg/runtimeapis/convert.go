@@ -19,32 +19,111 @@ package runtimeapis
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
+	_ "github.com/Mirantis/criproxy/pkg/runtimeapis/v1"
+	_ "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_12"
 	v1_9 "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_9"
 	"github.com/gogo/protobuf/proto"
 )
 
-func convertTo(in interface{}, targetProtoPackage string) (interface{}, error) {
+const (
+	// ProtoPackage19 is the proto package of CRI 1.9
+	ProtoPackage19 = "runtime"
+	// ProtoPackage112 is the proto package of CRI 1.10-1.19
+	ProtoPackage112 = "runtime.v1alpha2"
+	// ProtoPackageV1 is the proto package of CRI v1
+	ProtoPackageV1 = "runtime.v1"
+)
+
+func protoPackage(in interface{}) (string, error) {
+	msg, ok := in.(proto.Message)
+	if !ok {
+		return "", fmt.Errorf("%T is not a proto message", in)
+	}
+	name := proto.MessageName(msg)
+	p := strings.LastIndex(name, ".")
+	if p < 0 {
+		return "", fmt.Errorf("can't determine proto package for %T", in)
+	}
+	return name[:p], nil
+}
+
+func targetObject(in interface{}, targetProtoPackage string) (interface{}, error) {
 	targetTypeName := fmt.Sprintf("%s.%s", targetProtoPackage, reflect.TypeOf(in).Elem().Name())
 	mtype := proto.MessageType(targetTypeName)
 	if mtype == nil {
 		return nil, fmt.Errorf("target type for %T not found in proto package %q", in, targetProtoPackage)
 	}
-	if reflect.TypeOf(in) == mtype {
-		return in, nil
+	return reflect.New(mtype.Elem()).Interface(), nil
+}
+
+// convertUsingScheme converts an object between CRI 1.9 and CRI
+// 1.10-1.19 using the generated conversion functions
+func convertUsingScheme(in interface{}, targetProtoPackage string) (interface{}, error) {
+	out, err := targetObject(in, targetProtoPackage)
+	if err != nil {
+		return nil, err
 	}
-	out := reflect.New(mtype.Elem()).Interface()
 	return out, v1_9.Scheme.Convert(in, out, nil)
 }
 
+// convertUsingWireFormat converts an object between v1alpha2 and v1
+// versions of CRI. runtime.v1 started as a copy of runtime.v1alpha2
+// with the same field numbers, so the objects can be converted by
+// marshalling them and unmarshalling the result as the target type.
+func convertUsingWireFormat(in interface{}, targetProtoPackage string) (interface{}, error) {
+	out, err := targetObject(in, targetProtoPackage)
+	if err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(in.(proto.Message))
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling %T: %v", in, err)
+	}
+	if err := proto.Unmarshal(data, out.(proto.Message)); err != nil {
+		return nil, fmt.Errorf("error unmarshalling %T: %v", out, err)
+	}
+	return out, nil
+}
+
+// ConvertTo converts a raw CRI object to the CRI version that uses
+// the specified proto package. It just returns the object if it
+// already belongs to that version.
+func ConvertTo(in interface{}, targetProtoPackage string) (interface{}, error) {
+	sourceProtoPackage, err := protoPackage(in)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case sourceProtoPackage == targetProtoPackage:
+		return in, nil
+	case sourceProtoPackage == ProtoPackageV1 || targetProtoPackage == ProtoPackageV1:
+		if sourceProtoPackage == ProtoPackage19 {
+			if in, err = convertUsingScheme(in, ProtoPackage112); err != nil {
+				return nil, err
+			}
+		}
+		if targetProtoPackage != ProtoPackage19 {
+			return convertUsingWireFormat(in, targetProtoPackage)
+		}
+		if in, err = convertUsingWireFormat(in, ProtoPackage112); err != nil {
+			return nil, err
+		}
+		return convertUsingScheme(in, ProtoPackage19)
+	default:
+		return convertUsingScheme(in, targetProtoPackage)
+	}
+}
+
 // Upgrade converts CRI 1.9 object to CRI 1.12 one. It just returns
 // the object if it's already CRI 1.12.
 func Upgrade(in interface{}) (interface{}, error) {
-	return convertTo(in, "runtime.v1alpha2")
+	return ConvertTo(in, ProtoPackage112)
 }
 
 // Downgrade converts CRI 1.12 object to CRI 1.9 one. It just returns
 // the object if it's already CRI 1.9.
 func Downgrade(in interface{}) (interface{}, error) {
-	return convertTo(in, "runtime")
+	return ConvertTo(in, ProtoPackage19)
 }
@@ -23,6 +23,7 @@ import (
 
 	"github.com/ghodss/yaml"
 
+	v1 "github.com/Mirantis/criproxy/pkg/runtimeapis/v1"
 	v1_12 "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_12"
 	v1_9 "github.com/Mirantis/criproxy/pkg/runtimeapis/v1_9"
 )
@@ -187,3 +188,85 @@ func TestConversion(t *testing.T) {
 		}
 	}
 }
+
+func TestDowngradeRunPodSandboxRequestWithRuntimeHandler(t *testing.T) {
+	// v1.9 has no RuntimeHandler, so it's dropped
+	in := &v1_12.RunPodSandboxRequest{
+		Config:         podSandboxConfig10(nil),
+		RuntimeHandler: "kata",
+	}
+	expected := &v1_9.RunPodSandboxRequest{
+		Config: podSandboxConfig9(nil),
+	}
+	out, err := Downgrade(in)
+	switch {
+	case err != nil:
+		t.Fatalf("Downgrade: %v", err)
+	case !reflect.DeepEqual(out, expected):
+		t.Errorf("bad conversion: expected:\n%s\nactual:\n%s", mustYaml(expected), mustYaml(out))
+	}
+}
+
+func TestConversionV1(t *testing.T) {
+	in := &v1_9.RunPodSandboxRequest{
+		Config: podSandboxConfig9(&v1_9.NamespaceOption{HostNetwork: true}),
+	}
+	expected := &v1.RunPodSandboxRequest{
+		Config: &v1.PodSandboxConfig{
+			Metadata: &v1.PodSandboxMetadata{
+				Name:      "pod-1-1",
+				Uid:       podUid1,
+				Namespace: "default",
+			},
+			Labels: map[string]string{"name": "pod-1-1"},
+			Linux: &v1.LinuxPodSandboxConfig{
+				SecurityContext: &v1.LinuxSandboxSecurityContext{
+					NamespaceOptions: &v1.NamespaceOption{
+						Network: v1.NamespaceMode_NODE,
+					},
+				},
+			},
+		},
+	}
+	out, err := ConvertTo(in, ProtoPackageV1)
+	switch {
+	case err != nil:
+		t.Fatalf("ConvertTo: %v", err)
+	case !reflect.DeepEqual(out, expected):
+		t.Errorf("bad conversion: expected:\n%s\nactual:\n%s", mustYaml(expected), mustYaml(out))
+	}
+
+	switch out1, err := ConvertTo(out, ProtoPackageV1); {
+	case err != nil:
+		t.Errorf("ConvertTo (repeated): %v", err)
+	case out1 != out:
+		t.Errorf("ConvertTo is not idempotent")
+	}
+
+	back, err := ConvertTo(out, ProtoPackage19)
+	switch {
+	case err != nil:
+		t.Errorf("ConvertTo (reverse): %v", err)
+	case !reflect.DeepEqual(back, in):
+		t.Errorf("bad reverse conversion: expected:\n%s\nactual:\n%s", mustYaml(in), mustYaml(back))
+	}
+
+	in112 := &v1_12.RunPodSandboxRequest{
+		Config:         podSandboxConfig10(nil),
+		RuntimeHandler: "kata",
+	}
+	out, err = ConvertTo(in112, ProtoPackageV1)
+	switch {
+	case err != nil:
+		t.Fatalf("ConvertTo: %v", err)
+	case out.(*v1.RunPodSandboxRequest).RuntimeHandler != "kata":
+		t.Errorf("runtime handler lost during the conversion:\n%s", mustYaml(out))
+	}
+	back, err = ConvertTo(out, ProtoPackage112)
+	switch {
+	case err != nil:
+		t.Errorf("ConvertTo (reverse): %v", err)
+	case !reflect.DeepEqual(back, in112):
+		t.Errorf("bad reverse conversion: expected:\n%s\nactual:\n%s", mustYaml(in112), mustYaml(back))
+	}
+}
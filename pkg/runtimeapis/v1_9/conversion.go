@@ -68,7 +68,8 @@ func Convert_v1_12_ContainerConfig_To_v1_9_ContainerConfig(in *v1_12.ContainerCo
 
 func Convert_v1_12_FilesystemUsage_To_v1_9_FilesystemUsage(in *v1_12.FilesystemUsage, out *FilesystemUsage, s conversion.Scope) error {
 	out.Timestamp = in.Timestamp
-	// XXX: can't get old StorageId (UUID) from the new FsId which contains just a mount point
+	// XXX: can't get old StorageId (UUID) from the new FsId which contains just a mount point,
+	// CRI proxy restores it using the mounts of the node
 	out.UsedBytes = (*UInt64Value)(in.UsedBytes)
 	out.InodesUsed = (*UInt64Value)(in.InodesUsed)
 	return nil
@@ -76,7 +77,8 @@ func Convert_v1_12_FilesystemUsage_To_v1_9_FilesystemUsage(in *v1_12.FilesystemU
 
 func Convert_v1_9_FilesystemUsage_To_v1_12_FilesystemUsage(in *FilesystemUsage, out *v1_12.FilesystemUsage, s conversion.Scope) error {
 	out.Timestamp = in.Timestamp
-	// XXX: can't get new FsId which contains just a mount point from the old StorageId (UUID)
+	// XXX: can't get new FsId which contains just a mount point from the old StorageId (UUID),
+	// CRI proxy restores it using the mounts of the node
 	out.UsedBytes = (*v1_12.UInt64Value)(in.UsedBytes)
 	out.InodesUsed = (*v1_12.UInt64Value)(in.InodesUsed)
 	return nil
@@ -16,10 +16,18 @@ limitations under the License.
 package utils
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
 	"net"
 	"net/url"
 	"os"
+	"os/user"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
@@ -27,37 +35,290 @@ import (
 )
 
 const (
-	// FIXME: make the following configurable
-	// connect timeout when waiting for the socket to become available
-	connectWaitTimeout     = 500 * time.Millisecond
-	connectAttemptInterval = 500 * time.Millisecond
+	// DefaultDialTimeout is the timeout for connecting to the
+	// socket that's used if none is specified.
+	DefaultDialTimeout = 5 * time.Second
+	// DefaultInitialBackoff is the delay before the second
+	// connection attempt that's used if none is specified.
+	DefaultInitialBackoff = 500 * time.Millisecond
 )
 
-// dial creates a net.Conn by unix socket addr.
+// Backoff describes exponentially growing delays between the
+// connection attempts.
+type Backoff struct {
+	// Initial is the delay after the first failed attempt.
+	// DefaultInitialBackoff is used if it's zero.
+	Initial time.Duration
+	// Max is the maximum delay. The delay is not limited
+	// if it's zero.
+	Max time.Duration
+	// Jitter is the fraction of the delay by which it's randomly
+	// increased or decreased, from 0 to 1.
+	Jitter float64
+}
+
+// Delay returns the delay after the specified number of
+// consecutive failed attempts (starting from 1).
+func (b Backoff) Delay(failures int) time.Duration {
+	d := b.Initial
+	if d <= 0 {
+		d = DefaultInitialBackoff
+	}
+	for i := 1; i < failures && (b.Max <= 0 || d < b.Max) && d < math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	if b.Jitter > 0 {
+		d += time.Duration(b.Jitter * (2*rand.Float64() - 1) * float64(d))
+	}
+	return d
+}
+
+// Endpoint describes the address of a CRI endpoint.
+type Endpoint struct {
+	// Network is the network to use ("unix" or "tcp").
+	Network string
+	// Address is the unix socket path or host:port.
+	Address string
+	// Tls is true if the TLS must be used.
+	Tls bool
+}
+
+// ParseEndpoint parses the address of a CRI endpoint, which can
+// be either a plain path to a unix socket or a URL with
+// unix://, tcp:// or tls:// scheme, e.g. unix:///run/virtlet.sock,
+// tcp://10.0.0.2:10500 or tls://vm.example.com:10500
+func ParseEndpoint(addr string) (Endpoint, error) {
+	p := strings.Index(addr, "://")
+	if p < 0 {
+		if addr == "" {
+			return Endpoint{}, errors.New("empty endpoint address")
+		}
+		return Endpoint{Network: "unix", Address: addr}, nil
+	}
+	scheme, rest := addr[:p], addr[p+3:]
+	switch scheme {
+	case "unix":
+		if rest == "" {
+			return Endpoint{}, fmt.Errorf("bad endpoint address %q: no socket path", addr)
+		}
+		return Endpoint{Network: "unix", Address: rest}, nil
+	case "tcp", "tls":
+		if _, _, err := net.SplitHostPort(rest); err != nil {
+			return Endpoint{}, fmt.Errorf("bad endpoint address %q: %v", addr, err)
+		}
+		return Endpoint{Network: "tcp", Address: rest, Tls: scheme == "tls"}, nil
+	default:
+		return Endpoint{}, fmt.Errorf("bad endpoint address %q: unsupported scheme %q", addr, scheme)
+	}
+}
+
+// Dial creates a net.Conn for the specified CRI endpoint address.
+// It can't be used for tls:// addresses, see NewDialer.
 func Dial(addr string, timeout time.Duration) (net.Conn, error) {
-	return net.DialTimeout("unix", addr, timeout)
+	return NewDialer(nil)(addr, timeout)
+}
+
+// NewDialer returns a function that creates a net.Conn for the
+// specified CRI endpoint address. tlsConfig is used for tls://
+// addresses.
+func NewDialer(tlsConfig *tls.Config) func(addr string, timeout time.Duration) (net.Conn, error) {
+	return func(addr string, timeout time.Duration) (net.Conn, error) {
+		ep, err := ParseEndpoint(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ep.Tls && tlsConfig == nil {
+			return nil, fmt.Errorf("no TLS config for %q", addr)
+		}
+		conn, err := net.DialTimeout(ep.Network, ep.Address, timeout)
+		if err != nil || !ep.Tls {
+			return conn, err
+		}
+		config := tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(ep.Address)
+		}
+		tlsConn := tls.Client(conn, config)
+		if timeout > 0 {
+			tlsConn.SetDeadline(time.Now().Add(timeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with %q failed: %v", addr, err)
+		}
+		tlsConn.SetDeadline(time.Time{})
+		return tlsConn, nil
+	}
 }
 
-func WaitForSocket(path string, maxAttempts int, extraCheck func() error) error {
-	var err error
+// Listen makes a net.Listener for the specified CRI endpoint
+// address. tlsConfig is used for tls:// addresses. See ListenUnix
+// for the handling of unix sockets.
+func Listen(addr string, tlsConfig *tls.Config, perms SocketPermissions) (net.Listener, error) {
+	ep, err := ParseEndpoint(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ep.Tls && tlsConfig == nil {
+		return nil, fmt.Errorf("no TLS config for %q", addr)
+	}
+	if ep.Network == "unix" {
+		return ListenUnix(ep.Address, perms)
+	}
+	ln, err := net.Listen(ep.Network, ep.Address)
+	if err != nil || !ep.Tls {
+		return ln, err
+	}
+	return tls.NewListener(ln, tlsConfig), nil
+}
+
+// SocketPermissions specifies the mode and the group of
+// a unix socket.
+type SocketPermissions struct {
+	// Mode is the file mode of the socket. Zero means
+	// the default mode.
+	Mode os.FileMode
+	// Group is the name or the numeric id of the group that
+	// owns the socket. Empty means the default group.
+	Group string
+}
+
+func (p SocketPermissions) gid() (int, error) {
+	if p.Group == "" {
+		return -1, nil
+	}
+	if gid, err := strconv.Atoi(p.Group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(p.Group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// ListenUnix makes a net.Listener for the unix socket at the
+// specified path, which is removed first if it exists, and sets the
+// socket permissions. The socket is created with the umask that
+// doesn't give it any permissions beyond perms.Mode so that nobody
+// can connect to it before the permissions are set.
+func ListenUnix(path string, perms SocketPermissions) (net.Listener, error) {
+	gid, err := perms.gid()
+	if err != nil {
+		return nil, fmt.Errorf("bad socket group %q: %v", perms.Group, err)
+	}
+	if err := syscall.Unlink(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if perms.Mode == 0 {
+		return net.Listen("unix", path)
+	}
+	// NOTE: umask is process-wide, but the listener is normally
+	// created during the startup
+	oldUmask := syscall.Umask(int(0777 &^ perms.Mode.Perm()))
+	ln, err := net.Listen("unix", path)
+	syscall.Umask(oldUmask)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, perms.Mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("can't set the mode of %q: %v", path, err)
+	}
+	if gid >= 0 {
+		if err := os.Chown(path, -1, gid); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("can't set the group of %q: %v", path, err)
+		}
+	}
+	return ln, nil
+}
+
+// ActivationListener returns the listener passed by systemd via
+// socket activation (LISTEN_FDS protocol) or nil if there's none.
+// If there are several sockets, only the first one is used. The
+// environment variables that describe the passed sockets are unset
+// so that they're not inherited by the child processes.
+func ActivationListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds < 1 {
+		return nil, fmt.Errorf("bad LISTEN_FDS value %q", os.Getenv("LISTEN_FDS"))
+	}
+	if nfds > 1 {
+		glog.Warningf("%d sockets passed via socket activation, using only the first one", nfds)
+	}
+	// the passed fds start at 3
+	syscall.CloseOnExec(3)
+	f := os.NewFile(3, "LISTEN_FD_3")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("can't use the socket passed via socket activation: %v", err)
+	}
+	return ln, nil
+}
+
+// WaitForSocket waits for the CRI endpoint at the specified address
+// to become available. dialTimeout is the timeout for each
+// connection attempt (DefaultDialTimeout is used if it's zero), and
+// backoff specifies the delays between the attempts. If maxAttempts
+// is negative, the number of attempts is not limited. If stopCh is
+// not nil, closing it makes WaitForSocket give up waiting and return
+// an error. If onError is not nil, it's called with the error of
+// each failed attempt.
+func WaitForSocket(path string, dialTimeout time.Duration, backoff Backoff, maxAttempts int, stopCh <-chan struct{}, extraCheck func() error, onError func(err error)) error {
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	ep, err := ParseEndpoint(path)
+	if err != nil {
+		return err
+	}
 	var conn net.Conn
 	for n := 0; maxAttempts < 0 || n < maxAttempts; n++ {
-		if _, err = os.Stat(path); err != nil {
+		err = nil
+		select {
+		case <-stopCh:
+			return fmt.Errorf("stopped waiting for %q", path)
+		default:
+		}
+		if ep.Network == "unix" {
+			_, err = os.Stat(ep.Address)
+		}
+		if err != nil {
 			glog.V(1).Infof("attempt %d: %q is not here yet: %v", n, path, err)
-		} else if conn, err = Dial(path, connectWaitTimeout); err != nil {
+		} else if conn, err = net.DialTimeout(ep.Network, ep.Address, dialTimeout); err != nil {
 			glog.V(1).Infof("attempt %d: can't connect to %q yet: %v", n, path, err)
 		} else {
 			conn.Close()
-			if extraCheck != nil {
-				err = extraCheck()
-				if err != nil {
-					glog.V(1).Infof("attempt %d: extra check failed for %q: %v", n, path, err)
-					continue
-				}
+			if extraCheck == nil {
+				break
+			}
+			if err = extraCheck(); err == nil {
+				break
 			}
-			break
+			glog.V(1).Infof("attempt %d: extra check failed for %q: %v", n, path, err)
+		}
+		if onError != nil {
+			onError(err)
+		}
+		select {
+		case <-stopCh:
+			return fmt.Errorf("stopped waiting for %q", path)
+		case <-time.After(backoff.Delay(n + 1)):
 		}
-		time.Sleep(connectAttemptInterval)
 	}
 	return err
 }
@@ -0,0 +1,48 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	for n, expected := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		if d := b.Delay(n + 1); d != expected {
+			t.Errorf("Delay(%d) = %v instead of %v", n+1, d, expected)
+		}
+	}
+	if d := b.Delay(1000); d != time.Second {
+		t.Errorf("Delay(1000) = %v instead of %v", d, time.Second)
+	}
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := b.Delay(3); d < 200*time.Millisecond || d > 600*time.Millisecond {
+			t.Fatalf("Delay(3) with jitter = %v, out of range", d)
+		}
+	}
+}